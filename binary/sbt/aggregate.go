@@ -0,0 +1,273 @@
+package sbt
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Stats holds the count, sum, min and max of the non-null values of a numeric column.
+type Stats struct {
+	Count int64
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+// Avg returns the mean value, NaN if there are no values.
+func (s Stats) Avg() float64 {
+	if s.Count == 0 {
+		return math.NaN()
+	}
+
+	return s.Sum / float64(s.Count)
+}
+
+// add
+func (s *Stats) add(v float64) {
+	if s.Count == 0 || v < s.Min {
+		s.Min = v
+	}
+
+	if s.Count == 0 || v > s.Max {
+		s.Max = v
+	}
+
+	s.Count++
+	s.Sum += v
+}
+
+// merge
+func (s *Stats) merge(o Stats) {
+	if o.Count == 0 {
+		return
+	}
+
+	if s.Count == 0 || o.Min < s.Min {
+		s.Min = o.Min
+	}
+
+	if s.Count == 0 || o.Max > s.Max {
+		s.Max = o.Max
+	}
+
+	s.Count += o.Count
+	s.Sum += o.Sum
+}
+
+// Aggregate returns the Stats of the named integer, float or decimal column over the live rows,
+// reading only that column in parallel buckets like ParallelScan.
+func (c *Container[P, RowType]) Aggregate(ctx context.Context, column string) (stats Stats, err error) {
+	var values *columnScanner
+	if values, err = c.numericScanner(column); err != nil {
+		return
+	}
+
+	var mu sync.Mutex
+
+	err = c.scanBuckets(ctx, 0, 0, func(bucketSize int64) bucketVisitor {
+		values := values.clone(bucketSize)
+
+		return func(pos, n int64) error {
+			if err := values.read(pos, n); err != nil {
+				return err
+			}
+
+			var local Stats
+			for i := int64(0); i < n; i++ {
+				if b := values.value(i); b != nil && !c.tombstones.isDeleted(pos+i) {
+					local.add(values.float(b))
+				}
+			}
+
+			mu.Lock()
+			stats.merge(local)
+			mu.Unlock()
+
+			return nil
+		}
+	})
+
+	return
+}
+
+// GroupBy returns the Stats of the named integer, float or decimal column over the live rows, grouped by
+// the values of the key column. Keys are the Go values of AnyRow, except []byte which are keyed as string,
+// and nil for null keys.
+func (c *Container[P, RowType]) GroupBy(ctx context.Context, key, column string) (groups map[any]Stats, err error) {
+	var values *columnScanner
+	if values, err = c.numericScanner(column); err != nil {
+		return
+	}
+
+	var keys *columnScanner
+	if keys, err = c.newColumnScanner(key); err != nil {
+		return
+	}
+
+	var mu sync.Mutex
+	groups = make(map[any]Stats)
+
+	err = c.scanBuckets(ctx, 0, 0, func(bucketSize int64) bucketVisitor {
+		values, keys := values.clone(bucketSize), keys.clone(bucketSize)
+
+		decoder := c.newDecoder(nil)
+		if decoder.checks > CheckBounds {
+			decoder.checks = CheckBounds
+		}
+
+		return func(pos, n int64) error {
+			if err := values.read(pos, n); err != nil {
+				return err
+			}
+
+			if err := keys.read(pos, n); err != nil {
+				return err
+			}
+
+			local := make(map[any]Stats)
+			for i := int64(0); i < n; i++ {
+				b := values.value(i)
+				if b == nil || c.tombstones.isDeleted(pos+i) {
+					continue
+				}
+
+				var k any
+				if raw := keys.value(i); raw != nil {
+					decoder.Reset(raw)
+
+					var err error
+					if k, err = decodeValue(decoder, keys.column); err != nil {
+						return fmt.Errorf("failed to decode key of row %d: %w", pos+i, err)
+					}
+
+					if bin, ok := k.([]byte); ok {
+						k = string(bin)
+					}
+				}
+
+				stats := local[k]
+				stats.add(values.float(b))
+				local[k] = stats
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			for k, s := range local {
+				stats := groups[k]
+				stats.merge(s)
+				groups[k] = stats
+			}
+
+			return nil
+		}
+	})
+
+	return
+}
+
+// columnScanner reads the values of a column and their null bits in buckets, without the other columns.
+type columnScanner struct {
+	// readColumn is Container.readColumn
+	readColumn func(pos int64, col columnSlice, raw []byte) error
+	column     Column
+	col        columnSlice
+	// bitmap is the null bitmap of the rows, bit the null bit of the column or -1.
+	bitmap columnSlice
+	bit    int
+	float  func([]byte) float64
+	raw    []byte
+	nulls  []byte
+}
+
+// newColumnScanner returns a columnScanner of the named column, to be cloned by every worker.
+func (c *Container[P, RowType]) newColumnScanner(name string) (*columnScanner, error) {
+	i := c.spec.Index(name)
+	if i < 0 {
+		return nil, fmt.Errorf("unknown column %q", name)
+	}
+
+	return &columnScanner{
+		readColumn: c.readColumn,
+		column:     c.spec[i],
+		col:        columnSlice{offset: int64(c.spec.Offset(i)), size: int64(c.spec[i].Size)},
+		bitmap:     columnSlice{offset: 0, size: int64(c.spec.NullBitmapSize())},
+		bit:        c.spec.nullBit(i),
+	}, nil
+}
+
+// numericScanner returns a columnScanner of the named integer, float or decimal column.
+func (c *Container[P, RowType]) numericScanner(name string) (s *columnScanner, err error) {
+	if s, err = c.newColumnScanner(name); err != nil {
+		return
+	}
+
+	if s.float = numericDecoder(s.column, c.order); s.float == nil {
+		return nil, fmt.Errorf("can't aggregate %s column %q", s.column.Type, name)
+	}
+
+	return
+}
+
+// clone returns a copy of s with its own buffers of bucketSize rows.
+func (s *columnScanner) clone(bucketSize int64) *columnScanner {
+	clone := *s
+	clone.raw = make([]byte, bucketSize*s.col.size)
+	if s.bit >= 0 {
+		clone.nulls = make([]byte, bucketSize*s.bitmap.size)
+	}
+
+	return &clone
+}
+
+// read reads the n rows starting at pos.
+func (s *columnScanner) read(pos, n int64) error {
+	if err := s.readColumn(pos, s.col, s.raw[:n*s.col.size]); err != nil {
+		return fmt.Errorf("failed to read column %q: %w", s.column.Name, err)
+	}
+
+	if s.bit >= 0 {
+		if err := s.readColumn(pos, s.bitmap, s.nulls[:n*s.bitmap.size]); err != nil {
+			return fmt.Errorf("failed to read null bitmap: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// value returns the bytes of the value of row i of the bucket, nil if it's null.
+func (s *columnScanner) value(i int64) []byte {
+	if s.bit >= 0 && s.nulls[i*s.bitmap.size+int64(s.bit/8)]&(1<<(s.bit%8)) != 0 {
+		return nil
+	}
+
+	return s.raw[i*s.col.size : (i+1)*s.col.size]
+}
+
+// numericDecoder returns the function decoding an integer, float or decimal column value as a float64,
+// nil for other types.
+func numericDecoder(column Column, order binary.ByteOrder) func([]byte) float64 {
+	switch column.Type {
+	case ColumnTypeUInt64:
+		return func(b []byte) float64 { return float64(order.Uint64(b)) }
+	case ColumnTypeFloat32:
+		return func(b []byte) float64 { return float64(math.Float32frombits(order.Uint32(b))) }
+	case ColumnTypeFloat64:
+		return func(b []byte) float64 { return math.Float64frombits(order.Uint64(b)) }
+	case ColumnTypeDecimal:
+		return func(b []byte) float64 {
+			return Decimal{Unscaled: int64(order.Uint64(b)), Scale: column.Scale}.Float64()
+		}
+	case ColumnTypeTime:
+		return nil
+	}
+
+	if key := keyDecoder(column.Type, order); key != nil {
+		return func(b []byte) float64 { return float64(key(b)) }
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package sbt
+
+import (
+	"fmt"
+	"os"
+)
+
+// AnyRow is a schema-less Row, decoding every column of the stored RowSpec into Values.
+//
+// Values holds nil for null columns, bool, the sized int, uint and float types, string for str and vstr columns,
+// []byte for bin and vbin columns, time.Time, Decimal, UUID and netip.Addr. When encoding, any value
+// convertible to the column type is accepted, e.g. strings for uuid columns, nil encodes the zero value.
+type AnyRow struct {
+	Spec   RowSpec
+	Values []any
+}
+
+// OpenAny opens any Container file for reading without its row type, rows are decoded into AnyRow.
+func OpenAny(filename string, options ...Option) (*Container[*AnyRow, AnyRow], error) {
+	return open[*AnyRow, AnyRow](filename, os.O_RDONLY, 0666, options)
+}
+
+// Factory
+func (r *AnyRow) Factory() Row {
+	return new(AnyRow)
+}
+
+// Columns returns nil, so the stored RowSpec is accepted as is.
+func (r *AnyRow) Columns() RowSpec {
+	return nil
+}
+
+// Encode
+func (r *AnyRow) Encode(ctx *Encoder) (err error) {
+	if len(r.Values) != len(ctx.spec) {
+		return fmt.Errorf("expected %d values, got %d", len(ctx.spec), len(r.Values))
+	}
+
+	return encodeValues(ctx, r.Values)
+}
+
+// Decode
+func (r *AnyRow) Decode(ctx *Decoder) (err error) {
+	r.Spec = ctx.spec
+	r.Values = make([]any, len(ctx.spec))
+
+	return decodeValues(ctx, r.Values)
+}
+
+// Get returns the value of the named column, or nil if there's no such column.
+func (r *AnyRow) Get(name string) any {
+	if i := r.Spec.Index(name); i >= 0 && i < len(r.Values) {
+		return r.Values[i]
+	}
+
+	return nil
+}
+
+// Map returns the values keyed by column name.
+func (r *AnyRow) Map() map[string]any {
+	m := make(map[string]any, len(r.Values))
+	for i, v := range r.Values {
+		if i < len(r.Spec) {
+			m[r.Spec[i].Name] = v
+		}
+	}
+
+	return m
+}
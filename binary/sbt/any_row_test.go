@@ -0,0 +1,96 @@
+package sbt
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAny(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "any.sbt")
+
+	c, err := Create[*exportRow, exportRow](filename)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	rows := testExportRows(10)
+	if err = c.BulkAppend(rows); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	a, err := OpenAny(filename)
+	if err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+
+	row := new(AnyRow)
+	if err = a.ReadAt(3, row); err != nil {
+		t.Fatalf("failed to read row: %v", err)
+	}
+
+	expected := rows[3].Value
+	m := row.Map()
+	if len(m) != 11 || m["symbol"] != expected.Symbol || m["note"] != expected.Note || m["total"] != expected.Total ||
+		!reflect.DeepEqual(m["payload"], expected.Payload) || row.Get("delta") != expected.Delta || row.Get("missing") != nil {
+		t.Fatalf("unexpected row %v", m)
+	}
+
+	it := a.Iter()
+	n := 0
+	for item := range it.Next() {
+		if item.Value().Get("level") != rows[item.Key()].Value.Level {
+			t.Fatalf("unexpected row %d: %v", item.Key(), item.Value().Map())
+		}
+		n++
+	}
+
+	if it.Error() != nil || n != len(rows) {
+		t.Fatalf("iterated %d rows of %d: %v", n, len(rows), it.Error())
+	}
+
+	if err = a.Append(row); err == nil {
+		t.Fatalf("expected read-only error")
+	}
+
+	if err = a.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	w, err := Open[*AnyRow, AnyRow](filename)
+	if err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+
+	row.Values[row.Spec.Index("count")] = 5
+	if err = w.Append(row); err != nil {
+		t.Fatalf("failed to append any row: %v", err)
+	}
+
+	if err = w.Append(&AnyRow{Values: []any{"short"}}); err == nil {
+		t.Fatalf("expected value count error")
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if c, err = OpenRead[*exportRow, exportRow](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	typed := new(exportRow)
+	if err = c.ReadAt(10, typed); err != nil {
+		t.Fatalf("failed to read row: %v", err)
+	}
+
+	expected.Count = 5
+	if !reflect.DeepEqual(typed.Value, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, typed.Value)
+	}
+}
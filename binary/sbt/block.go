@@ -0,0 +1,682 @@
+package sbt
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBlockRows is the number of rows per block when not specified.
+const DefaultBlockRows uint32 = 4096
+
+// blockFrameHeaderSize is the size of a block frame header, an uint32 payload size and an uint32 row count,
+// followed by the uint32 CRC32C of the payload in checksummed files.
+const blockFrameHeaderSize = 8
+
+// blockFrameAppended flags the row count of a tail frame holding the rows following the tail frames before it.
+const blockFrameAppended uint32 = 1 << 31
+
+var ErrImmutableBlock = errors.New("can't overwrite rows of a written block")
+
+// BlockConfig configures the block layout of a Container file, stored in the header.
+type BlockConfig struct {
+	Rows        uint32      `json:"rows"`
+	Compression Compression `json:"compression"`
+}
+
+// blockEntry is the index entry of a block frame.
+type blockEntry struct {
+	offset int64
+	size   uint32
+}
+
+// blockLayout groups rows into fixed-count blocks, each stored as an independently compressed frame.
+//
+// A frame is the payload size and row count followed by the payload. The block index is rebuilt
+// from the frame headers on open, so any block can be read without decompressing the others.
+//
+// Appended rows are buffered in the tail block. Frames are only ever appended to the file, so a torn write never
+// loses flushed rows: a flush appends a frame of the rows appended since the last one, or of the whole tail if
+// flushed rows were overwritten, and a full tail is written as a new block frame. The tail frames it replaces stay
+// in the file until Compact. Rows of full blocks can't be overwritten.
+//
+// Columnar blocks store each column contiguously, the rows are transposed when writing
+// and reading frames. The tail block is always kept row-major in memory.
+//
+// Encrypted payloads are sealed after compression, with the block index and row count as additional data.
+//
+// mu guards the block index and the tail, cacheMu the decompressed block cache shared by readers.
+type blockLayout struct {
+	file       Storage
+	offset     int64
+	rowSize    int64
+	config     BlockConfig
+	compressor compressor
+
+	// columns holds the offset and size of each column in the row, nil for row-major blocks.
+	columns []columnSlice
+	// checksum is set if frames hold the CRC32C of their payload.
+	checksum    bool
+	frameHeader int64
+	// aead seals the payloads of encrypted files, nil otherwise.
+	aead cipher.AEAD
+
+	mu     sync.RWMutex
+	rows   atomic.Int64
+	blocks []blockEntry
+	end    int64
+
+	tail      []byte
+	tailDirty bool
+	// tailFlushed is the size of the tail rows in the tail frames, tailRewrite is set once one of them is overwritten.
+	tailFlushed int
+	tailRewrite bool
+	// tailOffset is the offset of the tail frames and tailFrames their size.
+	tailOffset int64
+	tailFrames int64
+	// superseded is the size of the tail frames replaced by later frames.
+	superseded int64
+	// trailing is the size of a torn frame after end, truncated by the next write if it's larger.
+	trailing int64
+
+	cacheMu    sync.Mutex
+	cacheIndex int
+	cache      []byte
+}
+
+// columnSlice is the offset and size of a column in the row.
+type columnSlice struct {
+	offset int64
+	size   int64
+}
+
+// newBlockLayout returns the block layout of file, whose content starts at offset.
+// Blocks are stored column by column with FlagColumnar, frames are checksummed with FlagChecksum
+// and payloads are sealed with aead if it's not nil.
+func newBlockLayout(
+	file Storage,
+	offset int64,
+	spec RowSpec,
+	config BlockConfig,
+	flags uint8,
+	aead cipher.AEAD,
+) (l *blockLayout, err error) {
+	l = &blockLayout{
+		file:        file,
+		offset:      offset,
+		rowSize:     int64(spec.RowSize()),
+		config:      config,
+		end:         offset,
+		cacheIndex:  -1,
+		checksum:    flags&FlagChecksum != 0,
+		frameHeader: blockFrameHeaderSize,
+		aead:        aead,
+	}
+
+	if l.checksum {
+		l.frameHeader += checksumSize
+	}
+
+	if flags&FlagColumnar != 0 {
+		if nulls := spec.NullBitmapSize(); nulls > 0 {
+			l.columns = append(l.columns, columnSlice{offset: 0, size: int64(nulls)})
+		}
+
+		for i, col := range spec {
+			l.columns = append(l.columns, columnSlice{offset: int64(spec.Offset(i)), size: int64(col.Size)})
+		}
+	}
+
+	if config.Rows == 0 || config.Rows >= blockFrameAppended {
+		err = fmt.Errorf("invalid block rows %d", config.Rows)
+		return
+	}
+
+	if l.compressor, err = newCompressor(config.Compression); err != nil {
+		return
+	}
+
+	if err = l.scan(); err != nil {
+		return
+	}
+
+	l.countRows()
+
+	return
+}
+
+// countRows stores the number of rows in the blocks and the tail.
+func (l *blockLayout) countRows() {
+	l.rows.Store(int64(len(l.blocks))*int64(l.config.Rows) + int64(len(l.tail))/l.rowSize)
+}
+
+// scan rebuilds the block index from the frame headers, loading the trailing tail frames as the tail.
+// A torn trailing frame, or a tail frame failing its checksum, is ignored and will be overwritten by the next write.
+func (l *blockLayout) scan() (err error) {
+	var fileSize int64
+	if fileSize, err = l.file.Size(); err != nil {
+		return fmt.Errorf("failed to get file size: %w", err)
+	}
+
+	header := make([]byte, l.frameHeader)
+
+	for l.end+l.frameHeader <= fileSize {
+		if _, err = l.file.ReadAt(header, l.end); err != nil {
+			return fmt.Errorf("failed to read block header at %d: %w", l.end, err)
+		}
+
+		size := binary.LittleEndian.Uint32(header)
+		rows := binary.LittleEndian.Uint32(header[4:])
+		appended := rows&blockFrameAppended != 0
+		rows &^= blockFrameAppended
+
+		frameSize := l.frameHeader + int64(size)
+		if l.end+frameSize > fileSize || rows == 0 || rows > l.config.Rows {
+			break
+		}
+
+		entry := blockEntry{offset: l.end, size: size}
+
+		var start uint32
+		if appended {
+			if start = uint32(int64(len(l.tail)) / l.rowSize); start == 0 || start+rows >= l.config.Rows {
+				break
+			}
+		}
+
+		if rows < l.config.Rows {
+			var b []byte
+			if b, err = l.readBlock(len(l.blocks), entry, start, rows); errors.Is(err, ErrCorrupted) {
+				err = nil
+				break
+			} else if err != nil {
+				return
+			}
+
+			if !appended {
+				l.superseded += l.tailFrames
+				l.tail, l.tailOffset, l.tailFrames = nil, l.end, 0
+			}
+
+			l.tail = append(l.tail, l.toRows(b)...)
+			l.tailFrames += frameSize
+			l.end += frameSize
+
+			continue
+		}
+
+		// a torn block frame is ignored if it replaces tail frames, which still hold its flushed rows
+		if l.tailFrames > 0 && l.end+frameSize == fileSize {
+			if _, err = l.readBlock(len(l.blocks), entry, 0, rows); errors.Is(err, ErrCorrupted) {
+				err = nil
+				break
+			} else if err != nil {
+				return
+			}
+		}
+
+		l.blocks = append(l.blocks, entry)
+		l.end += frameSize
+
+		l.superseded += l.tailFrames
+		l.tail, l.tailOffset, l.tailFrames = nil, l.end, 0
+	}
+
+	if l.tailFrames == 0 {
+		l.tailOffset = l.end
+	}
+
+	l.tailFlushed = len(l.tail)
+	l.trailing = fileSize - l.end
+
+	return
+}
+
+// readBlock reads and decompresses the frame of entry, the block at index, as stored.
+// start is the number of rows before the rows of an appended tail frame, 0 for other frames.
+func (l *blockLayout) readBlock(index int, entry blockEntry, start, rows uint32) (b []byte, err error) {
+	frame := make([]byte, l.frameHeader+int64(entry.size))
+	if _, err = l.file.ReadAt(frame, entry.offset); err != nil {
+		err = fmt.Errorf("failed to read block at %d: %w", entry.offset, err)
+		return
+	}
+
+	payload := frame[l.frameHeader:]
+
+	if l.checksum && binary.LittleEndian.Uint32(frame[blockFrameHeaderSize:]) != checksum(payload) {
+		err = fmt.Errorf("block at %d: checksum mismatch: %w", entry.offset, ErrCorrupted)
+		return
+	}
+
+	if l.aead != nil {
+		if payload, err = unseal(l.aead, payload, blockAD(index, frameAD(start, rows))); err != nil {
+			err = fmt.Errorf("failed to decrypt block at %d: %v: %w", entry.offset, err, ErrCorrupted)
+			return
+		}
+	}
+
+	size := int(rows) * int(l.rowSize)
+	if b, err = l.compressor.decompress(payload, size); err != nil {
+		err = fmt.Errorf("failed to decompress block at %d: %v: %w", entry.offset, err, ErrCorrupted)
+		return
+	}
+
+	if len(b) != size {
+		err = fmt.Errorf("invalid block size at %d: %d != %d: %w", entry.offset, len(b), size, ErrCorrupted)
+		return
+	}
+
+	return
+}
+
+// block returns the decompressed full block at index, as stored, caching the last one.
+func (l *blockLayout) block(index int) (b []byte, err error) {
+	l.cacheMu.Lock()
+	if index == l.cacheIndex {
+		b = l.cache
+	}
+	l.cacheMu.Unlock()
+
+	if b != nil {
+		return
+	}
+
+	if b, err = l.readBlock(index, l.blocks[index], 0, l.config.Rows); err != nil {
+		return
+	}
+
+	l.cacheMu.Lock()
+	l.cacheIndex, l.cache = index, b
+	l.cacheMu.Unlock()
+
+	return
+}
+
+// frameAD returns the row count authenticated with a frame, the end of the rows of an appended tail frame.
+func frameAD(start, rows uint32) uint32 {
+	if start > 0 {
+		return blockFrameAppended | (start + rows)
+	}
+
+	return rows
+}
+
+// writeFrame compresses rows into a frame at the end of the file, appending them to the tail frames
+// after start rows if start isn't 0. A larger torn frame there is truncated.
+func (l *blockLayout) writeFrame(rows []byte, start uint32) (frameSize int64, err error) {
+	count := uint32(int64(len(rows)) / l.rowSize)
+
+	var payload []byte
+	if payload, err = l.compressor.compress(l.toColumns(rows)); err != nil {
+		err = fmt.Errorf("failed to compress block: %w", err)
+		return
+	}
+
+	if l.aead != nil {
+		if payload, err = seal(l.aead, payload, blockAD(len(l.blocks), frameAD(start, count))); err != nil {
+			err = fmt.Errorf("failed to encrypt block: %w", err)
+			return
+		}
+	}
+
+	header := count
+	if start > 0 {
+		header |= blockFrameAppended
+	}
+
+	frame := make([]byte, l.frameHeader+int64(len(payload)))
+	binary.LittleEndian.PutUint32(frame, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:], header)
+	copy(frame[l.frameHeader:], payload)
+
+	if l.checksum {
+		binary.LittleEndian.PutUint32(frame[blockFrameHeaderSize:], checksum(payload))
+	}
+
+	if _, err = l.file.WriteAt(frame, l.end); err != nil {
+		err = fmt.Errorf("failed to write block: %w", err)
+		return
+	}
+
+	frameSize = int64(len(frame))
+
+	if l.trailing > frameSize {
+		if err = l.file.Truncate(l.end + frameSize); err != nil {
+			err = fmt.Errorf("failed to truncate file: %w", err)
+			return
+		}
+	}
+
+	l.trailing = 0
+
+	return
+}
+
+// toColumns transposes row-major rows into columnar block data, if the layout is columnar.
+func (l *blockLayout) toColumns(rows []byte) []byte {
+	if l.columns == nil {
+		return rows
+	}
+
+	n := int64(len(rows)) / l.rowSize
+	b := make([]byte, len(rows))
+
+	for _, col := range l.columns {
+		dst := b[n*col.offset:]
+		for r := int64(0); r < n; r++ {
+			copy(dst[r*col.size:(r+1)*col.size], rows[r*l.rowSize+col.offset:])
+		}
+	}
+
+	return b
+}
+
+// toRows transposes columnar block data back into row-major rows, if the layout is columnar.
+func (l *blockLayout) toRows(b []byte) []byte {
+	if l.columns == nil {
+		return b
+	}
+
+	rows := make([]byte, len(b))
+	l.gatherRows(b, int64(len(b))/l.rowSize, 0, rows)
+
+	return rows
+}
+
+// gatherRows copies len(dst)/rowSize rows starting at row pos out of the columnar data of a block of n rows.
+func (l *blockLayout) gatherRows(b []byte, n, pos int64, dst []byte) {
+	count := int64(len(dst)) / l.rowSize
+
+	for _, col := range l.columns {
+		src := b[n*col.offset+pos*col.size:]
+		for r := int64(0); r < count; r++ {
+			copy(dst[r*l.rowSize+col.offset:r*l.rowSize+col.offset+col.size], src[r*col.size:])
+		}
+	}
+}
+
+func (l *blockLayout) blockSize() int {
+	return int(l.config.Rows) * int(l.rowSize)
+}
+
+func (l *blockLayout) numRows() int64 {
+	return l.rows.Load()
+}
+
+func (l *blockLayout) size() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.end
+}
+
+// supersededSize returns the size of the tail frames replaced by later frames, dropped by Compact.
+func (l *blockLayout) supersededSize() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.superseded
+}
+
+func (l *blockLayout) readRows(pos int64, b []byte) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	blockRows := int64(l.config.Rows)
+	tailStart := int64(len(l.blocks)) * blockRows
+
+	for len(b) > 0 {
+		var n int
+
+		if pos >= tailStart {
+			n = copy(b, l.tail[(pos-tailStart)*l.rowSize:])
+		} else {
+			block, err := l.block(int(pos / blockRows))
+			if err != nil {
+				return err
+			}
+
+			if l.columns == nil {
+				n = copy(b, block[(pos%blockRows)*l.rowSize:])
+			} else {
+				rows := blockRows - pos%blockRows
+				if rows*l.rowSize > int64(len(b)) {
+					rows = int64(len(b)) / l.rowSize
+				}
+
+				n = int(rows * l.rowSize)
+				l.gatherRows(block, blockRows, pos%blockRows, b[:n])
+			}
+		}
+
+		if n == 0 {
+			return fmt.Errorf("row %d out of bounds", pos)
+		}
+
+		b = b[n:]
+		pos += int64(n) / l.rowSize
+	}
+
+	return nil
+}
+
+func (l *blockLayout) readColumn(pos int64, col columnSlice, b []byte) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	blockRows := int64(l.config.Rows)
+	tailStart := int64(len(l.blocks)) * blockRows
+
+	for len(b) > 0 {
+		var n int64
+
+		if pos >= tailStart {
+			n = strideCopy(b, l.tail[(pos-tailStart)*l.rowSize:], l.rowSize, col)
+		} else {
+			index := int(pos / blockRows)
+			start := pos % blockRows
+
+			if l.columns == nil {
+				block, err := l.block(index)
+				if err != nil {
+					return err
+				}
+
+				n = strideCopy(b, block[start*l.rowSize:], l.rowSize, col)
+			} else {
+				src, err := l.columnData(index, col, start, int64(len(b)))
+				if err != nil {
+					return err
+				}
+
+				n = int64(copy(b, src)) / col.size
+			}
+		}
+
+		if n == 0 {
+			return fmt.Errorf("row %d out of bounds", pos)
+		}
+
+		b = b[n*col.size:]
+		pos += n
+	}
+
+	return nil
+}
+
+// columnData returns up to max bytes of col from row start of the columnar block at index.
+// Uncompressed blocks are read straight from the file, without reading the other columns,
+// unless their checksum has to be verified or they're encrypted.
+func (l *blockLayout) columnData(index int, col columnSlice, start, max int64) (b []byte, err error) {
+	blockRows := int64(l.config.Rows)
+	from := blockRows*col.offset + start*col.size
+	size := (blockRows - start) * col.size
+	if size > max {
+		size = max
+	}
+
+	if _, ok := l.compressor.(noneCompressor); ok && !l.checksum && l.aead == nil {
+		b = make([]byte, size)
+		if _, err = l.file.ReadAt(b, l.blocks[index].offset+l.frameHeader+from); err != nil {
+			err = fmt.Errorf("failed to read block at %d: %w", l.blocks[index].offset, err)
+		}
+
+		return
+	}
+
+	var block []byte
+	if block, err = l.block(index); err != nil {
+		return
+	}
+
+	b = block[from : from+size]
+
+	return
+}
+
+func (l *blockLayout) writeRows(pos int64, b []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tailStart := int64(len(l.blocks)) * int64(l.config.Rows)
+	if pos < tailStart {
+		return ErrImmutableBlock
+	}
+
+	offset := (pos - tailStart) * l.rowSize
+	copy(l.tail[offset:], b)
+	l.tailDirty = true
+
+	if offset < int64(l.tailFlushed) {
+		l.tailRewrite = true
+	}
+
+	return nil
+}
+
+func (l *blockLayout) appendRows(b []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for len(b) > 0 {
+		n := l.blockSize() - len(l.tail)
+		if n > len(b) {
+			n = len(b)
+		}
+
+		l.tail = append(l.tail, b[:n]...)
+		l.tailDirty = true
+		l.rows.Add(int64(n) / l.rowSize)
+		b = b[n:]
+
+		if len(l.tail) < l.blockSize() {
+			continue
+		}
+
+		frameSize, err := l.writeFrame(l.tail, 0)
+		if err != nil {
+			return err
+		}
+
+		l.blocks = append(l.blocks, blockEntry{offset: l.end, size: uint32(frameSize - l.frameHeader)})
+		l.end += frameSize
+		l.tail = l.tail[:0]
+		l.tailDirty = false
+		l.tailFlushed = 0
+		l.tailRewrite = false
+
+		l.superseded += l.tailFrames
+		l.tailOffset, l.tailFrames = l.end, 0
+	}
+
+	return nil
+}
+
+func (l *blockLayout) verify() (r VerifyReport, err error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	r.Rows = l.numRows()
+
+	for i, entry := range l.blocks {
+		if _, err = l.readBlock(i, entry, 0, l.config.Rows); errors.Is(err, ErrCorrupted) {
+			r.addCorrupted(int64(i)*int64(l.config.Rows), int64(l.config.Rows))
+		} else if err != nil {
+			return
+		}
+	}
+
+	err = nil
+
+	var size int64
+	if size, err = l.file.Size(); err != nil {
+		err = fmt.Errorf("failed to get file size: %w", err)
+		return
+	}
+
+	r.PartialBytes = size - l.end
+
+	return
+}
+
+// refresh picks up the blocks written by another process, reloading the tail.
+// It's a no-op while appended rows are buffered, this process being the writer.
+func (l *blockLayout) refresh() (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.tailDirty {
+		return
+	}
+
+	// the tail frames are read again, followed by the frames written since
+	l.end = l.tailOffset
+	l.tail, l.tailFrames = nil, 0
+
+	if err = l.scan(); err != nil {
+		return
+	}
+
+	l.countRows()
+
+	return
+}
+
+func (l *blockLayout) flush() (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.tailDirty || len(l.tail) == 0 {
+		return
+	}
+
+	// only the appended rows are written, unless flushed ones were overwritten
+	start := l.tailFlushed
+	if l.tailRewrite {
+		start = 0
+	}
+
+	if start < len(l.tail) {
+		var frameSize int64
+		if frameSize, err = l.writeFrame(l.tail[start:], uint32(int64(start)/l.rowSize)); err != nil {
+			return
+		}
+
+		if start == 0 {
+			l.superseded += l.tailFrames
+			l.tailOffset, l.tailFrames = l.end, 0
+		}
+
+		l.end += frameSize
+		l.tailFrames += frameSize
+	}
+
+	l.tailFlushed = len(l.tail)
+	l.tailRewrite = false
+	l.tailDirty = false
+
+	return
+}
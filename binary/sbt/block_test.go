@@ -0,0 +1,237 @@
+package sbt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendTestRows(t *testing.T, c *Container[*TestRow, TestRow], from, count int) {
+	rows := make([]*TestRow, count)
+	for i := range rows {
+		rows[i] = &TestRow{Symbol: "BTCUSDT", Price: uint32(from + i)}
+	}
+
+	if err := c.BulkAppend(rows); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+}
+
+func checkTestRows(t *testing.T, c *Container[*TestRow, TestRow], count int) {
+	if c.NumRows() != int64(count) {
+		t.Fatalf("expected %d rows, got %d", count, c.NumRows())
+	}
+
+	for _, pos := range []int64{0, 99, 100, 555, int64(count - 1)} {
+		row := new(TestRow)
+		if err := c.ReadAt(pos, row); err != nil {
+			t.Fatalf("failed to read row %d: %v", pos, err)
+		}
+
+		if row.Price != uint32(pos) || row.Symbol != "BTCUSDT" {
+			t.Fatalf("unexpected row %d: %+v", pos, row)
+		}
+	}
+
+	rows := make([]*TestRow, count-50)
+	for i := range rows {
+		rows[i] = new(TestRow)
+	}
+
+	if _, err := c.BulkRead(50, rows); err != nil {
+		t.Fatalf("failed to bulk read rows: %v", err)
+	}
+
+	for i, row := range rows {
+		if row.Price != uint32(50+i) {
+			t.Fatalf("unexpected row %d: %+v", 50+i, row)
+		}
+	}
+
+	it := c.IterBucketSize(Bucket100 + 7)
+	defer it.Close()
+
+	n := 0
+	for range it.Next() {
+		n++
+	}
+
+	if it.Error() != nil || n != count {
+		t.Fatalf("iterated %d rows of %d: %v", n, count, it.Error())
+	}
+}
+
+func TestBlockCompression(t *testing.T) {
+	for _, compression := range []Compression{CompressionNone, CompressionFlate, CompressionZstd, CompressionSnappy} {
+		t.Run(string(compression), func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "block.sbt")
+
+			c, err := Create[*TestRow, TestRow](filename, WithBlockCompression(compression, 100))
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+
+			appendTestRows(t, c, 0, 1050)
+
+			if err = c.Set(&TestRow{Symbol: "BTCUSDT", Price: 1049}, 1049); err != nil {
+				t.Fatalf("failed to set tail row: %v", err)
+			}
+
+			if err = c.Set(&TestRow{}, 0); !errors.Is(err, ErrImmutableBlock) {
+				t.Fatalf("expected immutable block error, got %v", err)
+			}
+
+			checkTestRows(t, c, 1050)
+
+			if err = c.Close(); err != nil {
+				t.Fatalf("failed to close container: %v", err)
+			}
+
+			if c, err = Open[*TestRow, TestRow](filename); err != nil {
+				t.Fatalf("failed to open container: %v", err)
+			}
+
+			if c.Flags()&FlagBlocks == 0 || c.Block().Compression != compression {
+				t.Fatalf("unexpected flags %x or block config %+v", c.Flags(), c.Block())
+			}
+
+			checkTestRows(t, c, 1050)
+			appendTestRows(t, c, 1050, 100)
+
+			if err = c.Close(); err != nil {
+				t.Fatalf("failed to close container: %v", err)
+			}
+
+			if c, err = OpenRead[*TestRow, TestRow](filename); err != nil {
+				t.Fatalf("failed to open container: %v", err)
+			}
+			defer c.Close()
+
+			checkTestRows(t, c, 1150)
+		})
+	}
+}
+
+func TestBlockTornWrites(t *testing.T) {
+	for name, options := range map[string][]Option{
+		"snappy":    {WithBlockCompression(CompressionSnappy, 100)},
+		"checksum":  {WithBlockCompression(CompressionNone, 100), WithChecksum()},
+		"encrypted": {WithBlockCompression(CompressionZstd, 100), WithColumnar(), WithEncryption(testEncryptionKey)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "block.sbt")
+
+			reopen := func(c *Container[*TestRow, TestRow], torn int64) *Container[*TestRow, TestRow] {
+				if err := c.Close(); err != nil {
+					t.Fatalf("failed to close container: %v", err)
+				}
+
+				if torn > 0 {
+					info, err := os.Stat(filename)
+					if err != nil {
+						t.Fatalf("failed to stat file: %v", err)
+					}
+
+					if err = os.Truncate(filename, info.Size()-torn); err != nil {
+						t.Fatalf("failed to truncate file: %v", err)
+					}
+				}
+
+				c, err := Open[*TestRow, TestRow](filename, options...)
+				if err != nil {
+					t.Fatalf("failed to open container: %v", err)
+				}
+
+				return c
+			}
+
+			check := func(c *Container[*TestRow, TestRow], count int, symbol string) {
+				if c.NumRows() != int64(count) {
+					t.Fatalf("expected %d rows, got %d", count, c.NumRows())
+				}
+
+				for i := 0; i < count; i++ {
+					row := new(TestRow)
+					if err := c.ReadAt(int64(i), row); err != nil {
+						t.Fatalf("failed to read row %d: %v", i, err)
+					}
+
+					expected := TestRow{Symbol: "BTCUSDT", Price: uint32(i)}
+					if i == 3 && symbol != "" {
+						expected.Symbol = symbol
+					}
+
+					if *row != expected {
+						t.Fatalf("row %d: expected %+v, got %+v", i, expected, row)
+					}
+				}
+			}
+
+			c, err := Create[*TestRow, TestRow](filename, options...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+
+			// a torn appended frame leaves the rows flushed before it
+			appendTestRows(t, c, 0, 10)
+			if err = c.Flush(); err != nil {
+				t.Fatalf("failed to flush: %v", err)
+			}
+
+			appendTestRows(t, c, 10, 5)
+			c = reopen(c, 2)
+			check(c, 10, "")
+
+			appendTestRows(t, c, 10, 5)
+			c = reopen(c, 0)
+			check(c, 15, "")
+
+			// overwriting a flushed row rewrites the whole tail, a torn rewrite leaves the previous frames
+			if err = c.Set(&TestRow{Symbol: "ETHUSDT", Price: 3}, 3); err != nil {
+				t.Fatalf("failed to set row: %v", err)
+			}
+
+			c = reopen(c, 2)
+			check(c, 15, "")
+
+			if err = c.Set(&TestRow{Symbol: "ETHUSDT", Price: 3}, 3); err != nil {
+				t.Fatalf("failed to set row: %v", err)
+			}
+
+			c = reopen(c, 0)
+			check(c, 15, "ETHUSDT")
+
+			// a torn block frame leaves the tail frames it replaces
+			appendTestRows(t, c, 15, 85)
+			c = reopen(c, 2)
+			check(c, 15, "ETHUSDT")
+
+			appendTestRows(t, c, 15, 90)
+			c = reopen(c, 0)
+			check(c, 105, "ETHUSDT")
+
+			report, err := c.Verify()
+			if err != nil || !report.OK() {
+				t.Fatalf("unexpected report %+v: %v", report, err)
+			}
+
+			// Compact drops the replaced tail frames
+			size := c.Size()
+			if err = c.Compact(); err != nil {
+				t.Fatalf("failed to compact: %v", err)
+			}
+
+			if c.Size() >= size {
+				t.Fatalf("expected compaction to shrink the file from %d bytes, got %d", size, c.Size())
+			}
+
+			check(c, 105, "ETHUSDT")
+
+			c = reopen(c, 0)
+			defer c.Close()
+
+			check(c, 105, "ETHUSDT")
+		})
+	}
+}
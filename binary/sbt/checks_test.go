@@ -0,0 +1,194 @@
+package sbt
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+type testCheckedRow struct {
+	encode func(e *Encoder)
+	decode func(d *Decoder)
+}
+
+func (r *testCheckedRow) Factory() Row {
+	return new(testCheckedRow)
+}
+
+func (r *testCheckedRow) Columns() RowSpec {
+	return NewRowSpec(
+		ColumnTypeUInt32.New("A"),
+		ColumnTypeUInt16.New("B"),
+	)
+}
+
+func (r *testCheckedRow) Encode(ctx *Encoder) error {
+	if r.encode != nil {
+		r.encode(ctx)
+	} else {
+		ctx.EncodeUInt32(1)
+		ctx.EncodeUInt16(2)
+	}
+
+	return nil
+}
+
+func (r *testCheckedRow) Decode(ctx *Decoder) error {
+	if r.decode != nil {
+		r.decode(ctx)
+	} else {
+		ctx.DecodeUInt32()
+		ctx.DecodeUInt16()
+	}
+
+	return nil
+}
+
+func TestChecks(t *testing.T) {
+	for name, test := range map[string]struct {
+		mode   CheckMode
+		encode func(e *Encoder)
+		err    error
+	}{
+		"valid": {
+			mode: CheckColumns,
+		},
+		"overflow": {
+			mode:   CheckBounds,
+			encode: func(e *Encoder) { e.EncodeUInt32(1); e.EncodeUInt32(2) },
+			err:    ErrOverflow,
+		},
+		"short": {
+			mode:   CheckBounds,
+			encode: func(e *Encoder) { e.EncodeUInt32(1) },
+			err:    ErrRowSize,
+		},
+		"unchecked type": {
+			mode:   CheckBounds,
+			encode: func(e *Encoder) { e.EncodeInt32(1); e.EncodeInt16(2) },
+		},
+		"type": {
+			mode:   CheckColumns,
+			encode: func(e *Encoder) { e.EncodeInt32(1); e.EncodeUInt16(2) },
+			err:    ErrColumnMismatch,
+		},
+		"size": {
+			mode:   CheckColumns,
+			encode: func(e *Encoder) { e.EncodeUInt16(1); e.EncodeUInt16(2); e.EncodeUInt16(3) },
+			err:    ErrColumnMismatch,
+		},
+		"no heap": {
+			mode:   CheckColumns,
+			encode: func(e *Encoder) { e.EncodeVarString("a") },
+			err:    ErrNoHeap,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := Create[*testCheckedRow, testCheckedRow](filepath.Join(t.TempDir(), "checked.sbt"), WithChecks(test.mode))
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer c.Close()
+
+			if err = c.Append(&testCheckedRow{encode: test.encode}); !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+
+			expected := int64(1)
+			if test.err != nil {
+				expected = 0
+			}
+
+			if c.NumRows() != expected {
+				t.Fatalf("expected %d rows, got %d", expected, c.NumRows())
+			}
+		})
+	}
+}
+
+func TestDecoderChecks(t *testing.T) {
+	c, err := Create[*testCheckedRow, testCheckedRow](filepath.Join(t.TempDir(), "checked.sbt"), WithChecks(CheckColumns))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	defer c.Close()
+
+	if err = c.Append(new(testCheckedRow)); err != nil {
+		t.Fatalf("failed to append row: %v", err)
+	}
+
+	if err = c.ReadAt(0, new(testCheckedRow)); err != nil {
+		t.Fatalf("failed to read row: %v", err)
+	}
+
+	for _, test := range []struct {
+		decode func(d *Decoder)
+		err    error
+	}{
+		{func(d *Decoder) { d.DecodeUInt32() }, ErrRowSize},
+		{func(d *Decoder) { d.DecodeInt32(); d.DecodeUInt16() }, ErrColumnMismatch},
+		{func(d *Decoder) { d.DecodeUInt32(); d.DecodeUInt16(); d.DecodeBool() }, ErrOverflow},
+	} {
+		if err = c.ReadAt(0, &testCheckedRow{decode: test.decode}); !errors.Is(err, test.err) {
+			t.Fatalf("expected %v, got %v", test.err, err)
+		}
+	}
+
+	values, err := ReadColumn[uint16](c, "B", 0, 1)
+	if err != nil || values[0] != 2 {
+		t.Fatalf("unexpected column values %v: %v", values, err)
+	}
+}
+
+func TestChecksTypedRows(t *testing.T) {
+	dir := t.TempDir()
+
+	typed, err := Create[*testTypedRow, testTypedRow](filepath.Join(dir, "typed.sbt"), WithChecks(CheckColumns))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	defer typed.Close()
+
+	rows := testTypedRows(10)
+	if err = typed.BulkAppend(rows); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	for i, expected := range rows {
+		row := new(testTypedRow)
+		if err = typed.ReadAt(int64(i), row); err != nil {
+			t.Fatalf("failed to read row: %v", err)
+		}
+
+		checkTypedRow(t, row, expected)
+	}
+
+	nullable, err := Create[*testNullableRow, testNullableRow](filepath.Join(dir, "nullable.sbt"), WithChecks(CheckColumns))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	nullableRows := testNullableRows(10)
+	if err = nullable.BulkAppend(nullableRows); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	if err = nullable.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	a, err := Open[*AnyRow, AnyRow](filepath.Join(dir, "nullable.sbt"), WithChecks(CheckColumns))
+	if err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer a.Close()
+
+	row := new(AnyRow)
+	if err = a.ReadAt(0, row); err != nil || row.Get("Note") != "note" {
+		t.Fatalf("unexpected row %v: %v", row.Values, err)
+	}
+
+	if err = a.Append(row); err != nil {
+		t.Fatalf("failed to append any row: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package sbt
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// checksumSize is the size of a stored CRC32C.
+const checksumSize = 4
+
+var ErrCorrupted = errors.New("corrupted content")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// checksum returns the CRC32C of b.
+func checksum(b []byte) uint32 {
+	return crc32.Checksum(b, castagnoli)
+}
+
+// RowRange is a range of Count rows starting at Start.
+type RowRange struct {
+	Start int64
+	Count int64
+}
+
+// VerifyReport is the result of Container.Verify.
+type VerifyReport struct {
+	// Rows is the number of verified rows.
+	Rows int64
+	// Corrupted lists the row ranges failing their checksum, or whose block can't be decompressed.
+	Corrupted []RowRange
+	// PartialBytes is the size of a partially written trailing row or block frame, removed by Repair.
+	PartialBytes int64
+}
+
+// OK reports whether no corruption was found.
+func (r VerifyReport) OK() bool {
+	return len(r.Corrupted) == 0 && r.PartialBytes == 0
+}
+
+// appendRowRange appends a range to ranges, merging it with the last one if adjacent.
+func appendRowRange(ranges []RowRange, start, count int64) []RowRange {
+	if n := len(ranges); n > 0 && ranges[n-1].Start+ranges[n-1].Count == start {
+		ranges[n-1].Count += count
+		return ranges
+	}
+
+	return append(ranges, RowRange{Start: start, Count: count})
+}
+
+// addCorrupted adds a corrupted range, merging it with the previous one if adjacent.
+func (r *VerifyReport) addCorrupted(start, count int64) {
+	r.Corrupted = appendRowRange(r.Corrupted, start, count)
+}
+
+// Verify checks the content of the Container file.
+//
+// Rows are checked against their checksums if the file was created WithChecksum, compressed blocks are
+// always checked to decompress. A partially written trailing row or block is reported in any case.
+func (c *Container[P, RowType]) Verify() (r VerifyReport, err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err = c.flush(); err != nil {
+		return
+	}
+
+	if r, err = c.layout.verify(); err != nil {
+		err = fmt.Errorf("failed to verify content: %w", err)
+		return
+	}
+
+	return
+}
+
+// Repair truncates a partially written trailing row or block left by a crash, so appends are safe.
+//
+// Rows failing their checksum are not repaired, they are reported by Verify and fail to be read.
+func (c *Container[P, RowType]) Repair() (err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err = c.checkWritable(); err != nil {
+		return
+	}
+
+	if err = c.flush(); err != nil {
+		return
+	}
+
+	if err = c.storage.Truncate(c.layout.size()); err != nil {
+		err = fmt.Errorf("failed to truncate file: %w", err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,154 @@
+package sbt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// corruptByte flips a byte of filename at offset, negative offsets being relative to the end.
+func corruptByte(t *testing.T, filename string, offset int64) {
+	f, err := os.OpenFile(filename, os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	if offset < 0 {
+		stat, _ := f.Stat()
+		offset += stat.Size()
+	}
+
+	b := make([]byte, 1)
+	if _, err = f.ReadAt(b, offset); err != nil {
+		t.Fatalf("failed to read byte: %v", err)
+	}
+
+	b[0] ^= 0xFF
+	if _, err = f.WriteAt(b, offset); err != nil {
+		t.Fatalf("failed to write byte: %v", err)
+	}
+}
+
+func appendGarbage(t *testing.T, filename string, n int) {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err = f.Write(make([]byte, n)); err != nil {
+		t.Fatalf("failed to append garbage: %v", err)
+	}
+}
+
+func TestChecksumRows(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "checksum.sbt")
+
+	c, err := Create[*TestRow, TestRow](filename, WithChecksum())
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	appendTestRows(t, c, 0, 300)
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	stride := int64(new(TestRow).Columns().RowSize()) + checksumSize
+	corruptByte(t, filename, -(300-10)*stride)
+	corruptByte(t, filename, -(300-11)*stride+3)
+	corruptByte(t, filename, -(300-200)*stride)
+	appendGarbage(t, filename, 5)
+
+	if c, err = Open[*TestRow, TestRow](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	report, err := c.Verify()
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+
+	expected := VerifyReport{Rows: 300, Corrupted: []RowRange{{10, 2}, {200, 1}}, PartialBytes: 5}
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if err = c.ReadAt(11, new(TestRow)); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected corruption error, got %v", err)
+	}
+
+	if err = c.Repair(); err != nil {
+		t.Fatalf("failed to repair: %v", err)
+	}
+
+	appendTestRows(t, c, 300, 1)
+
+	row := new(TestRow)
+	if err = c.ReadAt(300, row); err != nil || row.Price != 300 {
+		t.Fatalf("failed to read appended row %+v: %v", row, err)
+	}
+
+	if report, err = c.Verify(); err != nil || report.PartialBytes != 0 || report.Rows != 301 {
+		t.Fatalf("unexpected report %+v: %v", report, err)
+	}
+}
+
+func TestChecksumBlocks(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "checksum.sbt")
+
+	c, err := Create[*TestRow, TestRow](filename, WithChecksum(), WithBlockCompression(CompressionNone, 100))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	appendTestRows(t, c, 0, 250)
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	rowSize := int64(new(TestRow).Columns().RowSize())
+	frameHeader := int64(blockFrameHeaderSize + checksumSize)
+	tailFrame := frameHeader + 50*rowSize
+
+	// second full block
+	corruptByte(t, filename, -tailFrame-10)
+	appendGarbage(t, filename, 3)
+
+	if c, err = Open[*TestRow, TestRow](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	report, err := c.Verify()
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+
+	expected := VerifyReport{Rows: 250, Corrupted: []RowRange{{100, 100}}, PartialBytes: 3}
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if err = c.ReadAt(150, new(TestRow)); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected corruption error, got %v", err)
+	}
+
+	if err = c.ReadAt(50, new(TestRow)); err != nil {
+		t.Fatalf("failed to read intact row: %v", err)
+	}
+
+	if err = c.Repair(); err != nil {
+		t.Fatalf("failed to repair: %v", err)
+	}
+
+	if report, err = c.Verify(); err != nil || report.PartialBytes != 0 {
+		t.Fatalf("unexpected report %+v: %v", report, err)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/difof/goul/binary/sbt"
+	"github.com/difof/goul/fs"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+const usage = `Usage: sbtctl <command> [flags] <file>
+
+Commands:
+  head    print the first rows (-n)
+  tail    print the last rows (-n)
+  count   print the number of live rows
+  schema  print the header and the columns
+  verify  check checksums and trailing partial rows
+  slice   print the rows in [-from, -to)
+  export  write the rows as csv, jsonl or parquet (-format, -o)
+
+Run 'sbtctl <command> -h' for the flags of a command.
+`
+
+// errVerify is returned by verify when the file is corrupted, after the report is printed.
+var errVerify = errors.New("verification failed")
+
+type container = sbt.Container[*sbt.AnyRow, sbt.AnyRow]
+
+// run runs the command in args, writing its output to out.
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n\n%s", usage)
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() {
+		fmt.Fprintf(out, "Usage: sbtctl %s [flags] <file>\n", args[0])
+		flags.PrintDefaults()
+	}
+
+	var cmd func(c *container, out io.Writer) error
+
+	switch args[0] {
+	case "head", "tail":
+		n := flags.Int64("n", 10, "number of rows")
+		cmd = func(c *container, out io.Writer) error {
+			if args[0] == "head" {
+				return head(c, out, *n)
+			}
+
+			return tail(c, out, *n)
+		}
+	case "count":
+		cmd = func(c *container, out io.Writer) (err error) {
+			_, err = fmt.Fprintln(out, c.NumRows()-c.NumDeleted())
+			return
+		}
+	case "schema":
+		cmd = schema
+	case "verify":
+		cmd = verify
+	case "slice":
+		from := flags.Int64("from", 0, "first row")
+		to := flags.Int64("to", -1, "end row, exclusive; defaults to the number of rows")
+		cmd = func(c *container, out io.Writer) error {
+			return slice(c, out, *from, *to)
+		}
+	case "export":
+		format := flags.String("format", string(sbt.ExportCSV), "csv, jsonl or parquet")
+		output := flags.String("o", "", "output file; defaults to stdout")
+		cmd = func(c *container, out io.Writer) error {
+			return export(c, out, sbt.ExportFormat(*format), *output)
+		}
+	case "help", "-h", "-help", "--help":
+		_, err := fmt.Fprint(out, usage)
+		return err
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected a single file")
+	}
+
+	c, cleanup, err := openFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return cmd(c, out)
+}
+
+// openFile opens filename with sbt.OpenAny, decompressing .gz files and their sidecars into a temporary directory.
+func openFile(filename string) (c *container, cleanup func(), err error) {
+	cleanup = func() {}
+
+	if strings.HasSuffix(filename, ".gz") {
+		var dir string
+		if dir, err = os.MkdirTemp("", "sbtctl"); err != nil {
+			return
+		}
+
+		cleanup = func() { os.RemoveAll(dir) }
+
+		original := strings.TrimSuffix(filename, ".gz")
+		extracted := filepath.Join(dir, filepath.Base(original))
+
+		if err = fs.ExtractGZipFileTo(filename, extracted); err != nil {
+			cleanup()
+			return
+		}
+
+		for _, ext := range sbt.SidecarExtensions() {
+			if !fs.Exists(original + ext + ".gz") {
+				continue
+			}
+
+			if err = fs.ExtractGZipFileTo(original+ext+".gz", extracted+ext); err != nil {
+				cleanup()
+				return
+			}
+		}
+
+		filename = extracted
+	}
+
+	if c, err = sbt.OpenAny(filename); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	removeDir := cleanup
+	cleanup = func() {
+		c.Close()
+		removeDir()
+	}
+
+	return
+}
+
+func head(c *container, out io.Writer, n int64) error {
+	var positions []int64
+	for pos := int64(0); pos < c.NumRows() && int64(len(positions)) < n; pos++ {
+		if !c.IsDeleted(pos) {
+			positions = append(positions, pos)
+		}
+	}
+
+	return printRows(c, out, positions)
+}
+
+func tail(c *container, out io.Writer, n int64) error {
+	var positions []int64
+	for pos := c.NumRows() - 1; pos >= 0 && int64(len(positions)) < n; pos-- {
+		if !c.IsDeleted(pos) {
+			positions = append(positions, pos)
+		}
+	}
+
+	for i, j := 0, len(positions)-1; i < j; i, j = i+1, j-1 {
+		positions[i], positions[j] = positions[j], positions[i]
+	}
+
+	return printRows(c, out, positions)
+}
+
+func slice(c *container, out io.Writer, from, to int64) error {
+	if to < 0 || to > c.NumRows() {
+		to = c.NumRows()
+	}
+
+	if from < 0 || from > to {
+		return fmt.Errorf("invalid range [%d, %d) of %d rows", from, to, c.NumRows())
+	}
+
+	var positions []int64
+	for pos := from; pos < to; pos++ {
+		if !c.IsDeleted(pos) {
+			positions = append(positions, pos)
+		}
+	}
+
+	return printRows(c, out, positions)
+}
+
+// printRows prints the rows at positions as a table.
+func printRows(c *container, out io.Writer, positions []int64) error {
+	t := table.NewWriter()
+	t.SetOutputMirror(out)
+
+	header := table.Row{"#"}
+	for _, column := range c.Header() {
+		header = append(header, fmt.Sprintf("%s (%s.%d)", column.Name, column.Type, column.Size))
+	}
+	t.AppendHeader(header)
+
+	row := new(sbt.AnyRow)
+	for _, pos := range positions {
+		if err := c.ReadAt(pos, row); err != nil {
+			return fmt.Errorf("failed to read row %d: %w", pos, err)
+		}
+
+		cells := table.Row{pos}
+		for _, v := range row.Values {
+			switch value := v.(type) {
+			case nil:
+				v = "NULL"
+			case []byte:
+				v = base64.StdEncoding.EncodeToString(value)
+			}
+
+			cells = append(cells, v)
+		}
+		t.AppendRow(cells)
+	}
+
+	t.Render()
+
+	return nil
+}
+
+func schema(c *container, out io.Writer) (err error) {
+	fmt.Fprintf(out, "version: %d\nflags: %#02x\nrows: %d\ndeleted: %d\nsize: %d\n",
+		c.Version(), c.Flags(), c.NumRows(), c.NumDeleted(), c.Size())
+
+	if block := c.Block(); block != nil {
+		fmt.Fprintf(out, "block: %d rows, %s compression\n", block.Rows, block.Compression)
+	}
+
+	if key := c.Key(); key != nil {
+		fmt.Fprintf(out, "key: %s (monotonic: %v, index rows: %d)\n", key.Column, key.Monotonic, key.IndexRows)
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(out)
+	t.AppendHeader(table.Row{"#", "Name", "Type", "Size"})
+
+	for i, column := range c.Header() {
+		t.AppendRow(table.Row{i, column.Name, column.Type, column.Size})
+	}
+
+	t.AppendFooter(table.Row{"", "Row size", "", c.Header().RowSize()})
+	t.Render()
+
+	return
+}
+
+func verify(c *container, out io.Writer) (err error) {
+	var report sbt.VerifyReport
+	if report, err = c.Verify(); err != nil {
+		return
+	}
+
+	fmt.Fprintf(out, "rows: %d\n", report.Rows)
+
+	for _, r := range report.Corrupted {
+		fmt.Fprintf(out, "corrupted: rows [%d, %d)\n", r.Start, r.Start+r.Count)
+	}
+
+	if report.PartialBytes > 0 {
+		fmt.Fprintf(out, "partial: %d trailing bytes\n", report.PartialBytes)
+	}
+
+	if !report.OK() {
+		return errVerify
+	}
+
+	_, err = fmt.Fprintln(out, "ok")
+
+	return
+}
+
+func export(c *container, out io.Writer, format sbt.ExportFormat, output string) (err error) {
+	if output == "" {
+		return c.Export(out, format)
+	}
+
+	var f *os.File
+	if f, err = os.Create(output); err != nil {
+		return
+	}
+
+	if err = c.Export(f, format); err != nil {
+		f.Close()
+		return
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/difof/goul/binary/sbt"
+	"github.com/difof/goul/fs"
+)
+
+type trade struct {
+	Symbol string `sbt:"symbol,str,8"`
+	Price  uint32 `sbt:"price"`
+	Note   string `sbt:"note,vstr"`
+}
+
+type tradeRow = sbt.StructRow[trade]
+
+func createTestFile(t *testing.T) string {
+	filename := filepath.Join(t.TempDir(), "trades.sbt")
+
+	c, err := sbt.Create[*tradeRow, tradeRow](filename, sbt.WithChecksum())
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		if err = c.Append(sbt.NewStructRow(trade{Symbol: "BTCUSDT", Price: uint32(i), Note: "note"})); err != nil {
+			t.Fatalf("failed to append row: %v", err)
+		}
+	}
+
+	if err = c.Delete(1); err != nil {
+		t.Fatalf("failed to delete row: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	return filename
+}
+
+func runTest(t *testing.T, args ...string) string {
+	var out bytes.Buffer
+	if err := run(args, &out); err != nil {
+		t.Fatalf("%v failed: %v\n%s", args, err, out.String())
+	}
+
+	return out.String()
+}
+
+func TestCommands(t *testing.T) {
+	filename := createTestFile(t)
+
+	if out := runTest(t, "count", filename); out != "19\n" {
+		t.Fatalf("unexpected count %q", out)
+	}
+
+	out := runTest(t, "head", "-n", "2", filename)
+	if !strings.Contains(out, "PRICE (U32.4)") || !strings.Contains(out, "| 2 | BTCUSDT") || strings.Contains(out, "| 1 |") {
+		t.Fatalf("unexpected head:\n%s", out)
+	}
+
+	if out = runTest(t, "tail", "-n", "1", filename); !strings.Contains(out, "| 19 | BTCUSDT") || strings.Contains(out, "| 18 |") {
+		t.Fatalf("unexpected tail:\n%s", out)
+	}
+
+	if out = runTest(t, "slice", "-from", "5", "-to", "7", filename); strings.Count(out, "BTCUSDT") != 2 {
+		t.Fatalf("unexpected slice:\n%s", out)
+	}
+
+	if out = runTest(t, "schema", filename); !strings.Contains(out, "rows: 20") || !strings.Contains(out, "| note") {
+		t.Fatalf("unexpected schema:\n%s", out)
+	}
+
+	if out = runTest(t, "verify", filename); !strings.HasSuffix(out, "ok\n") {
+		t.Fatalf("unexpected verify:\n%s", out)
+	}
+
+	if out = runTest(t, "export", "-format", "jsonl", filename); strings.Count(out, "\n") != 19 ||
+		!strings.Contains(out, `{"note":"note","price":0,"symbol":"BTCUSDT"}`) {
+		t.Fatalf("unexpected export:\n%s", out)
+	}
+
+	for _, f := range append(sbt.Sidecars(filename), filename) {
+		if err := fs.EasyGzip(f); err != nil {
+			t.Fatalf("failed to compress %s: %v", f, err)
+		}
+
+		if err := os.Remove(f); err != nil {
+			t.Fatalf("failed to remove %s: %v", f, err)
+		}
+	}
+
+	if out = runTest(t, "export", "-format", "csv", filename+".gz"); strings.Count(out, "\n") != 20 || !strings.HasPrefix(out, "symbol,price,note\n") {
+		t.Fatalf("unexpected compressed export:\n%s", out)
+	}
+}
+
+func TestVerifyCorrupted(t *testing.T) {
+	filename := createTestFile(t)
+
+	f, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+
+	info, _ := f.Stat()
+	if _, err = f.WriteAt([]byte{0xFF, 0xFF}, info.Size()-3); err != nil {
+		t.Fatalf("failed to corrupt file: %v", err)
+	}
+	f.Close()
+
+	var out bytes.Buffer
+	if err = run([]string{"verify", filename}, &out); !errors.Is(err, errVerify) || !strings.Contains(out.String(), "corrupted: rows [19, 20)") {
+		t.Fatalf("expected verification failure, got %v:\n%s", err, out.String())
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	if err := run([]string{"nope"}, new(bytes.Buffer)); err == nil {
+		t.Fatalf("expected unknown command error")
+	}
+
+	if err := run([]string{"count"}, new(bytes.Buffer)); err == nil {
+		t.Fatalf("expected missing file error")
+	}
+}
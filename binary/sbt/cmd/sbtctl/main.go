@@ -0,0 +1,22 @@
+// Command sbtctl inspects sbt files without their Go row type.
+//
+// Rows are decoded with the RowSpec stored in the file header, see sbt.OpenAny.
+// Files compressed by the MultiContainer archive manager (.sbt.gz) are decompressed
+// into a temporary directory along with their sidecars.
+//
+//	sbtctl head -n 20 trades.sbt
+//	sbtctl slice -from 100 -to 200 trades.sbt.gz
+//	sbtctl export -format csv trades.sbt > trades.csv
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "sbtctl: %v\n", err)
+		os.Exit(1)
+	}
+}
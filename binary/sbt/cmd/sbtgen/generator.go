@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/difof/goul/binary/sbt"
+)
+
+// RowMarker is the doc comment line marking a struct for generation when no type is specified.
+const RowMarker = "//sbt:row"
+
+// columnCodec describes how a column type is written and read with Encoder and Decoder.
+type columnCodec struct {
+	constant string // sbt ColumnType constant name
+	goType   string // Go type the Encoder method accepts
+	method   string // Encoder/Decoder method suffix
+	width    int    // bytes written by the Encoder method, 0 for padded types
+}
+
+var columnCodecs = map[sbt.ColumnType]columnCodec{
+	sbt.ColumnTypeString:  {"ColumnTypeString", "string", "StringPadded", 0},
+	sbt.ColumnTypeBinary:  {"ColumnTypeBinary", "[]byte", "BytesPadded", 0},
+	sbt.ColumnTypeBool:    {"ColumnTypeBool", "bool", "Bool", 1},
+	sbt.ColumnTypeInt8:    {"ColumnTypeInt8", "int8", "Int8", 1},
+	sbt.ColumnTypeInt16:   {"ColumnTypeInt16", "int16", "Int16", 2},
+	sbt.ColumnTypeInt32:   {"ColumnTypeInt32", "int32", "Int32", 4},
+	sbt.ColumnTypeInt64:   {"ColumnTypeInt64", "int64", "Int64", 8},
+	sbt.ColumnTypeUInt8:   {"ColumnTypeUInt8", "uint8", "UInt8", 1},
+	sbt.ColumnTypeUInt16:  {"ColumnTypeUInt16", "uint16", "UInt16", 2},
+	sbt.ColumnTypeUInt32:  {"ColumnTypeUInt32", "uint32", "UInt32", 4},
+	sbt.ColumnTypeUInt64:  {"ColumnTypeUInt64", "uint64", "UInt64", 8},
+	sbt.ColumnTypeFloat32: {"ColumnTypeFloat32", "float32", "Float32", 4},
+	sbt.ColumnTypeFloat64: {"ColumnTypeFloat64", "float64", "Float64", 8},
+	sbt.ColumnTypeTime:    {"ColumnTypeTime", "time.Time", "Timestamp", 8},
+
+	sbt.ColumnTypeVarString: {"ColumnTypeVarString", "string", "VarString", sbt.HeapRefSize},
+	sbt.ColumnTypeVarBinary: {"ColumnTypeVarBinary", "[]byte", "VarBytes", sbt.HeapRefSize},
+}
+
+// builtinColumnTypes maps Go types to their natural column type.
+var builtinColumnTypes = map[string]sbt.ColumnType{
+	"bool":      sbt.ColumnTypeBool,
+	"int8":      sbt.ColumnTypeInt8,
+	"int16":     sbt.ColumnTypeInt16,
+	"int32":     sbt.ColumnTypeInt32,
+	"int64":     sbt.ColumnTypeInt64,
+	"int":       sbt.ColumnTypeInt64,
+	"uint8":     sbt.ColumnTypeUInt8,
+	"byte":      sbt.ColumnTypeUInt8,
+	"uint16":    sbt.ColumnTypeUInt16,
+	"uint32":    sbt.ColumnTypeUInt32,
+	"uint64":    sbt.ColumnTypeUInt64,
+	"uint":      sbt.ColumnTypeUInt64,
+	"float32":   sbt.ColumnTypeFloat32,
+	"float64":   sbt.ColumnTypeFloat64,
+	"string":    sbt.ColumnTypeString,
+	"[]byte":    sbt.ColumnTypeBinary,
+	"time.Time": sbt.ColumnTypeInt64,
+}
+
+// rowField is a struct field mapped to a column.
+type rowField struct {
+	name   string
+	goType string
+	array  bool
+	column sbt.Column
+	codec  columnCodec
+}
+
+// rowType is a struct to generate Row methods for.
+type rowType struct {
+	name   string
+	fields []rowField
+}
+
+// hasHeap reports whether any field is stored in the heap.
+func (r rowType) hasHeap() bool {
+	for _, f := range r.fields {
+		if f.column.Type.IsVariable() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Generator collects row structs of a package and emits their Row methods.
+type Generator struct {
+	pkgName string
+	rows    []rowType
+}
+
+// ParseDir parses the non-test Go files of dir and collects the given struct types.
+// If types is empty, structs with a RowMarker doc comment line are collected.
+func ParseDir(dir string, types []string) (g *Generator, err error) {
+	fset := token.NewFileSet()
+
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(dir); err != nil {
+		err = fmt.Errorf("failed to read %s: %w", dir, err)
+		return
+	}
+
+	wanted := map[string]bool{}
+	for _, t := range types {
+		wanted[t] = true
+	}
+
+	g = &Generator{}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		var file *ast.File
+		if file, err = parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments); err != nil {
+			err = fmt.Errorf("failed to parse %s: %w", name, err)
+			return
+		}
+
+		if g.pkgName == "" {
+			g.pkgName = file.Name.Name
+		} else if g.pkgName != file.Name.Name {
+			err = fmt.Errorf("multiple packages in %s: %s, %s", dir, g.pkgName, file.Name.Name)
+			return
+		}
+
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+
+				if len(types) > 0 && !wanted[ts.Name.Name] {
+					continue
+				}
+
+				if len(types) == 0 && !hasMarker(gen.Doc) && !hasMarker(ts.Doc) {
+					continue
+				}
+
+				var row rowType
+				if row, err = newRowType(ts.Name.Name, st); err != nil {
+					return
+				}
+
+				delete(wanted, ts.Name.Name)
+				g.rows = append(g.rows, row)
+			}
+		}
+	}
+
+	for name := range wanted {
+		err = fmt.Errorf("struct type %s not found in %s", name, dir)
+		return
+	}
+
+	if len(g.rows) == 0 {
+		err = fmt.Errorf("no row structs found in %s", dir)
+		return
+	}
+
+	return
+}
+
+// hasMarker reports whether the doc comment group contains RowMarker.
+func hasMarker(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == RowMarker {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newRowType maps the fields of a struct to columns.
+func newRowType(name string, st *ast.StructType) (row rowType, err error) {
+	row.name = name
+
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 {
+			err = fmt.Errorf("%s: embedded fields are not supported", name)
+			return
+		}
+
+		var tag sbt.StructTag
+		if field.Tag != nil {
+			var raw string
+			if raw, err = strconv.Unquote(field.Tag.Value); err != nil {
+				err = fmt.Errorf("%s: invalid tag %s: %w", name, field.Tag.Value, err)
+				return
+			}
+
+			if tag, err = sbt.ParseStructTag(reflect.StructTag(raw).Get(sbt.StructTagKey)); err != nil {
+				err = fmt.Errorf("%s: %w", name, err)
+				return
+			}
+		}
+
+		if tag.Skip {
+			continue
+		}
+
+		for _, ident := range field.Names {
+			if !ident.IsExported() {
+				continue
+			}
+
+			var rf rowField
+			if rf, err = newRowField(ident.Name, field.Type, tag); err != nil {
+				err = fmt.Errorf("%s.%s: %w", name, ident.Name, err)
+				return
+			}
+
+			row.fields = append(row.fields, rf)
+		}
+	}
+
+	if len(row.fields) == 0 {
+		err = fmt.Errorf("%s has no columns", name)
+	}
+
+	return
+}
+
+// newRowField resolves the column of a single field, pointer fields are nullable columns of their element type.
+func newRowField(name string, expr ast.Expr, tag sbt.StructTag) (rf rowField, err error) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		if rf, err = newRowField(name, star.X, tag); err == nil && rf.column.Nullable {
+			err = fmt.Errorf("unsupported type %s", exprString(expr))
+		}
+
+		rf.column = rf.column.AsNullable()
+		return
+	}
+
+	rf.name = name
+	rf.goType = exprString(expr)
+
+	typ, size := tag.Type, tag.Size
+
+	inferred, known := builtinColumnTypes[rf.goType]
+	if arr, ok := expr.(*ast.ArrayType); ok && arr.Len != nil && (exprString(arr.Elt) == "byte" || exprString(arr.Elt) == "uint8") {
+		lit, ok := arr.Len.(*ast.BasicLit)
+		if !ok {
+			err = fmt.Errorf("array length of %s must be a literal", rf.goType)
+			return
+		}
+
+		var n uint64
+		if n, err = strconv.ParseUint(lit.Value, 0, 32); err != nil {
+			err = fmt.Errorf("invalid array length of %s: %w", rf.goType, err)
+			return
+		}
+
+		inferred, known, rf.array = sbt.ColumnTypeBinary, true, true
+		if size == 0 {
+			size = uint32(n)
+		}
+	}
+
+	switch {
+	case typ == "" && !known:
+		err = fmt.Errorf("unsupported type %s, specify the column type in the sbt tag", rf.goType)
+		return
+	case typ == "":
+		typ = inferred
+	case typ == sbt.ColumnTypeTime && rf.goType != "time.Time":
+		err = fmt.Errorf("column type %s requires a time.Time field", typ)
+		return
+	case known && typ != inferred && !(isBlob(typ) && isBlob(inferred)) && typ != sbt.ColumnTypeTime:
+		err = fmt.Errorf("column type %s is not compatible with %s", typ, rf.goType)
+		return
+	}
+
+	var ok bool
+	if rf.codec, ok = columnCodecs[typ]; !ok {
+		err = fmt.Errorf("unknown column type %s", typ)
+		return
+	}
+
+	if size > 0 {
+		rf.column = sbt.NewColumn(tag.Name, typ, size)
+	} else if typ == sbt.ColumnTypeTime {
+		rf.column = sbt.NewTimeColumn(tag.Name, tag.Precision)
+	} else {
+		rf.column = sbt.NewColumn(tag.Name, typ)
+	}
+
+	if rf.column.Name == "" {
+		rf.column.Name = name
+	}
+
+	if typ.IsVariable() && (rf.array || rf.goType != rf.codec.goType) {
+		err = fmt.Errorf("column type %s requires a %s field", typ, rf.codec.goType)
+		return
+	}
+
+	if rf.codec.width > 0 && int(rf.column.Size) != rf.codec.width {
+		err = fmt.Errorf("column type %s can't have size %d", typ, rf.column.Size)
+		return
+	}
+
+	return
+}
+
+func isBlob(t sbt.ColumnType) bool {
+	return t == sbt.ColumnTypeString || t == sbt.ColumnTypeBinary || t.IsVariable()
+}
+
+// exprString renders a field type expression.
+func exprString(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return exprString(e.X) + "." + e.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprString(e.X)
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return "[]" + exprString(e.Elt)
+		}
+		if lit, ok := e.Len.(*ast.BasicLit); ok {
+			return "[" + lit.Value + "]" + exprString(e.Elt)
+		}
+		return "[...]" + exprString(e.Elt)
+	}
+
+	return fmt.Sprintf("%T", expr)
+}
+
+// width returns the number of bytes written by the field's Encoder call.
+func (f rowField) width() int {
+	if f.codec.width > 0 {
+		return f.codec.width
+	}
+
+	return int(f.column.Size)
+}
+
+// value returns the expression of the field value, dereferenced if nullable.
+func (f rowField) value() string {
+	if f.column.Nullable {
+		return "*r." + f.name
+	}
+
+	return "r." + f.name
+}
+
+// operand returns value as the operand of a slice expression.
+func (f rowField) operand() string {
+	if f.column.Nullable {
+		return "(" + f.value() + ")"
+	}
+
+	return f.value()
+}
+
+// encodeStmt returns the Encoder call of the field, writing nil fields of nullable columns as null.
+func (f rowField) encodeStmt() string {
+	if !f.column.Nullable {
+		return f.encodeValueStmt()
+	}
+
+	return fmt.Sprintf("if r.%s == nil {\n\t\tif err := ctx.EncodeNull(); err != nil {\n\t\t\treturn err\n\t\t}\n"+
+		"\t} else {\n\t\t%s\n\t}", f.name, f.encodeValueStmt())
+}
+
+// encodeValueStmt returns the Encoder call of the field value.
+func (f rowField) encodeValueStmt() string {
+	value := f.value()
+
+	switch {
+	case f.column.Type == sbt.ColumnTypeTime:
+		return fmt.Sprintf("ctx.EncodeTimestamp(%s, %d)", value, f.column.Precision)
+	case f.goType == "time.Time":
+		return fmt.Sprintf("ctx.EncodeTime(%s)", value)
+	case f.array:
+		return fmt.Sprintf("ctx.EncodeBytesPadded(%s[:], %d)", f.operand(), f.column.Size)
+	case f.codec.width == 0:
+		if f.goType != f.codec.goType {
+			value = fmt.Sprintf("%s(%s)", f.codec.goType, value)
+		}
+		return fmt.Sprintf("ctx.Encode%s(%s, %d)", f.codec.method, value, f.column.Size)
+	}
+
+	if f.goType != f.codec.goType {
+		value = fmt.Sprintf("%s(%s)", f.codec.goType, value)
+	}
+
+	return fmt.Sprintf("ctx.Encode%s(%s)", f.codec.method, value)
+}
+
+// decodeStmt returns the Decoder call of the field, setting fields of null values to nil.
+func (f rowField) decodeStmt(col int) string {
+	if !f.column.Nullable {
+		return f.decodeValueStmt()
+	}
+
+	return fmt.Sprintf("r.%s = new(%s)\n\t%s\n\tif ctx.IsNull(%d) {\n\t\tr.%s = nil\n\t}",
+		f.name, f.goType, f.decodeValueStmt(), col, f.name)
+}
+
+// decodeValueStmt returns the Decoder call of the field value.
+func (f rowField) decodeValueStmt() string {
+	target := f.value()
+
+	switch {
+	case f.column.Type.IsVariable():
+		return fmt.Sprintf("if %s, err = ctx.Decode%s(); err != nil {\n\t\treturn err\n\t}", target, f.codec.method)
+	case f.column.Type == sbt.ColumnTypeTime:
+		return fmt.Sprintf("%s = ctx.DecodeTimestamp(%d)", target, f.column.Precision)
+	case f.goType == "time.Time":
+		return fmt.Sprintf("%s = ctx.DecodeTime()", target)
+	case f.array:
+		return fmt.Sprintf("copy(%s[:], ctx.DecodeBytes(%d))", f.operand(), f.column.Size)
+	case f.codec.method == "BytesPadded" && f.goType == "[]byte":
+		return fmt.Sprintf("%s = append(%s[:0], ctx.DecodeBytes(%d)...)", target, f.operand(), f.column.Size)
+	case f.codec.method == "BytesPadded":
+		return fmt.Sprintf("%s = %s(append([]byte(nil), ctx.DecodeBytes(%d)...))", target, f.goType, f.column.Size)
+	}
+
+	var value string
+	if f.codec.width == 0 {
+		value = fmt.Sprintf("ctx.Decode%s(%d)", f.codec.method, f.column.Size)
+	} else {
+		value = fmt.Sprintf("ctx.Decode%s()", f.codec.method)
+	}
+
+	if f.goType != f.codec.goType {
+		value = fmt.Sprintf("%s(%s)", f.goType, value)
+	}
+
+	return fmt.Sprintf("%s = %s", target, value)
+}
+
+// Generate returns the formatted Go source of the Row methods.
+func (g *Generator) Generate() ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	fmt.Fprintf(buf, "// Code generated by sbtgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "package %s\n\n", g.pkgName)
+	fmt.Fprintf(buf, "import \"github.com/difof/goul/binary/sbt\"\n")
+
+	for _, row := range g.rows {
+		g.generateRow(buf, row)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w\n%s", err, buf.String())
+	}
+
+	return src, nil
+}
+
+func (g *Generator) generateRow(buf *bytes.Buffer, row rowType) {
+	recv := row.name
+
+	lower := strings.ToLower(recv[:1]) + recv[1:]
+
+	// both sizes start with the null bitmap, reserved by the Encoder
+	nullable := 0
+	for _, f := range row.fields {
+		if f.column.Nullable {
+			nullable++
+		}
+	}
+
+	var sizes, widths []string
+	if nullable > 0 {
+		sizes = append(sizes, strconv.Itoa((nullable+7)/8))
+		widths = append(widths, strconv.Itoa((nullable+7)/8))
+	}
+
+	for _, f := range row.fields {
+		sizes = append(sizes, strconv.Itoa(int(f.column.Size)))
+		widths = append(widths, strconv.Itoa(f.width()))
+	}
+
+	fmt.Fprintf(buf, "\nvar _ sbt.Row = (*%s)(nil)\n", recv)
+
+	fmt.Fprintf(buf, "\n// %sRowSize is the RowSpec.RowSize of %s.\n", lower, recv)
+	fmt.Fprintf(buf, "const %sRowSize = %s\n", lower, strings.Join(sizes, " + "))
+	fmt.Fprintf(buf, "\n// %sEncodedSize is the number of bytes written by %s.Encode.\n", lower, recv)
+	fmt.Fprintf(buf, "const %sEncodedSize = %s\n", lower, strings.Join(widths, " + "))
+	fmt.Fprintf(buf, "\n// fails to compile if %s.Encode doesn't write exactly RowSpec.RowSize bytes.\n", recv)
+	fmt.Fprintf(buf, "var _ = [1]struct{}{}[%sRowSize-%sEncodedSize]\n", lower, lower)
+
+	fmt.Fprintf(buf, "\n// Factory\nfunc (r *%s) Factory() sbt.Row {\n\treturn new(%s)\n}\n", recv, recv)
+
+	fmt.Fprintf(buf, "\n// Columns\nfunc (r *%s) Columns() sbt.RowSpec {\n\treturn sbt.NewRowSpec(\n", recv)
+	for _, f := range row.fields {
+		var nullable string
+		if f.column.Nullable {
+			nullable = ".AsNullable()"
+		}
+
+		if f.column.Type == sbt.ColumnTypeTime {
+			fmt.Fprintf(buf, "\t\tsbt.NewTimeColumn(%q, %d)%s,\n", f.column.Name, f.column.Precision, nullable)
+			continue
+		}
+
+		fmt.Fprintf(buf, "\t\tsbt.%s.New(%q, %d)%s,\n", f.codec.constant, f.column.Name, f.column.Size, nullable)
+	}
+	fmt.Fprintf(buf, "\t)\n}\n")
+
+	fmt.Fprintf(buf, "\n// Encode\nfunc (r *%s) Encode(ctx *sbt.Encoder) error {\n", recv)
+	for _, f := range row.fields {
+		fmt.Fprintf(buf, "\t%s\n", f.encodeStmt())
+	}
+	fmt.Fprintf(buf, "\n\treturn nil\n}\n")
+
+	fmt.Fprintf(buf, "\n// Decode\nfunc (r *%s) Decode(ctx *sbt.Decoder) error {\n", recv)
+	if row.hasHeap() {
+		fmt.Fprintf(buf, "\tvar err error\n\n")
+	}
+	for i, f := range row.fields {
+		fmt.Fprintf(buf, "\t%s\n", f.decodeStmt(i))
+	}
+	fmt.Fprintf(buf, "\n\treturn nil\n}\n")
+}
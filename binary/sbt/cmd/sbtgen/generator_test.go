@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// roundTripTest checks the generated Trade methods against a Container with checked encoding.
+const roundTripTest = `package rows
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/difof/goul/binary/sbt"
+)
+
+func TestRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "trades.sbt")
+	ts := time.Unix(1714564800, 123456789)
+
+	expected := Trade{
+		Symbol:   "BTCUSDT",
+		Price:    42.5,
+		Volume:   7,
+		Side:     2,
+		Time:     ts,
+		Created:  ts,
+		Checksum: [4]byte{1, 2, 3, 4},
+		Payload:  bytes.Repeat([]byte{9}, 16),
+		Count:    -3,
+		Note:     "variable length note",
+		Bid:      new(float64),
+		Tag:      &[4]byte{5, 6, 7, 8},
+	}
+	*expected.Bid = 41.5
+
+	if uint32(tradeRowSize) != new(Trade).Columns().RowSize() {
+		t.Fatalf("expected row size %d, got %d", new(Trade).Columns().RowSize(), tradeRowSize)
+	}
+
+	c, err := sbt.Create[*Trade, Trade](filename, sbt.WithChecks(sbt.CheckColumns))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.Append(&expected); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err = sbt.Open[*Trade, Trade](filename, sbt.WithChecks(sbt.CheckColumns)); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	got := new(Trade)
+	if err = c.ReadAt(0, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Symbol != expected.Symbol || got.Price != expected.Price || got.Volume != expected.Volume ||
+		got.Side != expected.Side || !got.Time.Equal(ts) || !got.Created.Equal(ts.Truncate(time.Millisecond)) ||
+		got.Checksum != expected.Checksum || !bytes.Equal(got.Payload, expected.Payload) ||
+		got.Count != expected.Count || got.Note != expected.Note || got.Bid == nil || *got.Bid != *expected.Bid ||
+		got.Tag == nil || *got.Tag != *expected.Tag || got.Memo != nil {
+		t.Fatalf("expected %+v, got %+v", expected, *got)
+	}
+}
+`
+
+// TestGeneratedRoundTrip builds the code generated for testdata and round-trips a row through a Container.
+func TestGeneratedRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the generated code")
+	}
+
+	g, err := ParseDir("testdata", nil)
+	if err != nil {
+		t.Fatalf("failed to parse testdata: %v", err)
+	}
+
+	src, err := g.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate: %v", err)
+	}
+
+	rows, err := os.ReadFile(filepath.Join("testdata", "rows.go"))
+	if err != nil {
+		t.Fatalf("failed to read testdata: %v", err)
+	}
+
+	// the package is built inside the module, so sbt resolves without downloads
+	dir, err := os.MkdirTemp(".", "_roundtrip")
+	if err != nil {
+		t.Fatalf("failed to create package: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for name, content := range map[string][]byte{
+		"rows.go":           rows,
+		"trade_sbt.go":      src,
+		"roundtrip_test.go": []byte(roundTripTest),
+	} {
+		if err = os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	for _, args := range [][]string{{"vet", "."}, {"test", "-count=1", "."}} {
+		cmd := exec.Command(filepath.Join(runtime.GOROOT(), "bin", "go"), args...)
+		cmd.Dir = dir
+
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("go %s failed: %v\n%s\n%s", strings.Join(args, " "), err, out, src)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	g, err := ParseDir("testdata", nil)
+	if err != nil {
+		t.Fatalf("failed to parse testdata: %v", err)
+	}
+
+	if len(g.rows) != 1 || g.rows[0].name != "Trade" {
+		t.Fatalf("expected only the marked Trade struct, got %+v", g.rows)
+	}
+
+	src, err := g.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate: %v", err)
+	}
+
+	for _, expected := range []string{
+		"package rows",
+		`sbt.ColumnTypeString.New("symbol", 12)`,
+		`sbt.ColumnTypeInt64.New("Count", 8)`,
+		"ctx.EncodeUInt8(uint8(r.Side))",
+		"r.Side = Side(ctx.DecodeUInt8())",
+		"copy(r.Checksum[:], ctx.DecodeBytes(4))",
+		"r.Time = ctx.DecodeTime()",
+		`sbt.NewTimeColumn("created", 3)`,
+		"ctx.EncodeTimestamp(r.Created, 3)",
+		"r.Created = ctx.DecodeTimestamp(3)",
+		"ctx.EncodeVarString(r.Note)",
+		"if r.Note, err = ctx.DecodeVarString(); err != nil {",
+		"var _ = [1]struct{}{}[tradeRowSize-tradeEncodedSize]",
+		`sbt.ColumnTypeFloat64.New("Bid", 8).AsNullable()`,
+		"ctx.EncodeBytesPadded((*r.Tag)[:], 4)",
+		"if ctx.IsNull(12) {",
+	} {
+		if !strings.Contains(string(src), expected) {
+			t.Fatalf("generated code is missing %q:\n%s", expected, src)
+		}
+	}
+
+	if strings.Contains(string(src), "Internal") || strings.Contains(string(src), "private") {
+		t.Fatalf("generated code contains skipped fields:\n%s", src)
+	}
+}
+
+func TestGenerateMissingType(t *testing.T) {
+	if _, err := ParseDir("testdata", []string{"Missing"}); err == nil {
+		t.Fatalf("expected missing type error")
+	}
+}
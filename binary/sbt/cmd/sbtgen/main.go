@@ -0,0 +1,62 @@
+// Command sbtgen generates sbt.Row methods for plain structs.
+//
+// It emits Factory, Columns, Encode and Decode methods calling the concrete
+// Encoder and Decoder functions, avoiding the reflection cost of sbt.StructRow.
+// Fields are mapped the same way as sbt.StructRow, using the `sbt:"name,type,size"` tag,
+// and pointer fields are mapped to nullable columns, nil being null.
+//
+// Structs are selected with -type, or by a "//sbt:row" line in their doc comment:
+//
+//	//go:generate go run github.com/difof/goul/binary/sbt/cmd/sbtgen -type Trade,Quote
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; defaults to structs marked with "+RowMarker)
+	output := flag.String("output", "", "output file name; defaults to <dir>/<type>_sbt.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sbtgen [flags] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	var types []string
+	if *typeNames != "" {
+		types = strings.Split(*typeNames, ",")
+	}
+
+	if err := run(dir, types, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "sbtgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, types []string, output string) error {
+	g, err := ParseDir(dir, types)
+	if err != nil {
+		return err
+	}
+
+	src, err := g.Generate()
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = filepath.Join(dir, strings.ToLower(g.rows[0].name)+"_sbt.go")
+	}
+
+	return os.WriteFile(output, src, 0644)
+}
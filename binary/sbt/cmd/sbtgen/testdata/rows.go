@@ -0,0 +1,28 @@
+package rows
+
+import "time"
+
+type Side uint8
+
+//sbt:row
+type Trade struct {
+	Symbol   string    `sbt:"symbol,str,12"`
+	Price    float64   `sbt:"price"`
+	Volume   uint32    `sbt:"volume"`
+	Side     Side      `sbt:"side,u8"`
+	Time     time.Time `sbt:"time"`
+	Created  time.Time `sbt:"created,time,3"`
+	Checksum [4]byte   `sbt:"checksum"`
+	Payload  []byte    `sbt:"payload,bin,16"`
+	Count    int
+	Note     string `sbt:"note,vstr"`
+	Bid      *float64
+	Tag      *[4]byte `sbt:"tag"`
+	Memo     *string  `sbt:"memo,vstr"`
+	Internal string   `sbt:"-"`
+	private  int
+}
+
+type Ignored struct {
+	Value int
+}
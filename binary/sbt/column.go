@@ -1,5 +1,12 @@
 package sbt
 
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNotNullable = errors.New("column isn't nullable")
+
 type ColumnType string
 
 const (
@@ -16,22 +23,72 @@ const (
 	ColumnTypeUInt64  ColumnType = "u64"
 	ColumnTypeFloat32 ColumnType = "f32"
 	ColumnTypeFloat64 ColumnType = "f64"
+
+	// ColumnTypeVarString is a variable-length string stored in the heap.
+	ColumnTypeVarString ColumnType = "vstr"
+	// ColumnTypeVarBinary is a variable-length binary stored in the heap.
+	ColumnTypeVarBinary ColumnType = "vbin"
+
+	// ColumnTypeTime is a timestamp stored as an int64 count of 10^-Precision seconds since the unix epoch.
+	ColumnTypeTime ColumnType = "time"
+	// ColumnTypeDecimal is a fixed-point decimal stored as an int64 count of 10^-Scale.
+	ColumnTypeDecimal ColumnType = "decimal"
+	// ColumnTypeUUID is a 16 bytes UUID.
+	ColumnTypeUUID ColumnType = "uuid"
+	// ColumnTypeIPv4 is a 4 bytes IPv4 address in network byte order.
+	ColumnTypeIPv4 ColumnType = "ipv4"
+	// ColumnTypeIPv6 is a 16 bytes IPv6 address in network byte order, IPv4 addresses being IPv4-mapped.
+	ColumnTypeIPv6 ColumnType = "ipv6"
 )
 
-func (c ColumnType) New(name string, size ...uint8) Column {
+// IsVariable reports whether the column type stores its payload in the heap.
+func (c ColumnType) IsVariable() bool {
+	return c == ColumnTypeVarString || c == ColumnTypeVarBinary
+}
+
+func (c ColumnType) New(name string, size ...uint32) Column {
 	return NewColumn(name, c, size...)
 }
 
 type Column struct {
 	Name string     `json:"name"`
 	Type ColumnType `json:"type"`
-	Size uint8      `json:"size"`
+	Size uint32     `json:"size"`
+	// Precision is the number of fractional second digits of time columns.
+	Precision uint8 `json:"precision,omitempty"`
+	// Scale is the number of digits after the decimal point of decimal columns.
+	Scale uint8 `json:"scale,omitempty"`
+	// Nullable columns have a bit in the null bitmap prefixed to every row.
+	Nullable bool `json:"nullable,omitempty"`
+}
+
+// AsNullable returns a nullable copy of the column.
+func (c Column) AsNullable() Column {
+	c.Nullable = true
+	return c
+}
+
+// NewTimeColumn creates a time column storing 10^-precision seconds, e.g. 3 for milliseconds.
+func NewTimeColumn(name string, precision uint8) Column {
+	c := NewColumn(name, ColumnTypeTime)
+	c.Precision = precision
+
+	return c
+}
+
+// NewDecimalColumn creates a decimal column with scale digits after the decimal point.
+func NewDecimalColumn(name string, scale uint8) Column {
+	c := NewColumn(name, ColumnTypeDecimal)
+	c.Scale = scale
+
+	return c
 }
 
 // NewColumn creates a new column.
 //
 // If size is not specified, it will be calculated based on the type.
-func NewColumn(name string, typ ColumnType, size ...uint8) (c Column) {
+// Time columns default to nanosecond precision and decimal columns to a scale of 0.
+func NewColumn(name string, typ ColumnType, size ...uint32) (c Column) {
 	c = Column{
 		Name: name,
 		Type: typ,
@@ -67,8 +124,43 @@ func NewColumn(name string, typ ColumnType, size ...uint8) (c Column) {
 			c.Size = 4
 		case ColumnTypeFloat64:
 			c.Size = 8
+		case ColumnTypeVarString, ColumnTypeVarBinary:
+			c.Size = HeapRefSize
+		case ColumnTypeTime, ColumnTypeDecimal:
+			c.Size = 8
+		case ColumnTypeUUID, ColumnTypeIPv6:
+			c.Size = 16
+		case ColumnTypeIPv4:
+			c.Size = 4
 		}
 	}
 
+	if typ == ColumnTypeTime {
+		c.Precision = MaxTimePrecision
+	}
+
 	return
 }
+
+// validate checks the size, precision and scale of columns with fixed-size types.
+func (c Column) validate() error {
+	switch c.Type {
+	case ColumnTypeTime:
+		if c.Precision > MaxTimePrecision {
+			return fmt.Errorf("column %q: time precision %d exceeds %d", c.Name, c.Precision, MaxTimePrecision)
+		}
+	case ColumnTypeDecimal:
+		if c.Scale > MaxDecimalScale {
+			return fmt.Errorf("column %q: decimal scale %d exceeds %d", c.Name, c.Scale, MaxDecimalScale)
+		}
+	case ColumnTypeUUID, ColumnTypeIPv4, ColumnTypeIPv6:
+	default:
+		return nil
+	}
+
+	if size := NewColumn(c.Name, c.Type).Size; c.Size != size {
+		return fmt.Errorf("column %q: %s must have size %d, got %d", c.Name, c.Type, size, c.Size)
+	}
+
+	return nil
+}
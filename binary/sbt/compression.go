@@ -0,0 +1,137 @@
+package sbt
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the codec used to compress blocks.
+type Compression string
+
+const (
+	CompressionNone   Compression = "none"
+	CompressionFlate  Compression = "flate"
+	CompressionZstd   Compression = "zstd"
+	CompressionSnappy Compression = "snappy"
+)
+
+// compressor compresses and decompresses whole blocks.
+type compressor interface {
+	compress(src []byte) ([]byte, error)
+	decompress(src []byte, size int) ([]byte, error)
+}
+
+// newCompressor returns the compressor of c.
+func newCompressor(c Compression) (compressor, error) {
+	switch c {
+	case CompressionNone, "":
+		return noneCompressor{}, nil
+	case CompressionFlate:
+		return flateCompressor{}, nil
+	case CompressionZstd:
+		return zstdCompressor{}, nil
+	case CompressionSnappy:
+		return snappyCompressor{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown compression %q", c)
+}
+
+type noneCompressor struct{}
+
+func (noneCompressor) compress(src []byte) ([]byte, error) {
+	return append([]byte(nil), src...), nil
+}
+
+func (noneCompressor) decompress(src []byte, _ int) ([]byte, error) {
+	return src, nil
+}
+
+type flateCompressor struct{}
+
+func (flateCompressor) compress(src []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	w, err := flate.NewWriter(buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(src); err != nil {
+		return nil, err
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (flateCompressor) decompress(src []byte, size int) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+
+	dst := make([]byte, size)
+	if _, err := io.ReadFull(r, dst); err != nil {
+		return nil, err
+	}
+
+	return dst, nil
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// zstdCodecs lazily creates the shared zstd encoder and decoder, both are safe for concurrent use.
+func zstdCodecs() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+type zstdCompressor struct{}
+
+func (zstdCompressor) compress(src []byte) ([]byte, error) {
+	enc, _, err := zstdCodecs()
+	if err != nil {
+		return nil, err
+	}
+
+	return enc.EncodeAll(src, nil), nil
+}
+
+func (zstdCompressor) decompress(src []byte, size int) ([]byte, error) {
+	_, dec, err := zstdCodecs()
+	if err != nil {
+		return nil, err
+	}
+
+	return dec.DecodeAll(src, make([]byte, 0, size))
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) compress(src []byte) ([]byte, error) {
+	return snappy.Encode(nil, src), nil
+}
+
+func (snappyCompressor) decompress(src []byte, size int) ([]byte, error) {
+	return snappy.Decode(make([]byte, size), src)
+}
@@ -0,0 +1,229 @@
+package sbt
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentReadWrite(t *testing.T) {
+	for name, options := range map[string][]Option{
+		"rows":   {WithKeyColumn("Quantity")},
+		"blocks": {WithBlockCompression(CompressionSnappy, 64), WithSparseIndex("Quantity", 32)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := Create[*testRowV2, testRowV2](filepath.Join(t.TempDir(), "concurrent.sbt"), options...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer c.Close()
+
+			const batches, batchSize = 100, 25
+
+			done := make(chan struct{})
+			errs := make(chan error, 16)
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				defer close(done)
+
+				for b := 0; b < batches; b++ {
+					rows := make([]*testRowV2, batchSize)
+					for i := range rows {
+						n := b*batchSize + i
+						rows[i] = &testRowV2{Price: uint32(n), Symbol: "SYM", Quantity: uint64(n)}
+					}
+
+					if err := c.BulkAppend(rows); err != nil {
+						errs <- err
+						return
+					}
+
+					if b%10 == 0 {
+						if err := c.Flush(); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}
+			}()
+
+			check := func(row *testRowV2, pos int64) bool {
+				return row.Price == uint32(pos) && row.Quantity == uint64(pos) && row.Symbol == "SYM"
+			}
+
+			for r := 0; r < 4; r++ {
+				wg.Add(1)
+
+				go func(r int) {
+					defer wg.Done()
+
+					for {
+						select {
+						case <-done:
+							return
+						default:
+						}
+
+						n := c.NumRows()
+						if n == 0 {
+							continue
+						}
+
+						pos := (n * int64(r+1) / 5) % n
+
+						row := new(testRowV2)
+						if err := c.ReadAt(pos, row); err != nil || !check(row, pos) {
+							errs <- fmt.Errorf("row %d %+v: %v", pos, row, err)
+							return
+						}
+
+						rows := []*testRowV2{new(testRowV2)}
+						if _, err := c.BulkRead(n-1, rows); err != nil || !check(rows[0], n-1) {
+							errs <- fmt.Errorf("bulk row %d %+v: %v", n-1, rows[0], err)
+							return
+						}
+
+						if first, err := c.SearchFirst(int64(pos)); err != nil || first != pos {
+							errs <- fmt.Errorf("search %d got %d: %v", pos, first, err)
+							return
+						}
+
+						it := c.IterBucketSize(Bucket100)
+						count := int64(0)
+						for item := range it.Next() {
+							if !check(item.Value(), item.Key()) {
+								it.Close()
+								errs <- fmt.Errorf("iterated row %d %+v", item.Key(), item.Value())
+								return
+							}
+							count++
+						}
+						it.Close()
+
+						if it.Error() != nil || count < n {
+							errs <- fmt.Errorf("iterated %d of %d rows: %v", count, n, it.Error())
+							return
+						}
+					}
+				}(r)
+			}
+
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Fatal(err)
+			}
+
+			if c.NumRows() != batches*batchSize {
+				t.Fatalf("expected %d rows, got %d", batches*batchSize, c.NumRows())
+			}
+		})
+	}
+}
+
+func TestConcurrentCompact(t *testing.T) {
+	for name, options := range map[string][]Option{
+		"rows":   {WithKeyColumn("Quantity")},
+		"blocks": {WithBlockCompression(CompressionSnappy, 64), WithSparseIndex("Quantity", 32)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := Create[*testRowV2, testRowV2](filepath.Join(t.TempDir(), "compact.sbt"), options...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer c.Close()
+
+			const numRows, compactions, deleted = 1000, 5, 10
+
+			rows := make([]*testRowV2, numRows)
+			for i := range rows {
+				rows[i] = &testRowV2{Price: uint32(i), Symbol: "SYM", Quantity: uint64(i)}
+			}
+
+			if err = c.BulkAppend(rows); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			done := make(chan struct{})
+			errs := make(chan error, 16)
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				defer close(done)
+
+				for i := 0; i < compactions; i++ {
+					if err := c.DeleteRange(0, deleted); err != nil {
+						errs <- err
+						return
+					}
+
+					if err := c.Compact(); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}()
+
+			// rows below the final row count stay in bounds, and rows past the deleted ones live,
+			// whichever compaction they're read after
+			const readable = numRows - compactions*deleted
+
+			for r := 0; r < 4; r++ {
+				wg.Add(1)
+
+				go func(r int) {
+					defer wg.Done()
+
+					for pos := int64(deleted + r); ; pos = deleted + (pos+97)%(readable-deleted) {
+						select {
+						case <-done:
+							return
+						default:
+						}
+
+						row := new(testRowV2)
+						if err := c.ReadAt(pos, row); err != nil || row.Price != uint32(row.Quantity) || row.Symbol != "SYM" {
+							errs <- fmt.Errorf("row %d %+v: %v", pos, row, err)
+							return
+						}
+
+						if _, err := ReadColumn[uint64](c, "Quantity", pos, 1); err != nil {
+							errs <- fmt.Errorf("column of row %d: %v", pos, err)
+							return
+						}
+
+						if _, err := c.SearchFirst(int64(pos)); err != nil {
+							errs <- fmt.Errorf("search %d: %v", pos, err)
+							return
+						}
+					}
+				}(r)
+			}
+
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Fatal(err)
+			}
+
+			if c.NumRows() != readable {
+				t.Fatalf("expected %d rows, got %d", readable, c.NumRows())
+			}
+
+			row := new(testRowV2)
+			if err = c.ReadAt(0, row); err != nil || row.Price != compactions*deleted {
+				t.Fatalf("unexpected first row %+v: %v", row, err)
+			}
+		})
+	}
+}
@@ -2,18 +2,47 @@ package sbt
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"math"
+	"net/netip"
 	"time"
 )
 
+// CheckMode selects the checks done by the Encoder and Decoder passed to rows, see WithChecks.
+type CheckMode uint8
+
+const (
+	// CheckNone does no checks, rows writing or reading past their size panic.
+	CheckNone CheckMode = iota
+	// CheckBounds fails rows writing or reading past their size with ErrOverflow,
+	// and rows not writing or reading exactly RowSize bytes with ErrRowSize.
+	CheckBounds
+	// CheckColumns also fails every write or read not matching the type and size
+	// of the column at its offset with ErrColumnMismatch.
+	CheckColumns
+)
+
+var (
+	ErrOverflow       = errors.New("row overflow")
+	ErrRowSize        = errors.New("row size mismatch")
+	ErrColumnMismatch = errors.New("column mismatch")
+)
+
 type RowSerializerBase struct {
 	buffer  []byte
 	counter int
+	heap    *heap
+	spec    RowSpec
+	order   binary.ByteOrder
+	nulls   int
+	checks  CheckMode
+	err     error
 }
 
 // newRowSerializerBase
 func newRowSerializerBase(buffer []byte) RowSerializerBase {
-	return RowSerializerBase{buffer: buffer}
+	return RowSerializerBase{buffer: buffer, order: binary.LittleEndian}
 }
 
 // Bytes returns the byte slice of the serializer.
@@ -25,6 +54,111 @@ func (s *RowSerializerBase) Bytes() []byte {
 func (s *RowSerializerBase) Reset(buffer []byte) {
 	s.buffer = buffer
 	s.counter = 0
+	s.err = nil
+}
+
+// Err returns the first error of the current row, always nil with CheckNone.
+// Once it's set, writes are ignored and reads return zero values.
+func (s *RowSerializerBase) Err() error {
+	return s.err
+}
+
+// column returns the index of the column starting at the current position, or -1.
+func (s *RowSerializerBase) column() int {
+	offset := s.nulls
+	for i, c := range s.spec {
+		if offset == s.counter {
+			return i
+		}
+
+		offset += int(c.Size)
+	}
+
+	return -1
+}
+
+// timePrecision returns the precision of the time column starting at the current position,
+// MaxTimePrecision for other columns or without a RowSpec.
+func (s *RowSerializerBase) timePrecision() uint8 {
+	if s.spec == nil {
+		return MaxTimePrecision
+	}
+
+	if i := s.column(); i >= 0 && s.spec[i].Type == ColumnTypeTime {
+		return s.spec[i].Precision
+	}
+
+	return MaxTimePrecision
+}
+
+// next returns the next n bytes of the row and moves past them.
+//
+// Checked serializers verify the bounds, and with CheckColumns that the n bytes are a column of one of
+// the given types unless types is empty. They return nil and keep the error on failure.
+func (s *RowSerializerBase) next(n int, types ...ColumnType) []byte {
+	if s.checks == CheckNone {
+		b := s.buffer[s.counter : s.counter+n]
+		s.counter += n
+		return b
+	}
+
+	if s.err != nil {
+		return nil
+	}
+
+	if s.counter+n > len(s.buffer) {
+		s.err = fmt.Errorf("%w: %d bytes at offset %d of a %d bytes row", ErrOverflow, n, s.counter, len(s.buffer))
+		return nil
+	}
+
+	if s.checks == CheckColumns && len(types) > 0 && s.spec != nil {
+		if s.err = s.checkColumn(n, types); s.err != nil {
+			return nil
+		}
+	}
+
+	b := s.buffer[s.counter : s.counter+n]
+	s.counter += n
+
+	return b
+}
+
+// checkColumn returns an error if the n bytes at the current position aren't a column of one of types.
+func (s *RowSerializerBase) checkColumn(n int, types []ColumnType) error {
+	i := s.column()
+	if i < 0 {
+		return fmt.Errorf("%w: %s value at offset %d isn't at a column", ErrColumnMismatch, types[0], s.counter)
+	}
+
+	column := s.spec[i]
+	if int(column.Size) != n {
+		return fmt.Errorf("%w: %d bytes %s value for %q of %d bytes", ErrColumnMismatch, n, types[0], column.Name, column.Size)
+	}
+
+	for _, t := range types {
+		if t == column.Type {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s value for %s column %q", ErrColumnMismatch, types[0], column.Type, column.Name)
+}
+
+// endRow returns the error of the row, if any, or ErrRowSize if a checked serializer didn't reach the end of the row.
+func (s *RowSerializerBase) endRow() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if s.checks == CheckNone {
+		return nil
+	}
+
+	if s.spec != nil && s.counter != int(s.spec.RowSize()) {
+		return fmt.Errorf("%w: %d bytes of %d", ErrRowSize, s.counter, s.spec.RowSize())
+	}
+
+	return nil
 }
 
 // Encoder is passed to Row.Encode as the encoding context and helper.
@@ -37,91 +171,227 @@ func NewEncoder(buffer []byte) *Encoder {
 	return &Encoder{newRowSerializerBase(buffer)}
 }
 
-// EncodeTime
+// beginRow clears the null bitmap of the row and moves past it.
+func (e *Encoder) beginRow() {
+	e.counter = 0
+	e.err = nil
+
+	b := e.next(e.nulls)
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// EncodeNull writes the current column as null, it fails with ErrNotNullable if the column isn't nullable.
+func (e *Encoder) EncodeNull() error {
+	i := e.column()
+	bit := e.spec.nullBit(i)
+	if bit < 0 {
+		return ErrNotNullable
+	}
+
+	b := e.next(int(e.spec[i].Size), e.spec[i].Type)
+	if b == nil {
+		return e.err
+	}
+
+	for j := range b {
+		b[j] = 0
+	}
+	e.buffer[bit/8] |= 1 << (bit % 8)
+
+	return nil
+}
+
+// putUint16
+func (e *Encoder) putUint16(v uint16, types ...ColumnType) {
+	if b := e.next(2, types...); b != nil {
+		e.order.PutUint16(b, v)
+	}
+}
+
+// putUint32
+func (e *Encoder) putUint32(v uint32, types ...ColumnType) {
+	if b := e.next(4, types...); b != nil {
+		e.order.PutUint32(b, v)
+	}
+}
+
+// putUint64
+func (e *Encoder) putUint64(v uint64, types ...ColumnType) {
+	if b := e.next(8, types...); b != nil {
+		e.order.PutUint64(b, v)
+	}
+}
+
+// putBytes writes b padded with zeros to size bytes.
+func (e *Encoder) putBytes(v []byte, size int, types ...ColumnType) {
+	if b := e.next(size, types...); b != nil {
+		n := copy(b, v)
+		for i := n; i < size; i++ {
+			b[i] = 0
+		}
+	}
+}
+
+// EncodeTime writes t as unix nanoseconds, or in the precision of the time column at the current position.
 func (e *Encoder) EncodeTime(t time.Time) {
-	e.EncodeInt64(t.UnixNano())
+	e.putUint64(uint64(timeUnits(t, e.timePrecision())), ColumnTypeInt64, ColumnTypeTime)
 }
 
 // EncodeStringPadded
 func (e *Encoder) EncodeStringPadded(s string, size int) {
-	copy(e.buffer[e.counter:], StringToBytePadded(s, size))
-	e.counter += size
+	if b := e.next(size, ColumnTypeString, ColumnTypeBinary); b != nil {
+		copy(b, StringToBytePadded(s, size))
+	}
 }
 
 // EncodeBytesPadded
 func (e *Encoder) EncodeBytesPadded(b []byte, size int) {
-	copy(e.buffer[e.counter:], b)
-	e.counter += size
+	e.putBytes(b, size, ColumnTypeBinary, ColumnTypeString)
+}
+
+// EncodeVarBytes writes b to the heap and its reference to the row.
+// It panics with ErrNoHeap if the row has no variable-length columns, checked encoders keep the error instead.
+func (e *Encoder) EncodeVarBytes(b []byte) {
+	if e.heap == nil {
+		if e.checks == CheckNone {
+			panic(ErrNoHeap)
+		}
+
+		if e.err == nil {
+			e.err = ErrNoHeap
+		}
+
+		return
+	}
+
+	ref := e.next(HeapRefSize, ColumnTypeVarBinary, ColumnTypeVarString)
+	if ref == nil {
+		return
+	}
+
+	offset, length, err := e.heap.reserve(b)
+	if err != nil {
+		if e.err == nil {
+			e.err = err
+		}
+
+		return
+	}
+
+	e.order.PutUint64(ref, offset)
+	e.order.PutUint32(ref[8:], length)
+}
+
+// EncodeVarString
+func (e *Encoder) EncodeVarString(s string) {
+	e.EncodeVarBytes([]byte(s))
 }
 
 // EncodeUInt8
 func (e *Encoder) EncodeUInt8(v uint8) {
-	e.buffer[e.counter] = v
-	e.counter++
+	if b := e.next(1, ColumnTypeUInt8); b != nil {
+		b[0] = v
+	}
 }
 
 // EncodeUInt16
 func (e *Encoder) EncodeUInt16(v uint16) {
-	binary.LittleEndian.PutUint16(e.buffer[e.counter:], v)
-	e.counter += 2
+	e.putUint16(v, ColumnTypeUInt16)
 }
 
 // EncodeUInt32
 func (e *Encoder) EncodeUInt32(v uint32) {
-	binary.LittleEndian.PutUint32(e.buffer[e.counter:], v)
-	e.counter += 4
+	e.putUint32(v, ColumnTypeUInt32)
 }
 
 // EncodeUInt64
 func (e *Encoder) EncodeUInt64(v uint64) {
-	binary.LittleEndian.PutUint64(e.buffer[e.counter:], v)
-	e.counter += 8
+	e.putUint64(v, ColumnTypeUInt64)
 }
 
 // EncodeInt8
 func (e *Encoder) EncodeInt8(v int8) {
-	e.buffer[e.counter] = byte(v)
-	e.counter++
+	if b := e.next(1, ColumnTypeInt8); b != nil {
+		b[0] = byte(v)
+	}
 }
 
 // EncodeInt16
 func (e *Encoder) EncodeInt16(v int16) {
-	binary.LittleEndian.PutUint16(e.buffer[e.counter:], uint16(v))
-	e.counter += 2
+	e.putUint16(uint16(v), ColumnTypeInt16)
 }
 
 // EncodeInt32
 func (e *Encoder) EncodeInt32(v int32) {
-	binary.LittleEndian.PutUint32(e.buffer[e.counter:], uint32(v))
-	e.counter += 4
+	e.putUint32(uint32(v), ColumnTypeInt32)
 }
 
-// EncodeInt64
+// EncodeInt64 writes v to an i64 column, or as the raw value of a time or decimal column.
 func (e *Encoder) EncodeInt64(v int64) {
-	binary.LittleEndian.PutUint64(e.buffer[e.counter:], uint64(v))
-	e.counter += 8
+	e.putUint64(uint64(v), ColumnTypeInt64, ColumnTypeTime, ColumnTypeDecimal)
 }
 
 // EncodeFloat32
 func (e *Encoder) EncodeFloat32(v float32) {
-	binary.LittleEndian.PutUint32(e.buffer[e.counter:], math.Float32bits(v))
-	e.counter += 4
+	e.putUint32(math.Float32bits(v), ColumnTypeFloat32)
 }
 
 // EncodeFloat64
 func (e *Encoder) EncodeFloat64(v float64) {
-	binary.LittleEndian.PutUint64(e.buffer[e.counter:], math.Float64bits(v))
-	e.counter += 8
+	e.putUint64(math.Float64bits(v), ColumnTypeFloat64)
 }
 
 // EncodeBool
 func (e *Encoder) EncodeBool(v bool) {
-	if v {
-		e.buffer[e.counter] = 1
-	} else {
-		e.buffer[e.counter] = 0
+	if b := e.next(1, ColumnTypeBool); b != nil {
+		b[0] = 0
+		if v {
+			b[0] = 1
+		}
+	}
+}
+
+// EncodeTimestamp writes t as a time column value of the given precision, truncating extra digits.
+func (e *Encoder) EncodeTimestamp(t time.Time, precision uint8) {
+	e.putUint64(uint64(timeUnits(t, precision)), ColumnTypeTime, ColumnTypeInt64)
+}
+
+// EncodeDecimal writes d as a decimal column value of the given scale, truncating extra digits.
+func (e *Encoder) EncodeDecimal(d Decimal, scale uint8) (err error) {
+	if d, err = d.Rescale(scale); err != nil {
+		return
 	}
-	e.counter++
+
+	e.putUint64(uint64(d.Unscaled), ColumnTypeDecimal, ColumnTypeInt64)
+
+	return
+}
+
+// EncodeUUID
+func (e *Encoder) EncodeUUID(u UUID) {
+	e.putBytes(u[:], 16, ColumnTypeUUID)
+}
+
+// EncodeIPv4 writes the IPv4 or IPv4-mapped IPv6 address a, other addresses are written as 0.0.0.0.
+func (e *Encoder) EncodeIPv4(a netip.Addr) {
+	var b [4]byte
+	if a = a.Unmap(); a.Is4() {
+		b = a.As4()
+	}
+
+	e.putBytes(b[:], 4, ColumnTypeIPv4)
+}
+
+// EncodeIPv6 writes a as an IPv6 address, IPv4 addresses being IPv4-mapped. Invalid addresses are written as ::.
+func (e *Encoder) EncodeIPv6(a netip.Addr) {
+	var b [16]byte
+	if a.IsValid() {
+		b = a.As16()
+	}
+
+	e.putBytes(b[:], 16, ColumnTypeIPv6)
 }
 
 // Decoder is passed to Row.Decode as the encoding context and helper.
@@ -134,98 +404,177 @@ func NewDecoder(buffer []byte) *Decoder {
 	return &Decoder{newRowSerializerBase(buffer)}
 }
 
-// DecodeTime
+// beginRow moves past the null bitmap of the row.
+func (d *Decoder) beginRow() {
+	d.counter = 0
+	d.err = nil
+	d.next(d.nulls)
+}
+
+// IsNull reports whether the column at index col of the RowSpec is null in the current row.
+func (d *Decoder) IsNull(col int) bool {
+	bit := d.spec.nullBit(col)
+	return bit >= 0 && bit/8 < len(d.buffer) && d.buffer[bit/8]&(1<<(bit%8)) != 0
+}
+
+// uint16
+func (d *Decoder) uint16(types ...ColumnType) uint16 {
+	if b := d.next(2, types...); b != nil {
+		return d.order.Uint16(b)
+	}
+
+	return 0
+}
+
+// uint32
+func (d *Decoder) uint32(types ...ColumnType) uint32 {
+	if b := d.next(4, types...); b != nil {
+		return d.order.Uint32(b)
+	}
+
+	return 0
+}
+
+// uint64
+func (d *Decoder) uint64(types ...ColumnType) uint64 {
+	if b := d.next(8, types...); b != nil {
+		return d.order.Uint64(b)
+	}
+
+	return 0
+}
+
+// byte
+func (d *Decoder) byte(types ...ColumnType) byte {
+	if b := d.next(1, types...); b != nil {
+		return b[0]
+	}
+
+	return 0
+}
+
+// DecodeTime reads unix nanoseconds, or a value in the precision of the time column at the current position.
 func (d *Decoder) DecodeTime() time.Time {
-	return time.Unix(0, d.DecodeInt64())
+	precision := d.timePrecision()
+	return unitsTime(int64(d.uint64(ColumnTypeInt64, ColumnTypeTime)), precision)
 }
 
 // DecodeStringPadded
 func (d *Decoder) DecodeStringPadded(size int) string {
-	s := ByteToStringPadded(d.buffer[d.counter : d.counter+size])
-	d.counter += size
-	return s
+	return ByteToStringPadded(d.next(size, ColumnTypeString, ColumnTypeBinary))
 }
 
-// DecodeBytes
+// DecodeBytes returns the next size bytes of the row, nil once a checked decoder failed.
 func (d *Decoder) DecodeBytes(size int) []byte {
-	b := d.buffer[d.counter : d.counter+size]
-	d.counter += size
-	return b
+	return d.next(size, ColumnTypeBinary, ColumnTypeString)
+}
+
+// DecodeVarBytes reads a variable-length column from the heap.
+// Empty values aren't stored in the heap, so they're decoded without one, e.g. columns added by a projection.
+func (d *Decoder) DecodeVarBytes() ([]byte, error) {
+	ref := d.next(HeapRefSize, ColumnTypeVarBinary, ColumnTypeVarString)
+	if ref == nil {
+		return nil, d.err
+	}
+
+	if d.order.Uint32(ref[8:]) == 0 {
+		return nil, nil
+	}
+
+	if d.heap == nil {
+		return nil, ErrNoHeap
+	}
+
+	return d.heap.read(d.order.Uint64(ref), d.order.Uint32(ref[8:]))
+}
+
+// DecodeVarString
+func (d *Decoder) DecodeVarString() (string, error) {
+	b, err := d.DecodeVarBytes()
+	return string(b), err
 }
 
 // DecodeUInt8
 func (d *Decoder) DecodeUInt8() uint8 {
-	v := d.buffer[d.counter]
-	d.counter++
-	return v
+	return d.byte(ColumnTypeUInt8)
 }
 
 // DecodeUInt16
 func (d *Decoder) DecodeUInt16() uint16 {
-	v := binary.LittleEndian.Uint16(d.buffer[d.counter:])
-	d.counter += 2
-	return v
+	return d.uint16(ColumnTypeUInt16)
 }
 
 // DecodeUInt32
 func (d *Decoder) DecodeUInt32() uint32 {
-	v := binary.LittleEndian.Uint32(d.buffer[d.counter:])
-	d.counter += 4
-	return v
+	return d.uint32(ColumnTypeUInt32)
 }
 
 // DecodeUInt64
 func (d *Decoder) DecodeUInt64() uint64 {
-	v := binary.LittleEndian.Uint64(d.buffer[d.counter:])
-	d.counter += 8
-	return v
+	return d.uint64(ColumnTypeUInt64)
 }
 
 // DecodeInt8
 func (d *Decoder) DecodeInt8() int8 {
-	v := d.buffer[d.counter]
-	d.counter++
-	return int8(v)
+	return int8(d.byte(ColumnTypeInt8))
 }
 
 // DecodeInt16
 func (d *Decoder) DecodeInt16() int16 {
-	v := binary.LittleEndian.Uint16(d.buffer[d.counter:])
-	d.counter += 2
-	return int16(v)
+	return int16(d.uint16(ColumnTypeInt16))
 }
 
 // DecodeInt32
 func (d *Decoder) DecodeInt32() int32 {
-	v := binary.LittleEndian.Uint32(d.buffer[d.counter:])
-	d.counter += 4
-	return int32(v)
+	return int32(d.uint32(ColumnTypeInt32))
 }
 
-// DecodeInt64
+// DecodeInt64 reads an i64 column, or the raw value of a time or decimal column.
 func (d *Decoder) DecodeInt64() int64 {
-	v := binary.LittleEndian.Uint64(d.buffer[d.counter:])
-	d.counter += 8
-	return int64(v)
+	return int64(d.uint64(ColumnTypeInt64, ColumnTypeTime, ColumnTypeDecimal))
 }
 
 // DecodeFloat32
 func (d *Decoder) DecodeFloat32() float32 {
-	v := binary.LittleEndian.Uint32(d.buffer[d.counter:])
-	d.counter += 4
-	return math.Float32frombits(v)
+	return math.Float32frombits(d.uint32(ColumnTypeFloat32))
 }
 
 // DecodeFloat64
 func (d *Decoder) DecodeFloat64() float64 {
-	v := binary.LittleEndian.Uint64(d.buffer[d.counter:])
-	d.counter += 8
-	return math.Float64frombits(v)
+	return math.Float64frombits(d.uint64(ColumnTypeFloat64))
 }
 
 // DecodeBool
 func (d *Decoder) DecodeBool() bool {
-	v := d.buffer[d.counter]
-	d.counter++
-	return v != 0
+	return d.byte(ColumnTypeBool) != 0
+}
+
+// DecodeTimestamp reads a time column value of the given precision.
+func (d *Decoder) DecodeTimestamp(precision uint8) time.Time {
+	return unitsTime(int64(d.uint64(ColumnTypeTime, ColumnTypeInt64)), precision)
+}
+
+// DecodeDecimal reads a decimal column value of the given scale.
+func (d *Decoder) DecodeDecimal(scale uint8) Decimal {
+	return Decimal{Unscaled: int64(d.uint64(ColumnTypeDecimal, ColumnTypeInt64)), Scale: scale}
+}
+
+// DecodeUUID
+func (d *Decoder) DecodeUUID() (u UUID) {
+	copy(u[:], d.next(16, ColumnTypeUUID))
+	return
+}
+
+// DecodeIPv4
+func (d *Decoder) DecodeIPv4() netip.Addr {
+	var b [4]byte
+	copy(b[:], d.next(4, ColumnTypeIPv4))
+	return netip.AddrFrom4(b)
+}
+
+// DecodeIPv6 reads an IPv6 address, IPv4-mapped addresses are returned as is.
+func (d *Decoder) DecodeIPv6() netip.Addr {
+	var b [16]byte
+	copy(b[:], d.next(16, ColumnTypeIPv6))
+	return netip.AddrFrom16(b)
 }
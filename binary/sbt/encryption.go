@@ -0,0 +1,147 @@
+package sbt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Cipher is the authenticated encryption algorithm of encrypted files.
+type Cipher string
+
+const (
+	// CipherAESGCM is AES in GCM mode with random 96-bit nonces, AES-128, AES-192 or AES-256
+	// depending on the size of the key.
+	CipherAESGCM Cipher = "aes-gcm"
+)
+
+var (
+	ErrEncrypted = errors.New("container is encrypted, no key given")
+	ErrWrongKey  = errors.New("wrong encryption key")
+)
+
+// EncryptionConfig declares the encryption of a Container file, stored in the header.
+type EncryptionConfig struct {
+	Cipher Cipher `json:"cipher"`
+	// Check is an empty message sealed with the key, to tell a wrong key from corrupted blocks.
+	Check []byte `json:"check"`
+	// Salt is random to every file and authenticated with every message,
+	// so blocks and payloads can't be moved between files encrypted with the same key.
+	Salt []byte `json:"salt,omitempty"`
+}
+
+// saltSize is the size of the salt of new files.
+const saltSize = 16
+
+// newAEAD returns the AEAD of c keyed with key.
+func newAEAD(c Cipher, key []byte) (aead cipher.AEAD, err error) {
+	switch c {
+	case CipherAESGCM, "":
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err != nil {
+			return
+		}
+
+		return cipher.NewGCM(block)
+	}
+
+	return nil, fmt.Errorf("unknown cipher %q", c)
+}
+
+// newEncryption returns the AEAD and the EncryptionConfig of a new file encrypted with key.
+func newEncryption(c Cipher, key []byte) (aead cipher.AEAD, config *EncryptionConfig, err error) {
+	if c == "" {
+		c = CipherAESGCM
+	}
+
+	if aead, err = newAEAD(c, key); err != nil {
+		err = fmt.Errorf("invalid encryption: %w", err)
+		return
+	}
+
+	config = &EncryptionConfig{Cipher: c, Salt: make([]byte, saltSize)}
+	if _, err = rand.Read(config.Salt); err != nil {
+		err = fmt.Errorf("failed to generate salt: %w", err)
+		return
+	}
+
+	aead = saltedAEAD{AEAD: aead, salt: config.Salt}
+	if config.Check, err = seal(aead, nil, nil); err != nil {
+		return
+	}
+
+	return
+}
+
+// openEncryption returns the AEAD of a file encrypted according to config, checking key against it.
+func openEncryption(config *EncryptionConfig, key []byte) (aead cipher.AEAD, err error) {
+	if key == nil {
+		return nil, ErrEncrypted
+	}
+
+	if aead, err = newAEAD(config.Cipher, key); err != nil {
+		return nil, fmt.Errorf("invalid encryption: %w", err)
+	}
+
+	if config.Salt != nil {
+		aead = saltedAEAD{AEAD: aead, salt: config.Salt}
+	}
+
+	if _, err = unseal(aead, config.Check, nil); err != nil {
+		return nil, ErrWrongKey
+	}
+
+	return
+}
+
+// saltedAEAD appends the salt of a file to the additional data of every message.
+type saltedAEAD struct {
+	cipher.AEAD
+	salt []byte
+}
+
+// Seal
+func (a saltedAEAD) Seal(dst, nonce, plaintext, ad []byte) []byte {
+	return a.AEAD.Seal(dst, nonce, plaintext, append(ad[:len(ad):len(ad)], a.salt...))
+}
+
+// Open
+func (a saltedAEAD) Open(dst, nonce, ciphertext, ad []byte) ([]byte, error) {
+	return a.AEAD.Open(dst, nonce, ciphertext, append(ad[:len(ad):len(ad)], a.salt...))
+}
+
+// seal encrypts and authenticates plaintext and additional data ad under a random nonce,
+// returning the nonce followed by the ciphertext.
+func seal(aead cipher.AEAD, plaintext, ad []byte) (b []byte, err error) {
+	b = make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err = rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(b, b, plaintext, ad), nil
+}
+
+// unseal decrypts and authenticates the output of seal.
+func unseal(aead cipher.AEAD, b, ad []byte) ([]byte, error) {
+	if len(b) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("sealed data too short: %d bytes", len(b))
+	}
+
+	return aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], ad)
+}
+
+// blockAD returns the additional data authenticated with a block frame: its index and row count,
+// so frames can't be reordered or truncated. The AEAD of the file adds its salt.
+func blockAD(index int, rows uint32) []byte {
+	b := binary.LittleEndian.AppendUint64(nil, uint64(index))
+
+	return binary.LittleEndian.AppendUint32(b, rows)
+}
+
+// heapAD returns the additional data authenticated with a heap payload: its offset. The AEAD of the file adds its salt.
+func heapAD(offset uint64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, offset)
+}
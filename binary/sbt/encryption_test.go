@@ -0,0 +1,216 @@
+package sbt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testEncryptionKey = bytes.Repeat([]byte{0x42}, 32)
+
+func testEncryptedRows(n int) []*StructRow[testVarRow] {
+	rows := make([]*StructRow[testVarRow], n)
+	for i := range rows {
+		rows[i] = NewStructRow(testVarRow{ID: uint32(i), Message: fmt.Sprintf("secret-%d", i)})
+	}
+
+	return rows
+}
+
+func TestEncryption(t *testing.T) {
+	for name, options := range map[string][]Option{
+		"blocks":   {},
+		"columnar": {WithColumnar(), WithBlockCompression(CompressionNone, 16)},
+		"zstd":     {WithBlockCompression(CompressionZstd, 16), WithChecksum()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "encrypted.sbt")
+
+			c, err := Create[*StructRow[testVarRow], StructRow[testVarRow]](filename,
+				append(options, WithEncryption(testEncryptionKey))...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+
+			rows := testEncryptedRows(40)
+			if err = c.BulkAppend(rows); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			if c.Flags()&FlagEncrypted == 0 || c.Block() == nil {
+				t.Fatalf("expected encrypted block layout, flags %b", c.Flags())
+			}
+
+			if err = c.Close(); err != nil {
+				t.Fatalf("failed to close container: %v", err)
+			}
+
+			for _, f := range []string{filename, filename + HeapExtension} {
+				b, err := os.ReadFile(f)
+				if err != nil {
+					t.Fatalf("failed to read %s: %v", f, err)
+				}
+
+				if bytes.Contains(b, []byte("secret-")) {
+					t.Fatalf("%s holds plaintext", f)
+				}
+			}
+
+			if _, err = Open[*StructRow[testVarRow], StructRow[testVarRow]](filename); !errors.Is(err, ErrEncrypted) {
+				t.Fatalf("expected encrypted error, got %v", err)
+			}
+
+			wrong := bytes.Repeat([]byte{0x24}, 32)
+			if _, err = Open[*StructRow[testVarRow], StructRow[testVarRow]](filename, WithEncryption(wrong)); !errors.Is(err, ErrWrongKey) {
+				t.Fatalf("expected wrong key error, got %v", err)
+			}
+
+			if c, err = Open[*StructRow[testVarRow], StructRow[testVarRow]](filename, WithEncryption(testEncryptionKey)); err != nil {
+				t.Fatalf("failed to open container: %v", err)
+			}
+			defer c.Close()
+
+			if c.NumRows() != 40 {
+				t.Fatalf("expected 40 rows, got %d", c.NumRows())
+			}
+
+			for _, pos := range []int64{39, 0, 17, 32} {
+				row := new(StructRow[testVarRow])
+				if err = c.ReadAt(pos, row); err != nil {
+					t.Fatalf("failed to read row %d: %v", pos, err)
+				}
+
+				if row.Value.ID != rows[pos].Value.ID || row.Value.Message != rows[pos].Value.Message {
+					t.Fatalf("row %d: expected %+v, got %+v", pos, rows[pos].Value, row.Value)
+				}
+			}
+
+			ids, err := ReadColumn[uint32](c, "id", 10, 20)
+			if err != nil || len(ids) != 20 || ids[0] != 10 || ids[19] != 29 {
+				t.Fatalf("unexpected column %v: %v", ids, err)
+			}
+		})
+	}
+}
+
+func TestEncryptionTampering(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "tampered.sbt")
+
+	c, err := Create[*testRowV2, testRowV2](filename, WithEncryption(testEncryptionKey),
+		WithBlockCompression(CompressionNone, 16))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	if err = c.BulkAppend(testTxRows(0, 32)); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	offset := c.contentOffset
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	// swap the two blocks, their frames have the same size
+	frames := b[offset:]
+	first := append([]byte(nil), frames[:len(frames)/2]...)
+	copy(frames, frames[len(frames)/2:])
+	copy(frames[len(frames)/2:], first)
+
+	if err = os.WriteFile(filename, b, 0666); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if c, err = Open[*testRowV2, testRowV2](filename, WithEncryption(testEncryptionKey)); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	if err = c.ReadAt(0, new(testRowV2)); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected corrupted error, got %v", err)
+	}
+
+	report, err := c.Verify()
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+
+	if len(report.Corrupted) != 1 || report.Corrupted[0] != (RowRange{Start: 0, Count: 32}) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestEncryptionSalt(t *testing.T) {
+	dir := t.TempDir()
+	filenames := []string{filepath.Join(dir, "a.sbt"), filepath.Join(dir, "b.sbt")}
+	offsets := make([]int64, len(filenames))
+
+	for i, filename := range filenames {
+		// the index of b is rebuilt on open, a would fail to open with one
+		options := []Option{WithEncryption(testEncryptionKey), WithBlockCompression(CompressionNone, 16)}
+		if i == 1 {
+			options = append(options, WithSparseIndex("Price", 8))
+		}
+
+		c, err := Create[*testRowV2, testRowV2](filename, options...)
+		if err != nil {
+			t.Fatalf("failed to create container: %v", err)
+		}
+
+		if err = c.BulkAppend(testTxRows(i*100, 16)); err != nil {
+			t.Fatalf("failed to append rows: %v", err)
+		}
+
+		offsets[i] = c.contentOffset
+		if err = c.Close(); err != nil {
+			t.Fatalf("failed to close container: %v", err)
+		}
+
+		if _, err = os.Stat(filename + IndexExtension); !os.IsNotExist(err) {
+			t.Fatalf("expected no index sidecar, got %v", err)
+		}
+	}
+
+	a, err := os.ReadFile(filenames[0])
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	b, err := os.ReadFile(filenames[1])
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	// the index of the block and its row count match, the salt doesn't
+	a = append(a[:offsets[0]], b[offsets[1]:]...)
+	if err = os.WriteFile(filenames[0], a, 0666); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	c, err := Open[*testRowV2, testRowV2](filenames[0], WithEncryption(testEncryptionKey))
+	if err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	if err = c.ReadAt(0, new(testRowV2)); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected corrupted error, got %v", err)
+	}
+
+	if c, err = Open[*testRowV2, testRowV2](filenames[1], WithEncryption(testEncryptionKey)); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	if pos, err := c.SearchFirst(105); err != nil || pos != 5 {
+		t.Fatalf("expected key 105 at 5, got %d: %v", pos, err)
+	}
+}
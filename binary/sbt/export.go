@@ -0,0 +1,371 @@
+package sbt
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/difof/goul/generics"
+)
+
+// ExportFormat is a file format rows can be exported to and imported from.
+type ExportFormat string
+
+const (
+	// ExportCSV is CSV with a header record of column names, binary values are base64 encoded and null values empty.
+	ExportCSV ExportFormat = "csv"
+	// ExportJSONL is JSON Lines, one object per row keyed by column names.
+	ExportJSONL ExportFormat = "jsonl"
+	// ExportParquet is Apache Parquet, see exportParquet and importParquet for the supported subset.
+	ExportParquet ExportFormat = "parquet"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Export writes the live rows to w in format, driven by the stored RowSpec.
+func (c *Container[P, RowType]) Export(w io.Writer, format ExportFormat) error {
+	switch format {
+	case ExportCSV:
+		return c.exportCSV(w)
+	case ExportJSONL:
+		return c.exportJSONL(w)
+	case ExportParquet:
+		return c.exportParquet(w)
+	}
+
+	return fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+// Import appends the rows read from r in format, driven by the stored RowSpec.
+//
+// Fields are matched to columns by name, missing ones are zero. It returns the number of appended rows.
+// The import is journaled like a commit: if it fails, none of the rows are appended.
+func (c *Container[P, RowType]) Import(r io.Reader, format ExportFormat) (n int64, err error) {
+	switch format {
+	case ExportCSV:
+		return c.importCSV(r)
+	case ExportJSONL:
+		return c.importJSONL(r)
+	case ExportParquet:
+		return c.importParquet(r)
+	}
+
+	return 0, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+// scanValues calls fn with the column values of every live row, fn must not retain values.
+func (c *Container[P, RowType]) scanValues(fn func(values []any) error) (err error) {
+	numRows := c.NumRows()
+	rowSize := int64(c.spec.RowSize())
+	values := make([]any, len(c.spec))
+	decoder := c.newDecoder(nil)
+
+	for pos := int64(0); pos < numRows; pos += Bucket10k {
+		n := numRows - pos
+		if n > Bucket10k {
+			n = Bucket10k
+		}
+
+		if err = c.scanBucket(pos, n, rowSize, decoder, values, fn); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// scanBucket calls fn with the column values of the live rows among the n rows starting at pos.
+func (c *Container[P, RowType]) scanBucket(
+	pos, n, rowSize int64,
+	decoder *Decoder,
+	values []any,
+	fn func(values []any) error,
+) (err error) {
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+
+	if err = c.checkBounds(pos, n); err != nil {
+		return
+	}
+
+	var rows []byte
+	if rows, err = c.readRowBytes(pos, n, nil); err != nil {
+		return fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	for i := int64(0); i < n; i++ {
+		if c.tombstones.isDeleted(pos + i) {
+			continue
+		}
+
+		decoder.Reset(rows[i*rowSize : (i+1)*rowSize])
+		decoder.beginRow()
+
+		if err = decodeValues(decoder, values); err != nil {
+			return fmt.Errorf("row %d: %w", pos+i, err)
+		}
+
+		if err = fn(values); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// rowAppender encodes rows of column values and appends them in batches.
+type rowAppender[P generics.Ptr[RowType], RowType any] struct {
+	c       *Container[P, RowType]
+	encoder *Encoder
+	row     []byte
+	batch   []byte
+	n       int64
+}
+
+// newRowAppender returns a rowAppender for c, which must be locked for writing.
+func newRowAppender[P generics.Ptr[RowType], RowType any](c *Container[P, RowType]) *rowAppender[P, RowType] {
+	return &rowAppender[P, RowType]{
+		c:       c,
+		encoder: c.newEncoder(nil),
+		row:     make([]byte, c.spec.RowSize()),
+	}
+}
+
+// append encodes a row from values indexed like the stored RowSpec.
+func (a *rowAppender[P, RowType]) append(values []any) (err error) {
+	a.encoder.Reset(a.row)
+	a.encoder.beginRow()
+
+	if err = encodeValues(a.encoder, values); err != nil {
+		if a.c.heap != nil {
+			a.c.heap.discard()
+		}
+
+		return
+	}
+
+	a.batch = append(a.batch, a.row...)
+
+	if int64(len(a.batch)) >= Bucket1k*int64(len(a.row)) {
+		return a.flush()
+	}
+
+	return
+}
+
+// flush appends the batched rows.
+func (a *rowAppender[P, RowType]) flush() (err error) {
+	if len(a.batch) == 0 {
+		return
+	}
+
+	if err = a.c.flushHeap(); err != nil {
+		return
+	}
+
+	if err = a.c.appendRows(a.batch); err != nil {
+		return fmt.Errorf("failed to write rows: %w", err)
+	}
+
+	a.n += int64(len(a.batch) / len(a.row))
+	a.batch = a.batch[:0]
+
+	return
+}
+
+// columnIndices maps field names to the indices of the stored columns.
+func (c *Container[P, RowType]) columnIndices(names []string) (indices []int, err error) {
+	indices = make([]int, len(names))
+
+	for i, name := range names {
+		if indices[i] = c.spec.Index(name); indices[i] < 0 {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+	}
+
+	return
+}
+
+// importRows runs fn with a rowAppender, holding the write lock. The appended rows are journaled
+// and rolled back if fn fails.
+func (c *Container[P, RowType]) importRows(fn func(a *rowAppender[P, RowType]) error) (n int64, err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err = c.checkWritable(); err != nil {
+		return
+	}
+
+	if err = c.flush(); err != nil {
+		return
+	}
+
+	var j *journal
+	if j, err = c.newJournal(nil); err == nil {
+		err = c.journal.append(j, c.opts.durability != DurabilityNone)
+	}
+
+	if err != nil {
+		return
+	}
+
+	a := newRowAppender(c)
+
+	if err = fn(a); err == nil {
+		if err = a.flush(); err == nil {
+			err = c.flush()
+		}
+	}
+
+	if err != nil {
+		if uerr := c.undo(j); uerr != nil {
+			return 0, fmt.Errorf("failed to roll back: %v: %w", uerr, err)
+		}
+
+		return 0, err
+	}
+
+	return a.n, c.settle()
+}
+
+func (c *Container[P, RowType]) exportCSV(w io.Writer) (err error) {
+	cw := csv.NewWriter(w)
+
+	record := make([]string, len(c.spec))
+	for i, column := range c.spec {
+		record[i] = column.Name
+	}
+
+	if err = cw.Write(record); err != nil {
+		return
+	}
+
+	err = c.scanValues(func(values []any) error {
+		for i, v := range values {
+			record[i] = formatValue(v)
+		}
+
+		return cw.Write(record)
+	})
+	if err != nil {
+		return
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func (c *Container[P, RowType]) importCSV(r io.Reader) (int64, error) {
+	return c.importRows(func(a *rowAppender[P, RowType]) (err error) {
+		cr := csv.NewReader(r)
+
+		var header []string
+		if header, err = cr.Read(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read csv header: %w", err)
+		}
+
+		var indices []int
+		if indices, err = c.columnIndices(header); err != nil {
+			return
+		}
+
+		values := make([]any, len(c.spec))
+
+		for line := 2; ; line++ {
+			var record []string
+			if record, err = cr.Read(); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("failed to read csv: %w", err)
+			}
+
+			for i := range values {
+				values[i] = nil
+			}
+
+			for i, field := range record {
+				column := c.spec[indices[i]]
+				if field == "" && column.Nullable {
+					continue
+				}
+
+				if values[indices[i]], err = parseValue(column, field); err != nil {
+					return fmt.Errorf("line %d: failed to parse column %q: %w", line, column.Name, err)
+				}
+			}
+
+			if err = a.append(values); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+	})
+}
+
+func (c *Container[P, RowType]) exportJSONL(w io.Writer) (err error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	object := make(map[string]any, len(c.spec))
+
+	err = c.scanValues(func(values []any) error {
+		for i, column := range c.spec {
+			object[column.Name] = values[i]
+		}
+
+		return enc.Encode(object)
+	})
+	if err != nil {
+		return
+	}
+
+	return bw.Flush()
+}
+
+func (c *Container[P, RowType]) importJSONL(r io.Reader) (int64, error) {
+	return c.importRows(func(a *rowAppender[P, RowType]) (err error) {
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+
+		values := make([]any, len(c.spec))
+
+		for line := 1; ; line++ {
+			var object map[string]any
+			if err = dec.Decode(&object); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("failed to decode json: %w", err)
+			}
+
+			for i := range values {
+				values[i] = nil
+			}
+
+			for name, v := range object {
+				i := c.spec.Index(name)
+				if i < 0 {
+					return fmt.Errorf("line %d: unknown column %q", line, name)
+				}
+
+				switch v := v.(type) {
+				case json.Number:
+					values[i] = string(v)
+				case string:
+					if values[i], err = parseValue(c.spec[i], v); err != nil {
+						return fmt.Errorf("line %d: failed to parse column %q: %w", line, name, err)
+					}
+				default:
+					values[i] = v
+				}
+			}
+
+			if err = a.append(values); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+	})
+}
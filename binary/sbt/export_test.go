@@ -0,0 +1,438 @@
+package sbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testExportRow struct {
+	Symbol  string  `sbt:"symbol,str,8"`
+	Note    string  `sbt:"note,vstr"`
+	Payload []byte  `sbt:"payload,vbin"`
+	Flag    bool    `sbt:"flag"`
+	Delta   int8    `sbt:"delta"`
+	Level   int16   `sbt:"level"`
+	Count   uint32  `sbt:"count"`
+	Total   uint64  `sbt:"total"`
+	Offset  int64   `sbt:"offset"`
+	Ratio   float32 `sbt:"ratio"`
+	Price   float64 `sbt:"price"`
+}
+
+type exportRow = StructRow[testExportRow]
+
+func testExportRows(n int) []*exportRow {
+	rows := make([]*exportRow, n)
+	for i := range rows {
+		rows[i] = NewStructRow(testExportRow{
+			Symbol:  "BTCUSDT",
+			Note:    strings.Repeat("n", i%5) + ",\"quoted\"",
+			Payload: []byte{byte(i), 0, 255},
+			Flag:    i%3 == 0,
+			Delta:   int8(-i % 100),
+			Level:   int16(i * 7),
+			Count:   uint32(i) + 1<<31,
+			Total:   uint64(i) + 1<<63,
+			Offset:  -int64(i) * 1000,
+			Ratio:   float32(i) / 4,
+			Price:   float64(i) * 1.5,
+		})
+	}
+
+	return rows
+}
+
+func TestExportImport(t *testing.T) {
+	for _, format := range []ExportFormat{ExportCSV, ExportJSONL, ExportParquet} {
+		t.Run(string(format), func(t *testing.T) {
+			dir := t.TempDir()
+
+			src, err := Create[*exportRow, exportRow](filepath.Join(dir, "src.sbt"))
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer src.Close()
+
+			rows := testExportRows(100)
+			if err = src.BulkAppend(rows); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			if err = src.Delete(10); err != nil {
+				t.Fatalf("failed to delete row: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err = src.Export(&buf, format); err != nil {
+				t.Fatalf("failed to export: %v", err)
+			}
+
+			dst, err := Create[*exportRow, exportRow](filepath.Join(dir, "dst.sbt"))
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer dst.Close()
+
+			n, err := dst.Import(&buf, format)
+			if err != nil {
+				t.Fatalf("failed to import: %v", err)
+			}
+
+			if n != 99 || dst.NumRows() != 99 {
+				t.Fatalf("expected 99 imported rows, got %d with %d stored", n, dst.NumRows())
+			}
+
+			for pos, i := int64(0), 0; i < len(rows); i++ {
+				if i == 10 {
+					continue
+				}
+
+				row := new(exportRow)
+				if err = dst.ReadAt(pos, row); err != nil {
+					t.Fatalf("failed to read row %d: %v", pos, err)
+				}
+
+				if !reflect.DeepEqual(row.Value, rows[i].Value) {
+					t.Fatalf("row %d: expected %+v, got %+v", pos, rows[i].Value, row.Value)
+				}
+
+				pos++
+			}
+		})
+	}
+}
+
+// readTestdata returns the content of a file in testdata.
+func readTestdata(t *testing.T, name string) *bytes.Reader {
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+
+	return bytes.NewReader(b)
+}
+
+// TestImportParquetFiles imports files written by parquet-go v0.25.1, using data page v1 and v2, dictionary, delta
+// and byte stream split encodings, logical types and snappy, gzip and zstd compression, in two row groups.
+func TestImportParquetFiles(t *testing.T) {
+	for _, name := range []string{"export_snappy_v1.parquet", "export_zstd_v2.parquet", "export_delta_v1.parquet"} {
+		t.Run(name, func(t *testing.T) {
+			c, err := Create[*exportRow, exportRow](filepath.Join(t.TempDir(), "import.sbt"))
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer c.Close()
+
+			if n, err := c.Import(readTestdata(t, name), ExportParquet); err != nil || n != 100 {
+				t.Fatalf("failed to import: %d %v", n, err)
+			}
+
+			for i, expected := range testExportRows(100) {
+				row := new(exportRow)
+				if err = c.ReadAt(int64(i), row); err != nil {
+					t.Fatalf("failed to read row %d: %v", i, err)
+				}
+
+				if !reflect.DeepEqual(row.Value, expected.Value) {
+					t.Fatalf("row %d: expected %+v, got %+v", i, expected.Value, row.Value)
+				}
+			}
+		})
+	}
+
+	t.Run("nullable_gzip_v2.parquet", func(t *testing.T) {
+		c, err := Create[*testNullableRow, testNullableRow](filepath.Join(t.TempDir(), "import.sbt"))
+		if err != nil {
+			t.Fatalf("failed to create container: %v", err)
+		}
+		defer c.Close()
+
+		if n, err := c.Import(readTestdata(t, "nullable_gzip_v2.parquet"), ExportParquet); err != nil || n != 100 {
+			t.Fatalf("failed to import: %d %v", n, err)
+		}
+
+		checkNullableRows(t, c, testNullableRows(100))
+	})
+
+	t.Run("typed_snappy_v1.parquet", func(t *testing.T) {
+		c, err := Create[*testTypedRow, testTypedRow](filepath.Join(t.TempDir(), "import.sbt"))
+		if err != nil {
+			t.Fatalf("failed to create container: %v", err)
+		}
+		defer c.Close()
+
+		if n, err := c.Import(readTestdata(t, "typed_snappy_v1.parquet"), ExportParquet); err != nil || n != 100 {
+			t.Fatalf("failed to import: %d %v", n, err)
+		}
+
+		for i, expected := range testTypedRows(100) {
+			row := new(testTypedRow)
+			if err = c.ReadAt(int64(i), row); err != nil {
+				t.Fatalf("failed to read row %d: %v", i, err)
+			}
+
+			checkTypedRow(t, row, expected)
+		}
+	})
+}
+
+// TestImportCorruptedParquet imports the files of TestImportParquetFiles with flipped bytes and truncated,
+// which must fail without appending rows.
+func TestImportCorruptedParquet(t *testing.T) {
+	for _, name := range []string{"export_snappy_v1.parquet", "export_zstd_v2.parquet", "export_delta_v1.parquet"} {
+		t.Run(name, func(t *testing.T) {
+			c, err := CreateStorage[*exportRow, exportRow](NewMemoryStorage(nil), WithSidecars(NewMemorySidecars()))
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer c.Close()
+
+			data := readTestdata(t, name)
+			b := make([]byte, data.Len())
+			data.Read(b)
+
+			check := func(corrupted []byte, at int) {
+				rows := c.NumRows()
+
+				n, err := c.Import(bytes.NewReader(corrupted), ExportParquet)
+				if err != nil && (n != 0 || c.NumRows() != rows) {
+					t.Fatalf("byte %d: failed import appended %d rows: %v", at, c.NumRows()-rows, err)
+				}
+
+				// flipped row counts or values may still make a valid file
+				if err == nil && c.NumRows() != rows+n {
+					t.Fatalf("byte %d: imported %d rows, appended %d", at, n, c.NumRows()-rows)
+				}
+			}
+
+			for i := 0; i < len(b); i += 7 {
+				corrupted := append([]byte(nil), b...)
+				corrupted[i] ^= 0xFF
+				check(corrupted, i)
+
+				check(b[:i], i)
+			}
+		})
+	}
+}
+
+// testParquetFile returns a file of a PLAIN encoded int32 page of count values 1 and 2 and the footer of meta.
+func testParquetFile(count int32, meta func(w *thriftWriter)) *bytes.Reader {
+	page := new(thriftWriter)
+	page.i32(1, parquetDataPage)
+	page.i32(2, 8)
+	page.i32(3, 8)
+	page.structBegin(5)
+	page.i32(1, count)
+	page.i32(2, parquetPlain)
+	page.structEnd()
+	page.stop()
+
+	footer := new(thriftWriter)
+	meta(footer)
+	footer.stop()
+
+	b := append(append([]byte(nil), parquetMagic...), page.buf...)
+	b = append(b, 1, 0, 0, 0, 2, 0, 0, 0)
+	b = append(b, footer.buf...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(footer.buf)))
+
+	return bytes.NewReader(append(b, parquetMagic...))
+}
+
+// testParquetSchema writes the schema of a required int32 count column.
+func testParquetSchema(w *thriftWriter) {
+	w.listBegin(2, thriftStruct, 2)
+	w.elemBegin()
+	w.binary(4, []byte("schema"))
+	w.elemEnd()
+	w.elemBegin()
+	w.i32(1, parquetInt32)
+	w.i32(3, parquetRequired)
+	w.binary(4, []byte("count"))
+	w.elemEnd()
+}
+
+// testParquetRowGroup writes a row group of rows rows, its column chunk pointing to the page of testParquetFile.
+func testParquetRowGroup(w *thriftWriter, rows int64) {
+	w.listBegin(4, thriftStruct, 1)
+	w.elemBegin()
+	w.listBegin(1, thriftStruct, 1)
+	w.elemBegin()
+	w.structBegin(3)
+	w.i32(4, parquetCodecNone)
+	w.i64(9, 4)
+	w.structEnd()
+	w.elemEnd()
+	w.i64(3, rows)
+	w.elemEnd()
+}
+
+func TestImportMalformedParquet(t *testing.T) {
+	c, err := CreateStorage[*exportRow, exportRow](NewMemoryStorage(nil), WithSidecars(NewMemorySidecars()))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	defer c.Close()
+
+	if n, err := c.Import(testParquetFile(2, func(w *thriftWriter) {
+		testParquetSchema(w)
+		testParquetRowGroup(w, 2)
+	}), ExportParquet); err != nil || n != 2 {
+		t.Fatalf("failed to import: %d %v", n, err)
+	}
+
+	for name, file := range map[string]*bytes.Reader{
+		"schema element": testParquetFile(2, func(w *thriftWriter) {
+			w.listBegin(2, thriftI32, 2)
+			w.varint(0)
+			w.varint(0)
+		}),
+		"row group": testParquetFile(2, func(w *thriftWriter) {
+			testParquetSchema(w)
+			w.listBegin(4, thriftI32, 1)
+			w.varint(0)
+		}),
+		"column chunk": testParquetFile(2, func(w *thriftWriter) {
+			testParquetSchema(w)
+			w.listBegin(4, thriftStruct, 1)
+			w.elemBegin()
+			w.listBegin(1, thriftI32, 1)
+			w.varint(0)
+			w.i64(3, 2)
+			w.elemEnd()
+		}),
+		"negative row count": testParquetFile(2, func(w *thriftWriter) {
+			testParquetSchema(w)
+			testParquetRowGroup(w, -1)
+		}),
+		"negative value count": testParquetFile(-1, func(w *thriftWriter) {
+			testParquetSchema(w)
+			testParquetRowGroup(w, 2)
+		}),
+		"value count over row count": testParquetFile(2, func(w *thriftWriter) {
+			testParquetSchema(w)
+			testParquetRowGroup(w, 1)
+		}),
+	} {
+		if _, err = c.Import(file, ExportParquet); !errors.Is(err, ErrCorrupted) {
+			t.Fatalf("%s: expected corrupted error, got %v", name, err)
+		}
+	}
+
+	if c.NumRows() != 2 {
+		t.Fatalf("failed imports appended rows, got %d", c.NumRows())
+	}
+}
+
+// TestDecodeParquetValues decodes the encodings of data pages the files of TestImportParquetFiles don't use
+// or use for a single physical type.
+func TestDecodeParquetValues(t *testing.T) {
+	boolean := parquetLeaf{physical: parquetBoolean}
+	int32Leaf := parquetLeaf{physical: parquetInt32, converted: -1}
+	byteArray := parquetLeaf{physical: parquetByteArray}
+
+	for _, test := range []struct {
+		name       string
+		leaf       parquetLeaf
+		encoding   int64
+		dictionary []any
+		data       []byte
+		expected   []any
+	}{
+		{"plain booleans", boolean, parquetPlain, nil, []byte{0b101}, []any{true, false, true}},
+		// a run of 3 trues then a bit-packed group
+		{"rle booleans", boolean, parquetRLE, nil, []byte{4, 0, 0, 0, 3 << 1, 1, 1<<1 | 1, 0b10},
+			[]any{true, true, true, false, true}},
+		{"plain int32", int32Leaf, parquetPlain, nil, []byte{1, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF}, []any{int32(1), int32(-1)}},
+		// indexes 2, 0, 1 bit-packed with a width of 2
+		{"rle dictionary", byteArray, parquetRLEDictionary, []any{[]byte("a"), []byte("b"), []byte("c")},
+			[]byte{2, 1<<1 | 1, 0b01_00_10, 0}, []any{[]byte("c"), []byte("a"), []byte("b")}},
+		// the example of the specification: deltas -2, -2, -2, 1, 1, 1, 1 are 0, 0, 0, 3, 3, 3, 3 over the min delta
+		{"delta binary packed", int32Leaf, parquetDeltaBinaryPacked, nil,
+			[]byte{128, 1, 4, 8, 14, 3, 2, 0, 0, 0, 0b11_00_00_00, 0b00_11_11_11, 0, 0, 0, 0, 0, 0},
+			[]any{int32(7), int32(5), int32(3), int32(1), int32(2), int32(3), int32(4), int32(5)}},
+		// lengths 1, 2
+		{"delta length byte array", byteArray, parquetDeltaLengthByteArray, nil,
+			append([]byte{128, 1, 4, 2, 2, 2, 0, 0, 0, 0}, "abc"...), []any{[]byte("a"), []byte("bc")}},
+		// prefixes 0, 1 and suffix lengths 2, 1
+		{"delta byte array", byteArray, parquetDeltaByteArray, nil,
+			append([]byte{128, 1, 4, 2, 0, 2, 0, 0, 0, 0, 128, 1, 4, 2, 4, 1, 0, 0, 0, 0}, "abc"...),
+			[]any{[]byte("ab"), []byte("ac")}},
+		// 1.0 and 2.0 split into 4 streams
+		{"byte stream split", parquetLeaf{physical: parquetFloat}, parquetByteStreamSplit, nil,
+			[]byte{0, 0, 0, 0, 0x80, 0, 0x3F, 0x40}, []any{float32(1), float32(2)}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := decodeParquetValues(test.data, test.leaf, test.encoding, test.dictionary, int64(len(test.expected)))
+			if err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+
+			if !reflect.DeepEqual(values, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, values)
+			}
+
+			// more values than encoded
+			if _, err = decodeParquetValues(test.data, test.leaf, test.encoding, test.dictionary, 100); !errors.Is(err, ErrCorrupted) {
+				t.Fatalf("expected corrupted error, got %v", err)
+			}
+
+			if _, err = decodeParquetValues(test.data, test.leaf, test.encoding, test.dictionary, -1); !errors.Is(err, ErrCorrupted) {
+				t.Fatalf("expected corrupted error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	c, err := Create[*testRowV2, testRowV2](filepath.Join(t.TempDir(), "import.sbt"))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	defer c.Close()
+
+	n, err := c.Import(strings.NewReader("Symbol,Price\nBTCUSDT,42\n"), ExportCSV)
+	if err != nil || n != 1 {
+		t.Fatalf("failed to import partial columns: %d %v", n, err)
+	}
+
+	row := new(testRowV2)
+	if err = c.ReadAt(0, row); err != nil || *row != (testRowV2{Price: 42, Symbol: "BTCUSDT"}) {
+		t.Fatalf("unexpected row %+v: %v", row, err)
+	}
+
+	for _, input := range []string{"Unknown\n1\n", "Price\n4294967296\n", "Price\nabc\n"} {
+		if _, err = c.Import(strings.NewReader(input), ExportCSV); err == nil {
+			t.Fatalf("expected error importing %q", input)
+		}
+	}
+
+	if _, err = c.Import(strings.NewReader(`{"Price":-1}`), ExportJSONL); err == nil {
+		t.Fatalf("expected overflow error")
+	}
+
+	// the rows flushed before the failure are rolled back
+	csv := "Price\n" + strings.Repeat("1\n", int(Bucket1k)*2) + "abc\n"
+	if n, err = c.Import(strings.NewReader(csv), ExportCSV); err == nil || n != 0 {
+		t.Fatalf("expected failed import, got %d rows: %v", n, err)
+	}
+
+	if _, err = c.Import(strings.NewReader("PAR1nope"), ExportParquet); !errors.Is(err, ErrUnsupportedParquet) {
+		t.Fatalf("expected unsupported parquet error, got %v", err)
+	}
+
+	if err = c.Export(new(bytes.Buffer), "xml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected unknown format error, got %v", err)
+	}
+
+	if c.NumRows() != 1 {
+		t.Fatalf("failed imports appended rows, got %d", c.NumRows())
+	}
+}
@@ -0,0 +1,150 @@
+package sbt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+const (
+	// FormatV0 stores the header as a JSON RowSpec, limiting column and row sizes to uint8.
+	FormatV0 uint8 = 0
+	// FormatV1 stores the header as a JSON object with uint32 column and row sizes.
+	FormatV1 uint8 = 1
+
+	// FormatVersion is the format version of created files.
+	FormatVersion = FormatV1
+
+	// formatVersionMask masks the format version in the flags byte.
+	formatVersionMask uint8 = 0x0F
+)
+
+// Feature flags, stored in the upper bits of the flags byte of FormatV1 files.
+const (
+	// FlagBlocks marks files using the block layout.
+	FlagBlocks uint8 = 1 << 4
+	// FlagColumnar marks block layout files storing each column contiguously in every block.
+	FlagColumnar uint8 = 1 << 5
+	// FlagChecksum marks files storing the CRC32C of every row, or of every block with the block layout.
+	FlagChecksum uint8 = 1 << 6
+	// FlagEncrypted marks block layout files whose block payloads and heap are encrypted.
+	FlagEncrypted uint8 = 1 << 7
+)
+
+// fileHeader is the JSON header of FormatV1 files.
+type fileHeader struct {
+	Columns RowSpec      `json:"columns"`
+	RowSize uint32       `json:"row_size"`
+	Block   *BlockConfig `json:"block,omitempty"`
+	Key     *KeyConfig   `json:"key,omitempty"`
+	// ByteOrder of the numeric columns, little endian if empty.
+	ByteOrder  ByteOrder         `json:"byte_order,omitempty"`
+	Encryption *EncryptionConfig `json:"encryption,omitempty"`
+}
+
+// newFileHeader returns the header of a new file and its flags.
+// The check of its EncryptionConfig is left to the caller.
+func newFileHeader(spec RowSpec, opts *Options) (h fileHeader, flags uint8) {
+	h = fileHeader{
+		Columns:   spec,
+		RowSize:   spec.RowSize(),
+		ByteOrder: opts.byteOrder,
+	}
+	flags = FormatVersion
+
+	if opts.block != nil {
+		h.Block = opts.block
+		flags |= FlagBlocks
+	}
+
+	if (opts.columnar || opts.encryptionKey != nil) && h.Block == nil {
+		h.Block = &BlockConfig{Rows: DefaultBlockRows, Compression: CompressionNone}
+		flags |= FlagBlocks
+	}
+
+	if opts.columnar {
+		flags |= FlagColumnar
+	}
+
+	if opts.encryptionKey != nil {
+		h.Encryption = &EncryptionConfig{Cipher: opts.cipher}
+		flags |= FlagEncrypted
+	}
+
+	if opts.checksum {
+		flags |= FlagChecksum
+	}
+
+	h.Key = opts.key
+
+	return
+}
+
+// marshalHeader encodes the header of a FormatVersion file.
+func marshalHeader(h fileHeader) ([]byte, error) {
+	return json.Marshal(h)
+}
+
+// unmarshalHeader decodes the header of a file with the given flags.
+func unmarshalHeader(flags uint8, b []byte) (h fileHeader, err error) {
+	switch flags & formatVersionMask {
+	case FormatV0:
+		if err = json.Unmarshal(b, &h.Columns); err != nil {
+			return
+		}
+
+		if h.Columns.RowSize() > math.MaxUint8 {
+			err = fmt.Errorf("row size %d overflows format v0", h.Columns.RowSize())
+			return
+		}
+
+		h.RowSize = h.Columns.RowSize()
+	case FormatV1:
+		if err = json.Unmarshal(b, &h); err != nil {
+			return
+		}
+
+		if h.Columns.RowSize() != h.RowSize {
+			err = fmt.Errorf("row size mismatch %d != %d", h.Columns.RowSize(), h.RowSize)
+			return
+		}
+	default:
+		err = fmt.Errorf("unsupported format version %d", flags&formatVersionMask)
+		return
+	}
+
+	if h.RowSize == 0 {
+		err = fmt.Errorf("empty row spec")
+		return
+	}
+
+	if err = h.Columns.validate(); err != nil {
+		return
+	}
+
+	if _, err = h.ByteOrder.binary(); err != nil {
+		return
+	}
+
+	if (flags&FlagBlocks != 0) != (h.Block != nil) {
+		err = fmt.Errorf("block flag doesn't match the header")
+		return
+	}
+
+	if flags&FlagColumnar != 0 && flags&FlagBlocks == 0 {
+		err = fmt.Errorf("columnar flag requires the block layout")
+		return
+	}
+
+	if (flags&FlagEncrypted != 0) != (h.Encryption != nil) {
+		err = fmt.Errorf("encrypted flag doesn't match the header")
+		return
+	}
+
+	if flags&FlagEncrypted != 0 && flags&FlagBlocks == 0 {
+		err = fmt.Errorf("encrypted flag requires the block layout")
+		return
+	}
+
+	return
+}
@@ -0,0 +1,96 @@
+package sbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testWideRow struct {
+	Text string `sbt:"text,str,300"`
+	ID   uint64 `sbt:"id"`
+}
+
+func TestWideRow(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "wide.sbt")
+
+	c, err := Create[*StructRow[testWideRow], StructRow[testWideRow]](filename)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	if c.Header().RowSize() != 308 || c.Version() != FormatVersion {
+		t.Fatalf("unexpected row size %d or version %d", c.Header().RowSize(), c.Version())
+	}
+
+	text := strings.Repeat("x", 300)
+	if err = c.Append(NewStructRow(testWideRow{Text: text, ID: 7})); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if c, err = OpenRead[*StructRow[testWideRow], StructRow[testWideRow]](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	row := new(StructRow[testWideRow])
+	if err = c.ReadAt(0, row); err != nil {
+		t.Fatalf("failed to read row: %v", err)
+	}
+
+	if row.Value.Text != text || row.Value.ID != 7 {
+		t.Fatalf("unexpected row %+v", row.Value)
+	}
+}
+
+func TestOpenFormatV0(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "v0.sbt")
+
+	header, err := json.Marshal((&testRowV1{}).Columns())
+	if err != nil {
+		t.Fatalf("failed to marshal header: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, MagicNumber)
+	binary.Write(buf, binary.LittleEndian, FormatV0)
+	binary.Write(buf, binary.LittleEndian, headerHash(header))
+	binary.Write(buf, binary.LittleEndian, uint32(len(header)))
+	buf.Write(header)
+
+	row := make([]byte, 12)
+	NewEncoder(row).EncodeStringPadded("BTCUSDT", 8)
+	binary.LittleEndian.PutUint32(row[8:], 42)
+	buf.Write(row)
+
+	if err = os.WriteFile(filename, buf.Bytes(), 0666); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	c, err := OpenRead[*testRowV1, testRowV1](filename)
+	if err != nil {
+		t.Fatalf("failed to open v0 file: %v", err)
+	}
+	defer c.Close()
+
+	if c.Version() != FormatV0 || c.NumRows() != 1 || c.Size() != int64(buf.Len()) {
+		t.Fatalf("unexpected version %d, rows %d or size %d", c.Version(), c.NumRows(), c.Size())
+	}
+
+	read := new(testRowV1)
+	if err = c.ReadAt(0, read); err != nil {
+		t.Fatalf("failed to read row: %v", err)
+	}
+
+	if *read != (testRowV1{Symbol: "BTCUSDT", Price: 42}) {
+		t.Fatalf("unexpected row %+v", read)
+	}
+}
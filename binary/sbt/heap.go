@@ -0,0 +1,150 @@
+package sbt
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// HeapExtension is appended to the Container filename to get its heap sidecar filename.
+const HeapExtension = ".heap"
+
+// HeapRefSize is the size of a variable-length column in the row, an uint64 offset and an uint32 length.
+const HeapRefSize = 12
+
+var ErrNoHeap = errors.New("variable-length column used without a heap")
+
+// SidecarExtensions returns the extensions of the files stored next to a Container file.
+func SidecarExtensions() []string {
+	return []string{HeapExtension, IndexExtension, TombstoneExtension, JournalExtension}
+}
+
+// Sidecars returns the existing sidecar files of a Container file, e.g. its heap, index, tombstones or journal.
+func Sidecars(filename string) (files []string) {
+	for _, ext := range SidecarExtensions() {
+		if _, err := os.Stat(filename + ext); err == nil {
+			files = append(files, filename+ext)
+		}
+	}
+
+	return
+}
+
+// heap stores the payloads of variable-length columns in a sidecar.
+//
+// Rows only hold an offset and a length into the heap, so they keep their fixed size.
+// The heap is append-only, payloads of overwritten rows are not reclaimed.
+// Reads are safe concurrently with the single writer reserving and flushing payloads.
+//
+// Payloads of encrypted files are sealed one by one, with their offset as additional data.
+type heap struct {
+	file    Storage
+	size    atomic.Int64
+	pending []byte
+	aead    cipher.AEAD
+}
+
+// openHeap opens the heap sidecar.
+func openHeap(sidecars SidecarSet, mode int) (h *heap, err error) {
+	h = &heap{}
+
+	if mode&(os.O_WRONLY|os.O_RDWR) != 0 {
+		mode |= os.O_CREATE
+	}
+
+	if h.file, err = sidecars.Open(HeapExtension, mode); err != nil {
+		err = fmt.Errorf("failed to open heap: %w", err)
+		return
+	}
+
+	if err = h.refresh(); err != nil {
+		h.file.Close()
+		return
+	}
+
+	return
+}
+
+// refresh re-reads the heap size to pick up payloads written by another process.
+func (h *heap) refresh() error {
+	size, err := h.file.Size()
+	if err != nil {
+		return fmt.Errorf("failed to get heap size: %w", err)
+	}
+
+	h.size.Store(size)
+
+	return nil
+}
+
+// reserve queues b to be written to the heap and returns its offset and stored length.
+func (h *heap) reserve(b []byte) (offset uint64, length uint32, err error) {
+	offset = uint64(h.size.Load()) + uint64(len(h.pending))
+
+	if h.aead != nil && len(b) > 0 {
+		if b, err = seal(h.aead, b, heapAD(offset)); err != nil {
+			return
+		}
+	}
+
+	h.pending = append(h.pending, b...)
+	length = uint32(len(b))
+
+	return
+}
+
+// flush writes the reserved payloads. It must be called before writing the rows referencing them.
+func (h *heap) flush() (err error) {
+	if len(h.pending) == 0 {
+		return
+	}
+
+	if _, err = h.file.WriteAt(h.pending, h.size.Load()); err != nil {
+		err = fmt.Errorf("failed to write heap: %w", err)
+		return
+	}
+
+	h.size.Add(int64(len(h.pending)))
+	h.pending = h.pending[:0]
+
+	return
+}
+
+// discard drops the reserved payloads.
+func (h *heap) discard() {
+	h.pending = h.pending[:0]
+}
+
+// read reads a payload from the heap.
+func (h *heap) read(offset uint64, length uint32) (b []byte, err error) {
+	if length == 0 {
+		return
+	}
+
+	if size := h.size.Load(); int64(offset)+int64(length) > size {
+		err = fmt.Errorf("heap reference out of bounds: %d+%d > %d", offset, length, size)
+		return
+	}
+
+	b = make([]byte, length)
+	if _, err = h.file.ReadAt(b, int64(offset)); err != nil {
+		err = fmt.Errorf("failed to read heap: %w", err)
+		return
+	}
+
+	if h.aead != nil {
+		if b, err = unseal(h.aead, b, heapAD(offset)); err != nil {
+			err = fmt.Errorf("failed to decrypt heap at %d: %v: %w", offset, err, ErrCorrupted)
+			return
+		}
+	}
+
+	return
+}
+
+// Close
+func (h *heap) Close() error {
+	return h.file.Close()
+}
@@ -0,0 +1,67 @@
+package sbt
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testVarRow struct {
+	ID      uint32 `sbt:"id"`
+	Message string `sbt:"message,vstr"`
+	Payload []byte `sbt:"payload,vbin"`
+}
+
+func TestHeapColumns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "heap.sbt")
+
+	c, err := Create[*StructRow[testVarRow], StructRow[testVarRow]](filename)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	long := strings.Repeat("sbt", 1000)
+	rows := []*StructRow[testVarRow]{
+		NewStructRow(testVarRow{ID: 1, Message: long, Payload: []byte{1, 2, 3}}),
+		NewStructRow(testVarRow{ID: 2}),
+		NewStructRow(testVarRow{ID: 3, Message: "short"}),
+	}
+
+	if err = c.BulkAppend(rows); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	if err = c.Set(NewStructRow(testVarRow{ID: 2, Message: "updated"}), 1); err != nil {
+		t.Fatalf("failed to set row: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if files := Sidecars(filename); len(files) != 1 || files[0] != filename+HeapExtension {
+		t.Fatalf("expected heap sidecar, got %v", files)
+	}
+
+	if c, err = OpenRead[*StructRow[testVarRow], StructRow[testVarRow]](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	read := make([]*StructRow[testVarRow], c.NumRows())
+	if _, err = c.BulkRead(0, read); err != nil {
+		t.Fatalf("failed to read rows: %v", err)
+	}
+
+	if read[0].Value.Message != long || string(read[0].Value.Payload) != "\x01\x02\x03" {
+		t.Fatalf("unexpected first row %+v", read[0].Value)
+	}
+
+	if read[1].Value.Message != "updated" || read[1].Value.Payload != nil {
+		t.Fatalf("unexpected second row %+v", read[1].Value)
+	}
+
+	if read[2].Value.Message != "short" {
+		t.Fatalf("unexpected third row %+v", read[2].Value)
+	}
+}
@@ -0,0 +1,283 @@
+package sbt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// JournalExtension is appended to the Container filename to get its journal sidecar filename.
+const JournalExtension = ".jnl"
+
+// journalMagic starts the journal sidecar.
+var journalMagic = []byte("SBTJ")
+
+// journal is the undo log of a commit: the size of the Container storage and of its sidecars
+// before the commit, and the bytes it overwrites.
+//
+// It's appended to the JournalExtension sidecar before the commit is applied, and the sidecar is cleared
+// once the commit is applied. Records found on open belong to commits that may be partially on disk,
+// they're undone from the last to the first.
+type journal struct {
+	entries []journalEntry
+}
+
+// journalEntry holds the state of the storage with the extension ext, "" for the Container storage.
+type journalEntry struct {
+	ext     string
+	size    int64
+	regions []journalRegion
+}
+
+// journalRegion holds the bytes at offset before the commit.
+type journalRegion struct {
+	offset int64
+	data   []byte
+}
+
+// add records the size of storage and the bytes of the given regions, clipped to its size.
+// Missing storages, e.g. tombstones before the first delete, are recorded as empty.
+func (j *journal) add(ext string, storage Storage, regions ...[2]int64) (err error) {
+	entry := journalEntry{ext: ext}
+
+	if storage != nil {
+		if entry.size, err = storage.Size(); err != nil {
+			return
+		}
+	}
+
+	for _, r := range regions {
+		offset, end := r[0], r[1]
+		if end > entry.size {
+			end = entry.size
+		}
+
+		if offset >= end {
+			continue
+		}
+
+		region := journalRegion{offset: offset, data: make([]byte, end-offset)}
+		if _, err = storage.ReadAt(region.data, offset); err != nil {
+			return
+		}
+
+		entry.regions = append(entry.regions, region)
+	}
+
+	j.entries = append(j.entries, entry)
+
+	return
+}
+
+// marshal encodes the journal as a record of the journal sidecar: the magic, the length of the body,
+// the body and the CRC32C of all of them.
+func (j *journal) marshal() []byte {
+	var body []byte
+	body = binary.AppendUvarint(body, uint64(len(j.entries)))
+
+	for _, e := range j.entries {
+		body = binary.AppendUvarint(body, uint64(len(e.ext)))
+		body = append(body, e.ext...)
+		body = binary.AppendVarint(body, e.size)
+		body = binary.AppendUvarint(body, uint64(len(e.regions)))
+
+		for _, r := range e.regions {
+			body = binary.AppendVarint(body, r.offset)
+			body = binary.AppendUvarint(body, uint64(len(r.data)))
+			body = append(body, r.data...)
+		}
+	}
+
+	b := append([]byte(nil), journalMagic...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(body)))
+	b = append(b, body...)
+
+	return binary.LittleEndian.AppendUint32(b, checksum(b))
+}
+
+// unmarshalJournals decodes the records of the journal sidecar, in the order they were written.
+// Decoding stops at the first torn record, which was being written when the process stopped.
+func unmarshalJournals(b []byte) (journals []*journal, err error) {
+	for len(b) > 0 {
+		headerSize := len(journalMagic) + 4
+		if len(b) < headerSize+checksumSize || string(b[:len(journalMagic)]) != string(journalMagic) {
+			return
+		}
+
+		size := int64(binary.LittleEndian.Uint32(b[len(journalMagic):]))
+		if int64(len(b)) < int64(headerSize)+size+checksumSize {
+			return
+		}
+
+		record := b[:int64(headerSize)+size]
+		if binary.LittleEndian.Uint32(b[len(record):]) != checksum(record) {
+			return
+		}
+
+		var j *journal
+		if j, err = unmarshalJournal(record[headerSize:]); err != nil {
+			return
+		}
+
+		journals = append(journals, j)
+		b = b[len(record)+checksumSize:]
+	}
+
+	return
+}
+
+// unmarshalJournal decodes the body of a record.
+func unmarshalJournal(b []byte) (j *journal, err error) {
+	r := &journalReader{b: b}
+	j = &journal{entries: make([]journalEntry, r.uvarint())}
+
+	for i := range j.entries {
+		e := &j.entries[i]
+		e.ext = string(r.bytes(r.uvarint()))
+		e.size = r.varint()
+		e.regions = make([]journalRegion, r.uvarint())
+
+		for k := range e.regions {
+			e.regions[k].offset = r.varint()
+			e.regions[k].data = r.bytes(r.uvarint())
+		}
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid journal: %w", r.err)
+	}
+
+	return
+}
+
+// journalReader decodes the fields of a journal, keeping the first error.
+type journalReader struct {
+	b   []byte
+	err error
+}
+
+// uvarint
+func (r *journalReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+
+	r.b = r.b[n:]
+
+	return v
+}
+
+// varint
+func (r *journalReader) varint() int64 {
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+
+	r.b = r.b[n:]
+
+	return v
+}
+
+// bytes
+func (r *journalReader) bytes(n uint64) []byte {
+	if n > uint64(len(r.b)) {
+		r.fail()
+		return nil
+	}
+
+	b := r.b[:n]
+	r.b = r.b[n:]
+
+	return b
+}
+
+// fail
+func (r *journalReader) fail() {
+	if r.err == nil {
+		r.err = io.ErrUnexpectedEOF
+	}
+
+	r.b = nil
+}
+
+// undo restores the recorded bytes and sizes of the Container storage and of its existing sidecars.
+func (j *journal) undo(storage Storage, sidecars SidecarSet) (err error) {
+	for _, e := range j.entries {
+		s := storage
+
+		if e.ext != "" {
+			if s, err = sidecars.Open(e.ext, os.O_RDWR); errors.Is(err, os.ErrNotExist) {
+				err = nil
+				continue
+			} else if err != nil {
+				return
+			}
+		}
+
+		for _, r := range e.regions {
+			if _, err = s.WriteAt(r.data, r.offset); err != nil {
+				break
+			}
+		}
+
+		if err == nil {
+			err = s.Truncate(e.size)
+		}
+
+		if err == nil {
+			err = s.Sync()
+		}
+
+		if e.ext != "" {
+			if cerr := s.Close(); err == nil {
+				err = cerr
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to restore %q: %w", e.ext, err)
+		}
+	}
+
+	return
+}
+
+// recoverJournal rolls back the commits whose records are in the journal sidecar, if any, and clears it.
+// A torn record belongs to a commit interrupted before being applied, it's ignored.
+func recoverJournal(storage Storage, sidecars SidecarSet) (err error) {
+	var file Storage
+	if file, err = sidecars.Open(JournalExtension, os.O_RDWR); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	var b []byte
+	if b, err = io.ReadAll(io.NewSectionReader(file, 0, 1<<62)); err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	var journals []*journal
+	if journals, err = unmarshalJournals(b); err != nil {
+		return fmt.Errorf("failed to recover journal: %w", err)
+	}
+
+	for i := len(journals) - 1; i >= 0; i-- {
+		if err = journals[i].undo(storage, sidecars); err != nil {
+			return fmt.Errorf("failed to recover journal: %w", err)
+		}
+	}
+
+	if err = file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to clear journal: %w", err)
+	}
+
+	return file.Sync()
+}
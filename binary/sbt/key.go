@@ -0,0 +1,478 @@
+package sbt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+// IndexExtension is appended to the Container filename to get its sparse index sidecar filename.
+const IndexExtension = ".idx"
+
+// indexEntrySize is the size of a sparse index entry, the int64 min and max keys of a chunk of rows.
+const indexEntrySize = 16
+
+var ErrNoKey = errors.New("container has no key column")
+
+// KeyConfig declares the key column of a Container file, stored in the header.
+type KeyConfig struct {
+	Column string `json:"column"`
+	// Monotonic keys never decrease from one row to the next, they are binary searched.
+	Monotonic bool `json:"monotonic"`
+	// IndexRows is the number of rows summarized by each entry of the sparse index of non-monotonic keys.
+	IndexRows uint32 `json:"index_rows,omitempty"`
+}
+
+// keyColumn reads the key column of a Container.
+type keyColumn struct {
+	config KeyConfig
+	column columnSlice
+	decode func([]byte) int64
+	index  *sparseIndex
+	// bias flips the sign bit of uint64 keys, so they're compared as unsigned.
+	bias int64
+}
+
+// keyDecoder returns the function decoding an integer, time or decimal column value as an int64 key, nil for other types.
+func keyDecoder(t ColumnType, order binary.ByteOrder) func([]byte) int64 {
+	switch t {
+	case ColumnTypeInt8:
+		return func(b []byte) int64 { return int64(int8(b[0])) }
+	case ColumnTypeInt16:
+		return func(b []byte) int64 { return int64(int16(order.Uint16(b))) }
+	case ColumnTypeInt32:
+		return func(b []byte) int64 { return int64(int32(order.Uint32(b))) }
+	case ColumnTypeInt64, ColumnTypeTime, ColumnTypeDecimal:
+		return func(b []byte) int64 { return int64(order.Uint64(b)) }
+	case ColumnTypeUInt8:
+		return func(b []byte) int64 { return int64(b[0]) }
+	case ColumnTypeUInt16:
+		return func(b []byte) int64 { return int64(order.Uint16(b)) }
+	case ColumnTypeUInt32:
+		return func(b []byte) int64 { return int64(order.Uint32(b)) }
+	case ColumnTypeUInt64:
+		return func(b []byte) int64 { return int64(order.Uint64(b)) }
+	}
+
+	return nil
+}
+
+// sparseIndex holds the min and max keys of every chunk of rows, persisted in a sidecar file if opened with one.
+type sparseIndex struct {
+	mu    sync.RWMutex
+	file  Storage
+	rows  int64
+	mins  []int64
+	maxs  []int64
+	dirty int
+}
+
+// entries returns a copy of the min and max keys of every chunk.
+func (x *sparseIndex) entries() (mins, maxs []int64) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	return append([]int64(nil), x.mins...), append([]int64(nil), x.maxs...)
+}
+
+// update widens the entries of the chunks holding the keys of the rows starting at pos.
+func (x *sparseIndex) update(pos int64, keys []int64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for i, key := range keys {
+		chunk := int((pos + int64(i)) / x.rows)
+
+		if chunk == len(x.mins) {
+			x.mins = append(x.mins, key)
+			x.maxs = append(x.maxs, key)
+		} else if key < x.mins[chunk] {
+			x.mins[chunk] = key
+		} else if key > x.maxs[chunk] {
+			x.maxs[chunk] = key
+		} else {
+			continue
+		}
+
+		if chunk < x.dirty {
+			x.dirty = chunk
+		}
+	}
+}
+
+// flush writes the updated entries to the sidecar file.
+func (x *sparseIndex) flush() (err error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.file == nil || x.dirty >= len(x.mins) {
+		return
+	}
+
+	b := make([]byte, (len(x.mins)-x.dirty)*indexEntrySize)
+	for i := range b[:len(b)/indexEntrySize] {
+		binary.LittleEndian.PutUint64(b[i*indexEntrySize:], uint64(x.mins[x.dirty+i]))
+		binary.LittleEndian.PutUint64(b[i*indexEntrySize+8:], uint64(x.maxs[x.dirty+i]))
+	}
+
+	if _, err = x.file.WriteAt(b, int64(x.dirty)*indexEntrySize); err != nil {
+		err = fmt.Errorf("failed to write index: %w", err)
+		return
+	}
+
+	x.dirty = len(x.mins)
+
+	return
+}
+
+// load reads the entries of the sidecar file, reporting false if they don't cover numRows rows.
+func (x *sparseIndex) load(numRows int64) (ok bool, err error) {
+	var size int64
+	if size, err = x.file.Size(); err != nil {
+		err = fmt.Errorf("failed to get index size: %w", err)
+		return
+	}
+
+	chunks := (numRows + x.rows - 1) / x.rows
+	if size != chunks*indexEntrySize {
+		return
+	}
+
+	b := make([]byte, size)
+	if _, err = x.file.ReadAt(b, 0); err != nil {
+		err = fmt.Errorf("failed to read index: %w", err)
+		return
+	}
+
+	x.mins = make([]int64, chunks)
+	x.maxs = make([]int64, chunks)
+
+	for i := range x.mins {
+		x.mins[i] = int64(binary.LittleEndian.Uint64(b[i*indexEntrySize:]))
+		x.maxs[i] = int64(binary.LittleEndian.Uint64(b[i*indexEntrySize+8:]))
+	}
+
+	x.dirty = len(x.mins)
+
+	return true, nil
+}
+
+// Close
+func (x *sparseIndex) Close() error {
+	if x.file == nil {
+		return nil
+	}
+
+	return x.file.Close()
+}
+
+// initKey sets up the key column declared in the header, or in the options if the header has none.
+//
+// The sparse index is persisted in a sidecar only if declared in the header of an unencrypted file,
+// it's rebuilt from the key column if the sidecar is missing or out of date. The index of encrypted files
+// would leak their keys, it's rebuilt in memory.
+func (c *Container[P, RowType]) initKey(mode int) (err error) {
+	config, persisted := c.keyConfig, c.aead == nil
+	if config == nil {
+		config, persisted = c.opts.key, false
+	}
+
+	if config == nil {
+		return
+	}
+
+	i := c.spec.Index(config.Column)
+	if i < 0 {
+		return fmt.Errorf("unknown key column %q", config.Column)
+	}
+
+	c.key = &keyColumn{
+		config: *config,
+		column: columnSlice{offset: int64(c.spec.Offset(i)), size: int64(c.spec[i].Size)},
+		decode: keyDecoder(c.spec[i].Type, c.order),
+	}
+
+	if c.key.decode == nil {
+		return fmt.Errorf("unsupported key column type %s", c.spec[i].Type)
+	}
+
+	if c.spec[i].Nullable {
+		return fmt.Errorf("key column %q can't be nullable", config.Column)
+	}
+
+	if c.spec[i].Type == ColumnTypeUInt64 {
+		decode := c.key.decode
+		c.key.bias = math.MinInt64
+		c.key.decode = func(b []byte) int64 { return decode(b) ^ math.MinInt64 }
+	}
+
+	if config.Monotonic {
+		return
+	}
+
+	if config.IndexRows == 0 {
+		return fmt.Errorf("invalid index rows %d", config.IndexRows)
+	}
+
+	c.key.index = &sparseIndex{rows: int64(config.IndexRows)}
+
+	if persisted {
+		if mode&(os.O_WRONLY|os.O_RDWR) != 0 {
+			mode |= os.O_CREATE
+		}
+
+		c.key.index.file, err = c.sidecars.Open(IndexExtension, mode)
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		} else if err != nil {
+			return fmt.Errorf("failed to open index: %w", err)
+		}
+	}
+
+	if c.key.index.file != nil {
+		var ok bool
+		if ok, err = c.key.index.load(c.NumRows()); ok || err != nil {
+			return
+		}
+
+		if mode&(os.O_WRONLY|os.O_RDWR) != 0 {
+			if err = c.key.index.file.Truncate(0); err != nil {
+				return fmt.Errorf("failed to truncate index: %w", err)
+			}
+		} else {
+			// out of date and read-only, rebuilt in memory
+			c.key.index.file.Close()
+			c.key.index.file = nil
+		}
+	}
+
+	return c.rebuildIndex()
+}
+
+// rebuildIndex rebuilds the sparse index from the key column.
+func (c *Container[P, RowType]) rebuildIndex() (err error) {
+	index := c.key.index
+	index.mins, index.maxs, index.dirty = nil, nil, 0
+
+	for pos := int64(0); pos < c.layout.numRows(); pos += index.rows {
+		n := c.layout.numRows() - pos
+		if n > index.rows {
+			n = index.rows
+		}
+
+		var keys []int64
+		if keys, err = c.readKeys(pos, n); err != nil {
+			return
+		}
+
+		index.update(pos, keys)
+	}
+
+	return index.flush()
+}
+
+// readKeys reads the keys of count rows starting at pos. The caller holds writeMu or swapMu.
+func (c *Container[P, RowType]) readKeys(pos, count int64) (keys []int64, err error) {
+	raw := make([]byte, count*c.key.column.size)
+	if err = c.layout.readColumn(pos, c.key.column, raw); err != nil {
+		err = fmt.Errorf("failed to read keys: %w", err)
+		return
+	}
+
+	keys = make([]int64, count)
+	for i := range keys {
+		keys[i] = c.key.decode(raw[int64(i)*c.key.column.size:])
+	}
+
+	return
+}
+
+// updateKeys updates the sparse index with the encoded rows written at pos.
+func (c *Container[P, RowType]) updateKeys(pos int64, rows []byte) {
+	if c.key == nil || c.key.index == nil {
+		return
+	}
+
+	rowSize := int64(c.spec.RowSize())
+	keys := make([]int64, int64(len(rows))/rowSize)
+
+	for i := range keys {
+		keys[i] = c.key.decode(rows[int64(i)*rowSize+c.key.column.offset:])
+	}
+
+	c.key.index.update(pos, keys)
+}
+
+// Key returns the key column configuration, nil if the Container has no key column.
+func (c *Container[P, RowType]) Key() *KeyConfig {
+	if c.key == nil {
+		return nil
+	}
+
+	return &c.key.config
+}
+
+// SearchFirst returns the position of the first row not deleted whose key is greater than or equal to key,
+// NumRows if there's none. Keys of uint64 columns are compared as unsigned, they're given as int64(key).
+//
+// Monotonic keys are binary searched. Otherwise, only the chunks of rows whose sparse index entry
+// may hold such a key are read.
+func (c *Container[P, RowType]) SearchFirst(key int64) (pos int64, err error) {
+	if c.key == nil {
+		err = ErrNoKey
+		return
+	}
+
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+
+	if c.key.index != nil {
+		return c.searchIndex(key ^ c.key.bias)
+	}
+
+	if pos, err = c.searchFirst(key ^ c.key.bias); err != nil {
+		return
+	}
+
+	for n := c.layout.numRows(); pos < n && c.tombstones.isDeleted(pos); {
+		pos++
+	}
+
+	return
+}
+
+// searchFirst binary searches the first row whose monotonic key, biased, is greater than or equal to key,
+// deleted or not. The caller holds swapMu.
+func (c *Container[P, RowType]) searchFirst(key int64) (pos int64, err error) {
+	n := c.layout.numRows()
+	pos = int64(sort.Search(int(n), func(i int) bool {
+		if err != nil {
+			return true
+		}
+
+		var keys []int64
+		if keys, err = c.readKeys(int64(i), 1); err != nil {
+			return true
+		}
+
+		return keys[0] >= key
+	}))
+
+	return
+}
+
+// searchIndex returns the position of the first row not deleted whose key, biased, is greater than or equal to key
+// using the sparse index.
+func (c *Container[P, RowType]) searchIndex(key int64) (pos int64, err error) {
+	index := c.key.index
+	_, maxs := index.entries()
+
+	for chunk, max := range maxs {
+		if max < key {
+			continue
+		}
+
+		start := int64(chunk) * index.rows
+		n := c.layout.numRows() - start
+		if n > index.rows {
+			n = index.rows
+		}
+
+		var keys []int64
+		if keys, err = c.readKeys(start, n); err != nil {
+			return
+		}
+
+		for i, k := range keys {
+			if k >= key && !c.tombstones.isDeleted(start+int64(i)) {
+				return start + int64(i), nil
+			}
+		}
+	}
+
+	return c.layout.numRows(), nil
+}
+
+// Range returns the ranges of rows not deleted whose key is in [from, to).
+// Keys of uint64 columns are compared as unsigned, they're given as int64(key).
+//
+// Monotonic keys give a single range found by binary search, split around deleted rows. Otherwise, the key column
+// of the chunks of rows whose sparse index entry overlaps [from, to) is scanned.
+func (c *Container[P, RowType]) Range(from, to int64) (ranges []RowRange, err error) {
+	if c.key == nil {
+		err = ErrNoKey
+		return
+	}
+
+	from, to = from^c.key.bias, to^c.key.bias
+	if from >= to {
+		return
+	}
+
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+
+	if c.key.index == nil {
+		var start, end int64
+		if start, err = c.searchFirst(from); err != nil {
+			return
+		}
+
+		if end, err = c.searchFirst(to); err != nil {
+			return
+		}
+
+		return c.appendLiveRows(ranges, start, end), nil
+	}
+
+	index := c.key.index
+	mins, maxs := index.entries()
+
+	for chunk := range mins {
+		if maxs[chunk] < from || mins[chunk] >= to {
+			continue
+		}
+
+		start := int64(chunk) * index.rows
+		n := c.layout.numRows() - start
+		if n > index.rows {
+			n = index.rows
+		}
+
+		var keys []int64
+		if keys, err = c.readKeys(start, n); err != nil {
+			return
+		}
+
+		for i, k := range keys {
+			if k >= from && k < to && !c.tombstones.isDeleted(start+int64(i)) {
+				ranges = appendRowRange(ranges, start+int64(i), 1)
+			}
+		}
+	}
+
+	return
+}
+
+// appendLiveRows appends the rows in [start, end) that aren't deleted to ranges.
+func (c *Container[P, RowType]) appendLiveRows(ranges []RowRange, start, end int64) []RowRange {
+	if c.NumDeleted() == 0 {
+		if end > start {
+			ranges = appendRowRange(ranges, start, end-start)
+		}
+
+		return ranges
+	}
+
+	for pos := start; pos < end; pos++ {
+		if !c.tombstones.isDeleted(pos) {
+			ranges = appendRowRange(ranges, pos, 1)
+		}
+	}
+
+	return ranges
+}
@@ -0,0 +1,233 @@
+package sbt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestKeyColumn(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "key.sbt")
+
+	c, err := Create[*testRowV2, testRowV2](filename, WithKeyColumn("Quantity"))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	rows := make([]*testRowV2, 1000)
+	for i := range rows {
+		rows[i] = &testRowV2{Price: uint32(i), Quantity: uint64(i * 10)}
+	}
+
+	if err = c.BulkAppend(rows); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if c, err = OpenRead[*testRowV2, testRowV2](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	if c.Key() == nil || c.Key().Column != "Quantity" || !c.Key().Monotonic {
+		t.Fatalf("unexpected key %+v", c.Key())
+	}
+
+	// uint64 keys are unsigned, -1 is the largest one
+	for key, expected := range map[int64]int64{-1: 1000, 0: 0, 55: 6, 60: 6, 9990: 999, 10000: 1000} {
+		pos, err := c.SearchFirst(key)
+		if err != nil || pos != expected {
+			t.Fatalf("expected %d for key %d, got %d: %v", expected, key, pos, err)
+		}
+	}
+
+	ranges, err := c.Range(100, 200)
+	if err != nil || !reflect.DeepEqual(ranges, []RowRange{{10, 10}}) {
+		t.Fatalf("unexpected ranges %v: %v", ranges, err)
+	}
+
+	if ranges, err = c.Range(10000, 20000); err != nil || len(ranges) != 0 {
+		t.Fatalf("unexpected ranges %v: %v", ranges, err)
+	}
+}
+
+func TestSparseIndex(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "index.sbt")
+
+	c, err := Create[*testRowV2, testRowV2](filename, WithSparseIndex("Price", 64))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	var prices []uint32
+	appendPrices := func(c *Container[*testRowV2, testRowV2], n int) {
+		rows := make([]*testRowV2, n)
+		for i := range rows {
+			price := uint32(len(prices)*7919) % 1000
+			prices = append(prices, price)
+			rows[i] = &testRowV2{Price: price}
+		}
+
+		if err := c.BulkAppend(rows); err != nil {
+			t.Fatalf("failed to append rows: %v", err)
+		}
+	}
+
+	check := func(c *Container[*testRowV2, testRowV2]) {
+		var expected []RowRange
+		first := int64(len(prices))
+
+		for i, price := range prices {
+			if price >= 100 && price < 110 {
+				expected = appendRowRange(expected, int64(i), 1)
+			}
+
+			if price >= 995 && first == int64(len(prices)) {
+				first = int64(i)
+			}
+		}
+
+		ranges, err := c.Range(100, 110)
+		if err != nil || !reflect.DeepEqual(ranges, expected) {
+			t.Fatalf("unexpected ranges %v != %v: %v", ranges, expected, err)
+		}
+
+		pos, err := c.SearchFirst(995)
+		if err != nil || pos != first {
+			t.Fatalf("expected first %d, got %d: %v", first, pos, err)
+		}
+	}
+
+	appendPrices(c, 1000)
+	check(c)
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if stat, err := os.Stat(filename + IndexExtension); err != nil || stat.Size() != 16*indexEntrySize {
+		t.Fatalf("unexpected index sidecar %v: %v", stat, err)
+	}
+
+	if c, err = Open[*testRowV2, testRowV2](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+
+	appendPrices(c, 100)
+
+	prices[5] = 105
+	if err = c.Set(&testRowV2{Price: 105}, 5); err != nil {
+		t.Fatalf("failed to set row: %v", err)
+	}
+
+	check(c)
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if err = os.Remove(filename + IndexExtension); err != nil {
+		t.Fatalf("failed to remove index: %v", err)
+	}
+
+	if c, err = OpenRead[*testRowV2, testRowV2](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	check(c)
+}
+
+func TestNoKey(t *testing.T) {
+	c, err := Create[*testRowV2, testRowV2](filepath.Join(t.TempDir(), "nokey.sbt"))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	defer c.Close()
+
+	if _, err = c.SearchFirst(0); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected no key error, got %v", err)
+	}
+}
+
+func TestKeyDeleted(t *testing.T) {
+	for name, option := range map[string]Option{
+		"monotonic": WithKeyColumn("Price"),
+		"index":     WithSparseIndex("Price", 4),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := Create[*testRowV2, testRowV2](filepath.Join(t.TempDir(), "deleted.sbt"), option)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer c.Close()
+
+			if err = c.BulkAppend(testTxRows(0, 20)); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			if err = c.DeleteRange(5, 3); err != nil {
+				t.Fatalf("failed to delete rows: %v", err)
+			}
+
+			if err = c.Delete(10); err != nil {
+				t.Fatalf("failed to delete row: %v", err)
+			}
+
+			for key, expected := range map[int64]int64{4: 4, 5: 8, 7: 8, 10: 11, 20: 20} {
+				pos, err := c.SearchFirst(key)
+				if err != nil || pos != expected {
+					t.Fatalf("expected %d for key %d, got %d: %v", expected, key, pos, err)
+				}
+			}
+
+			ranges, err := c.Range(3, 12)
+			if err != nil || !reflect.DeepEqual(ranges, []RowRange{{3, 2}, {8, 2}, {11, 1}}) {
+				t.Fatalf("unexpected ranges %v: %v", ranges, err)
+			}
+		})
+	}
+}
+
+func TestUnsignedKey(t *testing.T) {
+	for name, option := range map[string]Option{
+		"monotonic": WithKeyColumn("Quantity"),
+		"index":     WithSparseIndex("Quantity", 4),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := Create[*testRowV2, testRowV2](filepath.Join(t.TempDir(), "unsigned.sbt"), option)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer c.Close()
+
+			// keys from 1<<63 - 10 to 1<<63 + 9, the last ten don't fit an int64
+			rows := make([]*testRowV2, 20)
+			for i := range rows {
+				rows[i] = &testRowV2{Quantity: 1<<63 - 10 + uint64(i)}
+			}
+
+			if err = c.BulkAppend(rows); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			for key, expected := range map[uint64]int64{0: 0, 1<<63 - 1: 9, 1 << 63: 10, 1<<63 + 5: 15, 1<<64 - 1: 20} {
+				pos, err := c.SearchFirst(int64(key))
+				if err != nil || pos != expected {
+					t.Fatalf("expected %d for key %d, got %d: %v", expected, key, pos, err)
+				}
+			}
+
+			from, to := uint64(1<<63-2), uint64(1<<63+3)
+			ranges, err := c.Range(int64(from), int64(to))
+			if err != nil || !reflect.DeepEqual(ranges, []RowRange{{8, 5}}) {
+				t.Fatalf("unexpected ranges %v: %v", ranges, err)
+			}
+		})
+	}
+}
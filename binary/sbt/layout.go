@@ -0,0 +1,226 @@
+package sbt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+)
+
+// layout stores the encoded rows of the content section of a Container file.
+//
+// Rows are passed around row-major and back to back, whatever the layout stores on disk.
+//
+// Layouts are safe for a single writer calling writeRows, appendRows and flush concurrently
+// with readers, which only see rows once the call appending them returns.
+type layout interface {
+	// numRows returns the number of stored rows.
+	numRows() int64
+	// size returns the size of the file.
+	size() int64
+	// readRows reads len(b)/rowSize rows starting at pos into b.
+	readRows(pos int64, b []byte) error
+	// writeRows overwrites len(b)/rowSize existing rows starting at pos.
+	writeRows(pos int64, b []byte) error
+	// readColumn reads the values of col of len(b)/col.size rows starting at pos into b.
+	readColumn(pos int64, col columnSlice, b []byte) error
+	// appendRows appends len(b)/rowSize rows.
+	appendRows(b []byte) error
+	// flush writes any buffered rows to the file.
+	flush() error
+	// verify checks the stored rows against their checksums and the file size.
+	verify() (VerifyReport, error)
+	// refresh re-reads the file to pick up rows appended by another process.
+	refresh() error
+}
+
+// rowLayout stores rows back to back right after the header, the original layout.
+//
+// With FlagChecksum every row is followed by its uint32 CRC32C.
+type rowLayout struct {
+	file     Storage
+	offset   int64
+	rowSize  int64
+	stride   int64
+	checksum bool
+	rows     atomic.Int64
+}
+
+// newRowLayout returns the row layout of file, whose content starts at offset.
+func newRowLayout(file Storage, offset int64, rowSize uint32, flags uint8) (l *rowLayout, err error) {
+	l = &rowLayout{
+		file:     file,
+		offset:   offset,
+		rowSize:  int64(rowSize),
+		stride:   int64(rowSize),
+		checksum: flags&FlagChecksum != 0,
+	}
+
+	if l.checksum {
+		l.stride += checksumSize
+	}
+
+	err = l.refresh()
+
+	return
+}
+
+func (l *rowLayout) refresh() error {
+	size, err := l.file.Size()
+	if err != nil {
+		return fmt.Errorf("failed to get file size: %w", err)
+	}
+
+	// a partially written trailing row is ignored
+	l.rows.Store((size - l.offset) / l.stride)
+
+	return nil
+}
+
+func (l *rowLayout) numRows() int64 {
+	return l.rows.Load()
+}
+
+func (l *rowLayout) size() int64 {
+	return l.offset + l.rows.Load()*l.stride
+}
+
+func (l *rowLayout) readRows(pos int64, b []byte) error {
+	if !l.checksum {
+		if _, err := l.file.ReadAt(b, l.offset+pos*l.rowSize); err != nil {
+			return fmt.Errorf("failed to read rows: %w", err)
+		}
+
+		return nil
+	}
+
+	n := int64(len(b)) / l.rowSize
+	buf := make([]byte, n*l.stride)
+	if _, err := l.file.ReadAt(buf, l.offset+pos*l.stride); err != nil {
+		return fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	for i := int64(0); i < n; i++ {
+		row := buf[i*l.stride : i*l.stride+l.rowSize]
+		if binary.LittleEndian.Uint32(buf[i*l.stride+l.rowSize:]) != checksum(row) {
+			return fmt.Errorf("row %d: checksum mismatch: %w", pos+i, ErrCorrupted)
+		}
+
+		copy(b[i*l.rowSize:], row)
+	}
+
+	return nil
+}
+
+func (l *rowLayout) readColumn(pos int64, col columnSlice, b []byte) error {
+	rows := make([]byte, columnChunkRows*l.rowSize)
+
+	for len(b) > 0 {
+		n := int64(len(b)) / col.size
+		if n > columnChunkRows {
+			n = columnChunkRows
+		}
+
+		if err := l.readRows(pos, rows[:n*l.rowSize]); err != nil {
+			return err
+		}
+
+		strideCopy(b, rows[:n*l.rowSize], l.rowSize, col)
+
+		b = b[n*col.size:]
+		pos += n
+	}
+
+	return nil
+}
+
+func (l *rowLayout) writeRows(pos int64, b []byte) error {
+	if l.checksum {
+		n := int64(len(b)) / l.rowSize
+		buf := make([]byte, n*l.stride)
+
+		for i := int64(0); i < n; i++ {
+			row := b[i*l.rowSize : (i+1)*l.rowSize]
+			copy(buf[i*l.stride:], row)
+			binary.LittleEndian.PutUint32(buf[i*l.stride+l.rowSize:], checksum(row))
+		}
+
+		b = buf
+	}
+
+	if _, err := l.file.WriteAt(b, l.offset+pos*l.stride); err != nil {
+		return fmt.Errorf("failed to write rows: %w", err)
+	}
+
+	return nil
+}
+
+func (l *rowLayout) appendRows(b []byte) error {
+	if err := l.writeRows(l.rows.Load(), b); err != nil {
+		return err
+	}
+
+	l.rows.Add(int64(len(b)) / l.rowSize)
+
+	return nil
+}
+
+func (l *rowLayout) flush() error {
+	return nil
+}
+
+func (l *rowLayout) verify() (r VerifyReport, err error) {
+	r.Rows = l.rows.Load()
+
+	var size int64
+	if size, err = l.file.Size(); err != nil {
+		err = fmt.Errorf("failed to get file size: %w", err)
+		return
+	}
+
+	r.PartialBytes = size - l.size()
+
+	if !l.checksum {
+		return
+	}
+
+	buf := make([]byte, columnChunkRows*l.stride)
+
+	for pos := int64(0); pos < r.Rows; pos += columnChunkRows {
+		n := r.Rows - pos
+		if n > columnChunkRows {
+			n = columnChunkRows
+		}
+
+		if _, err = l.file.ReadAt(buf[:n*l.stride], l.offset+pos*l.stride); err != nil {
+			err = fmt.Errorf("failed to read rows: %w", err)
+			return
+		}
+
+		for i := int64(0); i < n; i++ {
+			row := buf[i*l.stride : i*l.stride+l.rowSize]
+			if binary.LittleEndian.Uint32(buf[i*l.stride+l.rowSize:]) != checksum(row) {
+				r.addCorrupted(pos+i, 1)
+			}
+		}
+	}
+
+	return
+}
+
+// columnChunkRows is the number of rows read at once when reading a column of row-major rows.
+const columnChunkRows = 4096
+
+// strideCopy copies the values of col out of the row-major rows in src into dst,
+// returning the number of copied values.
+func strideCopy(dst, src []byte, rowSize int64, col columnSlice) (n int64) {
+	n = int64(len(dst)) / col.size
+	if rows := int64(len(src)) / rowSize; rows < n {
+		n = rows
+	}
+
+	for r := int64(0); r < n; r++ {
+		copy(dst[r*col.size:(r+1)*col.size], src[r*rowSize+col.offset:])
+	}
+
+	return
+}
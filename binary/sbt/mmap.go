@@ -0,0 +1,148 @@
+package sbt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrReadOnly = errors.New("can't write to a read-only container")
+
+// rawLayout is implemented by layouts holding the encoded rows in memory.
+type rawLayout interface {
+	// rawRows returns count encoded rows starting at pos, without copying them.
+	rawRows(pos, count int64) []byte
+}
+
+// mmapLayout serves the rows of a row layout file from a read-only memory mapping.
+//
+// Mappings reserve twice the file size, so they follow the file as it grows and refresh only remaps it once
+// it outgrows them. Previous mappings are kept until Close since rows returned by rawRows may still point into
+// them, there are at most as many as the file doubled its size, and they're smaller than the last one together.
+type mmapLayout struct {
+	mu       sync.RWMutex
+	file     *FileStorage
+	data     []byte
+	previous [][]byte
+	// end is the size of the file when it was last refreshed, the end of the mapped content.
+	end     int64
+	offset  int64
+	rowSize int64
+	rows    atomic.Int64
+}
+
+// newMmapLayout maps file, whose content starts at offset.
+func newMmapLayout(file *FileStorage, offset int64, rowSize uint32) (l *mmapLayout, err error) {
+	l = &mmapLayout{
+		file:    file,
+		offset:  offset,
+		rowSize: int64(rowSize),
+	}
+
+	err = l.refresh()
+
+	return
+}
+
+func (l *mmapLayout) refresh() (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var size int64
+	if size, err = l.file.Size(); err != nil {
+		err = fmt.Errorf("failed to get file size: %w", err)
+		return
+	}
+
+	if size <= l.end {
+		return
+	}
+
+	if size > int64(len(l.data)) || !mmapShared {
+		var data []byte
+		if data, err = mmapFile(l.file.File, size, 2*size); err != nil {
+			err = fmt.Errorf("failed to map file: %w", err)
+			return
+		}
+
+		// memory read from the file is released by the garbage collector
+		if l.data != nil && mmapShared {
+			l.previous = append(l.previous, l.data)
+		}
+
+		l.data = data
+	}
+
+	l.end = size
+
+	// a partially written trailing row is ignored
+	l.rows.Store((size - l.offset) / l.rowSize)
+
+	return
+}
+
+func (l *mmapLayout) rawRows(pos, count int64) []byte {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	start := l.offset + pos*l.rowSize
+	return l.data[start : start+count*l.rowSize : start+count*l.rowSize]
+}
+
+func (l *mmapLayout) numRows() int64 {
+	return l.rows.Load()
+}
+
+func (l *mmapLayout) size() int64 {
+	return l.offset + l.rows.Load()*l.rowSize
+}
+
+func (l *mmapLayout) readRows(pos int64, b []byte) error {
+	copy(b, l.rawRows(pos, int64(len(b))/l.rowSize))
+	return nil
+}
+
+func (l *mmapLayout) readColumn(pos int64, col columnSlice, b []byte) error {
+	strideCopy(b, l.rawRows(pos, int64(len(b))/col.size), l.rowSize, col)
+	return nil
+}
+
+func (l *mmapLayout) writeRows(int64, []byte) error {
+	return ErrReadOnly
+}
+
+func (l *mmapLayout) appendRows([]byte) error {
+	return ErrReadOnly
+}
+
+func (l *mmapLayout) flush() error {
+	return nil
+}
+
+func (l *mmapLayout) verify() (VerifyReport, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return VerifyReport{Rows: l.numRows(), PartialBytes: l.end - l.size()}, nil
+}
+
+// Close unmaps the file.
+func (l *mmapLayout) Close() (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, data := range append(l.previous, l.data) {
+		if data == nil {
+			continue
+		}
+
+		if uerr := munmap(data); uerr != nil {
+			err = uerr
+		}
+	}
+
+	l.data, l.previous, l.end = nil, nil, 0
+
+	return
+}
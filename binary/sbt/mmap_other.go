@@ -0,0 +1,25 @@
+//go:build !unix
+
+package sbt
+
+import (
+	"io"
+	"os"
+)
+
+// mmapShared reports whether mmapFile maps the file, the mapping following it as it grows within its capacity,
+// rather than reading it into memory.
+const mmapShared = false
+
+// mmapFile reads the first size bytes of file into memory, where memory mapping isn't supported.
+func mmapFile(file *os.File, size, _ int64) (data []byte, err error) {
+	data = make([]byte, size)
+	_, err = io.ReadFull(io.NewSectionReader(file, 0, size), data)
+
+	return
+}
+
+// munmap releases data returned by mmapFile.
+func munmap([]byte) error {
+	return nil
+}
@@ -0,0 +1,120 @@
+package sbt
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMmap(t *testing.T) {
+	for name, options := range map[string][]Option{
+		"rows":   nil,
+		"blocks": {WithBlockCompression(CompressionZstd, 100)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "mmap.sbt")
+
+			c, err := Create[*TestRow, TestRow](filename, options...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+
+			appendTestRows(t, c, 0, 1000)
+
+			if err = c.Close(); err != nil {
+				t.Fatalf("failed to close container: %v", err)
+			}
+
+			if c, err = Open[*TestRow, TestRow](filename); err != nil {
+				t.Fatalf("failed to open container: %v", err)
+			}
+
+			expected, err := c.RawRow(555)
+			if err != nil {
+				t.Fatalf("failed to read raw row: %v", err)
+			}
+
+			if err = c.Close(); err != nil {
+				t.Fatalf("failed to close container: %v", err)
+			}
+
+			if c, err = OpenMmap[*TestRow, TestRow](filename); err != nil {
+				t.Fatalf("failed to map container: %v", err)
+			}
+			defer c.Close()
+
+			checkTestRows(t, c, 1000)
+
+			raw, err := c.RawRow(555)
+			if err != nil {
+				t.Fatalf("failed to read raw row: %v", err)
+			}
+
+			if !bytes.Equal(raw, expected) {
+				t.Fatalf("unexpected raw row %v != %v", raw, expected)
+			}
+
+			if err = c.Append(&TestRow{}); !errors.Is(err, ErrReadOnly) {
+				t.Fatalf("expected read-only error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMmapGrowth(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "growth.sbt")
+
+	w, err := Create[*TestRow, TestRow](filename)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	defer w.Close()
+
+	appendTestRows(t, w, 0, 1)
+
+	if err = w.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+
+	c, err := OpenMmap[*TestRow, TestRow](filename)
+	if err != nil {
+		t.Fatalf("failed to map container: %v", err)
+	}
+	defer c.Close()
+
+	first, err := c.RawRow(0)
+	if err != nil {
+		t.Fatalf("failed to read raw row: %v", err)
+	}
+
+	expected := append([]byte(nil), first...)
+
+	for i := 1; i < 1000; i++ {
+		appendTestRows(t, w, i, 1)
+
+		if err = w.Flush(); err != nil {
+			t.Fatalf("failed to flush: %v", err)
+		}
+
+		if err = c.Refresh(); err != nil {
+			t.Fatalf("failed to refresh: %v", err)
+		}
+
+		if c.NumRows() != int64(i+1) {
+			t.Fatalf("expected %d rows, got %d", i+1, c.NumRows())
+		}
+	}
+
+	checkTestRows(t, c, 1000)
+
+	// the file is only remapped when it doubled its size
+	if previous := len(c.layout.(*mmapLayout).previous); previous > 10 {
+		t.Fatalf("expected few previous mappings, got %d", previous)
+	}
+
+	// rows returned before remapping stay valid
+	if !bytes.Equal(first, expected) {
+		t.Fatalf("unexpected raw row %v != %v", first, expected)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package sbt
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapShared reports whether mmapFile maps the file, the mapping following it as it grows within its capacity,
+// rather than reading it into memory.
+const mmapShared = true
+
+// mmapFile maps capacity bytes of file read-only, of which the first size bytes can be read until the file grows.
+func mmapFile(file *os.File, size, capacity int64) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, int(capacity), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// munmap unmaps data returned by mmapFile.
+func munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
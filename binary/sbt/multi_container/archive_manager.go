@@ -3,7 +3,9 @@ package multi_container
 import (
 	"context"
 	"fmt"
+	"github.com/difof/goul/binary/sbt"
 	"github.com/difof/goul/fs"
+	"github.com/difof/goul/task"
 	"golang.org/x/sync/errgroup"
 	"os"
 	"path"
@@ -21,6 +23,7 @@ type ArchiveManager struct {
 	errs             *errgroup.Group
 	stopContext      context.Context
 	stopFunc         context.CancelFunc
+	retentionRunner  *task.TaskRunner
 }
 
 // NewArchiveManager creates a new archive manager
@@ -105,20 +108,22 @@ func (am *ArchiveManager) manageCompressionQueue() error {
 	}
 }
 
-// compressFile compresses a file
+// compressFile compresses a file along with its sidecars
 func (am *ArchiveManager) compressFile(filename string) error {
-	if err := fs.EasyGzip(filename); err != nil {
-		return fmt.Errorf("failed to compress file %s: %w", filename, err)
-	}
+	for _, f := range append(sbt.Sidecars(filename), filename) {
+		if err := fs.EasyGzip(f); err != nil {
+			return fmt.Errorf("failed to compress file %s: %w", f, err)
+		}
 
-	if err := os.Remove(filename); err != nil {
-		return fmt.Errorf("failed to remove file %s: %w", filename, err)
+		if err := os.Remove(f); err != nil {
+			return fmt.Errorf("failed to remove file %s: %w", f, err)
+		}
 	}
 
 	return nil
 }
 
-// decompressFile decompresses a file
+// decompressFile decompresses a file along with its sidecars
 func (am *ArchiveManager) decompressFile(filename string) (out string, err error) {
 	out, err = fs.EasyUnGzip(filename)
 	if err != nil {
@@ -126,6 +131,18 @@ func (am *ArchiveManager) decompressFile(filename string) (out string, err error
 		return
 	}
 
+	for _, ext := range sbt.SidecarExtensions() {
+		sidecar := out + ext + ".gz"
+		if !fs.Exists(sidecar) {
+			continue
+		}
+
+		if _, err = fs.EasyUnGzip(sidecar); err != nil {
+			err = fmt.Errorf("failed to decompress file %s: %w", sidecar, err)
+			return
+		}
+	}
+
 	return
 }
 
@@ -177,9 +194,13 @@ func (am *ArchiveManager) getIterableFilenames(start, end time.Time) (files []st
 		return
 	}
 
-	// group by filename
+	// group by filename, ignoring sidecars
 	mapped := map[string][]string{}
 	for _, f := range allFiles {
+		if !strings.HasSuffix(f, ".sbt") && !strings.HasSuffix(f, ".sbt.gz") {
+			continue
+		}
+
 		absFilename := f
 		switch filepath.Ext(f) {
 		case ".sbt":
@@ -241,6 +262,12 @@ func (am *ArchiveManager) getIterableFilenames(start, end time.Time) (files []st
 
 // Close closes the archive
 func (am *ArchiveManager) Close() error {
+	if am.retentionRunner != nil {
+		if err := am.retentionRunner.Close(); err != nil {
+			return err
+		}
+	}
+
 	am.stopFunc()
 	return am.errs.Wait()
 }
@@ -12,14 +12,21 @@ type MultiContainerFilenameParts struct {
 	prefix string
 	date   time.Time
 	unix   int64
+	// seq tells apart the files created in the same second, 0 for the first one.
+	seq int
 }
 
 // NewMultiContainerFilenamePartsFromNow creates a new MultiContainerFilenameParts from now
 func NewMultiContainerFilenamePartsFromNow(prefix string) MultiContainerFilenameParts {
+	return NewMultiContainerFilenameParts(prefix, time.Now())
+}
+
+// NewMultiContainerFilenameParts creates a new MultiContainerFilenameParts from t
+func NewMultiContainerFilenameParts(prefix string, t time.Time) MultiContainerFilenameParts {
 	return MultiContainerFilenameParts{
 		prefix: prefix,
-		date:   time.Now().In(time.UTC),
-		unix:   time.Now().Unix(),
+		date:   t.In(time.UTC),
+		unix:   t.Unix(),
 	}
 }
 
@@ -38,8 +45,18 @@ func (p MultiContainerFilenameParts) Unix() int64 {
 	return p.unix
 }
 
-// String returns the filename
+// Seq
+func (p MultiContainerFilenameParts) Seq() int {
+	return p.seq
+}
+
+// String returns the filename, the sequence number is appended after the unix time if not 0.
+// It's zero padded so filenames sort in creation order.
 func (p MultiContainerFilenameParts) String() string {
+	if p.seq > 0 {
+		return fmt.Sprintf("%s_%s_%d_%04d.sbt", p.prefix, p.date.Format("2006-01-02-15-04"), p.unix, p.seq)
+	}
+
 	return fmt.Sprintf("%s_%s_%d.sbt", p.prefix, p.date.Format("2006-01-02-15-04"), p.unix)
 }
 
@@ -55,11 +72,18 @@ func SplitMultiContainerFilename(filename string, tz *time.Location) (parts Mult
 	}
 
 	sparts := strings.Split(filenameParts[0], "_")
-	if len(sparts) != 3 {
+	if len(sparts) != 3 && len(sparts) != 4 {
 		err = fmt.Errorf("invalid filename format")
 		return
 	}
 
+	if len(sparts) == 4 {
+		if parts.seq, err = strconv.Atoi(sparts[3]); err != nil {
+			err = fmt.Errorf("invalid sequence format: %w", err)
+			return
+		}
+	}
+
 	parts.prefix = sparts[0]
 	parts.date, err = time.ParseInLocation("2006-01-02-15-04", sparts[1], tz)
 	if err != nil {
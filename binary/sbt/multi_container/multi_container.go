@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"path/filepath"
 	"runtime"
 	"sync"
 	"time"
 
 	"github.com/difof/goul/binary/sbt"
+	"github.com/difof/goul/fs"
 	"github.com/difof/goul/generics"
 	"github.com/difof/goul/generics/containers"
 	"github.com/difof/goul/task"
@@ -26,9 +28,13 @@ var ErrNoFileFound = errors.New("no file found")
 // with archive control to save space.
 //
 // It use a predefined filename format for identifying files.
-// The format is <prefix>_<date 2006-01-02-15-04>_<unix time>.sbt
+// The format is <prefix>_<date 2006-01-02-15-04>_<unix time>.sbt, or <prefix>_<date>_<unix time>_<sequence>.sbt
+// for the files created after the first one in the same second.
+//
+// Files are rotated on the WithCompressionScheduler timer, or on writes according to the WithRotation policies.
 type Container[P generics.Ptr[RowType], RowType any] struct {
 	container         *sbt.Container[P, RowType]
+	created           time.Time
 	am                *ArchiveManager
 	containerMutex    sync.Mutex
 	rootDir           string
@@ -58,10 +64,30 @@ func NewContainer[P generics.Ptr[RowType], RowType any](
 		return
 	}
 
+	// stop the goroutines started so far
+	defer func() {
+		if err != nil {
+			c.Close()
+		}
+	}()
+
 	if err = c.loadContainer(false); err != nil {
 		return
 	}
 
+	if c.opts.retention != nil {
+		retention := *c.opts.retention
+		if retention.OnError == nil {
+			retention.OnError = func(err error) {
+				c.opts.LogPrintf("Container (%s): retention failed: %v", c.prefix, err)
+			}
+		}
+
+		if err = c.am.StartRetention(retention); err != nil {
+			return
+		}
+	}
+
 	if c.opts.archiveDelaySec > 0 {
 		c.archiveTaskRunner, err = task.Every(c.opts.archiveDelaySec).Seconds().Do(c.archiveTask)
 		if err != nil {
@@ -74,14 +100,21 @@ func NewContainer[P generics.Ptr[RowType], RowType any](
 
 // loadContainer load a new container and close the current one.
 func (c *Container[P, RowType]) loadContainer(forceCreate bool) (err error) {
-	c.AcquireContainer()
-	defer c.ReleaseContainer()
+	c.containerMutex.Lock()
+	defer c.containerMutex.Unlock()
+
+	return c.openContainer(forceCreate)
+}
 
+// openContainer is loadContainer without locking.
+func (c *Container[P, RowType]) openContainer(forceCreate bool) (err error) {
 	if c.container != nil {
 		if err = c.closeContainer(); err != nil {
 			err = fmt.Errorf("Container (%s): failed to close containers: %w", c.prefix, err)
 			return
 		}
+
+		c.container = nil
 	}
 
 	if !forceCreate {
@@ -95,42 +128,169 @@ func (c *Container[P, RowType]) loadContainer(forceCreate bool) (err error) {
 		if lastFilename != "" {
 			c.opts.LogPrintf("Container (%s): opening last file %s", c.prefix, lastFilename)
 
+			var parts MultiContainerFilenameParts
+			if parts, err = SplitMultiContainerFilename(lastFilename, time.UTC); err != nil {
+				return
+			}
+
+			c.created = time.Unix(parts.Unix(), 0)
+
+			var container *sbt.Container[P, RowType]
 			if c.opts.openRead {
-				c.container, err = sbt.OpenRead[P, RowType](lastFilename)
+				container, err = sbt.OpenRead[P, RowType](lastFilename)
 			} else {
-				c.container, err = sbt.Open[P, RowType](lastFilename)
+				container, err = sbt.Open[P, RowType](lastFilename)
+			}
+
+			if err == nil {
+				c.container = container
 			}
 
 			return
 		}
 	}
 
-	filename := filepath.Join(c.rootDir, NewMultiContainerFilenamePartsFromNow(c.prefix).String())
+	var filename string
+	filename, c.created = c.nextFilename(time.Now())
 	c.opts.LogPrintf("Container (%s): creating %s", c.prefix, filename)
-	c.container, err = sbt.Create[P, RowType](filename)
+
+	var container *sbt.Container[P, RowType]
+	if container, err = sbt.Create[P, RowType](filename); err == nil {
+		c.container = container
+	}
 
 	return
 }
 
-// archiveTask
-func (c *Container[P, RowType]) archiveTask(*task.Task) error {
-	if c.container == nil {
-		return nil
+// nextFilename returns the filename of a file created at t and its creation time.
+// Files created in the same second, e.g. rotated by a policy, get the next sequence number.
+func (c *Container[P, RowType]) nextFilename(t time.Time) (filename string, created time.Time) {
+	created = t.Truncate(time.Second)
+	parts := NewMultiContainerFilenameParts(c.prefix, created)
+
+	for ; ; parts.seq++ {
+		filename = filepath.Join(c.rootDir, parts.String())
+		if !fs.Exists(filename) && !fs.Exists(filename+".gz") {
+			return
+		}
 	}
+}
 
+// rotate creates a new file, queueing the current one for compression.
+func (c *Container[P, RowType]) rotate() (err error) {
 	currentFilename := c.container.Filename()
 
+	if err = c.openContainer(true); err != nil {
+		return
+	}
+
+	c.am.QueueCompression(filepath.Join(c.rootDir, currentFilename))
+
+	return
+}
+
+// available returns how many rows can be written to the current file according to the rotation policies,
+// rotating it first if it's full.
+func (c *Container[P, RowType]) available() (n int64, err error) {
+	if c.container == nil {
+		err = fmt.Errorf("Container (%s): no open file", c.prefix)
+		return
+	}
+
+	if n = c.policyRows(); n > 0 {
+		return
+	}
+
 	if c.container.NumRows() > 0 {
-		if err := c.loadContainer(true); err != nil {
-			return err
+		c.opts.LogPrintf("Container (%s): rotating %s", c.prefix, c.container.Filename())
+
+		if err = c.rotate(); err != nil {
+			return
 		}
-	} else {
-		return nil
+
+		n = c.policyRows()
 	}
 
-	c.am.QueueCompression(filepath.Join(c.rootDir, currentFilename))
+	if n < 1 {
+		n = 1
+	}
 
-	return nil
+	return
+}
+
+// policyRows returns the minimum number of rows allowed by the rotation policies.
+func (c *Container[P, RowType]) policyRows() int64 {
+	n := int64(math.MaxInt64)
+	if len(c.opts.rotation) == 0 {
+		return n
+	}
+
+	stats := FileStats{
+		Filename: c.container.Filename(),
+		Created:  c.created,
+		Rows:     c.container.NumRows(),
+		Size:     c.container.Size(),
+		RowSize:  int64(c.container.Header().RowSize()),
+		Now:      time.Now(),
+	}
+
+	for _, policy := range c.opts.rotation {
+		if rows := policy(stats); rows < n {
+			n = rows
+		}
+	}
+
+	return n
+}
+
+// Append appends a row to the current file, rotating it first according to the rotation policies.
+func (c *Container[P, RowType]) Append(row P) (err error) {
+	c.containerMutex.Lock()
+	defer c.containerMutex.Unlock()
+
+	if _, err = c.available(); err != nil {
+		return
+	}
+
+	return c.container.Append(row)
+}
+
+// BulkAppend appends rows to the current file, rotating it according to the rotation policies,
+// so the rows may be split across files.
+func (c *Container[P, RowType]) BulkAppend(rows []P) (err error) {
+	c.containerMutex.Lock()
+	defer c.containerMutex.Unlock()
+
+	for len(rows) > 0 {
+		var n int64
+		if n, err = c.available(); err != nil {
+			return
+		}
+
+		if n > int64(len(rows)) {
+			n = int64(len(rows))
+		}
+
+		if err = c.container.BulkAppend(rows[:n]); err != nil {
+			return
+		}
+
+		rows = rows[n:]
+	}
+
+	return
+}
+
+// archiveTask
+func (c *Container[P, RowType]) archiveTask(*task.Task) error {
+	c.containerMutex.Lock()
+	defer c.containerMutex.Unlock()
+
+	if c.container == nil || c.container.NumRows() == 0 {
+		return nil
+	}
+
+	return c.rotate()
 }
 
 // closeContainer
@@ -163,8 +323,19 @@ func (c *Container[P, RowType]) Close() (err error) {
 
 // AcquireContainer returns the current container in a thread safe way.
 // MAKE SURE to call ReleaseContainer when done, otherwise the container will be locked forever.
+//
+// The current file is rotated first according to the rotation policies, so they apply to writes made directly
+// to the container, e.g. with a sbt.BulkAppendContext, from one acquisition to the next. A failed rotation
+// is logged, the container is nil if the new file couldn't be opened.
 func (c *Container[P, RowType]) AcquireContainer() *sbt.Container[P, RowType] {
 	c.containerMutex.Lock()
+
+	if c.container != nil {
+		if _, err := c.available(); err != nil {
+			c.opts.LogPrintf("Container (%s): failed to rotate: %v", c.prefix, err)
+		}
+	}
+
 	return c.container
 }
 
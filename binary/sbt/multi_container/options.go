@@ -9,6 +9,8 @@ type Options struct {
 	archiveDelaySec     int
 	compressionPoolSize int
 	openRead            bool
+	rotation            []RotationPolicy
+	retention           *Retention
 }
 
 // LogPrintf
@@ -40,6 +42,22 @@ func WithCompressionPoolSize(size int) Option {
 	}
 }
 
+// WithRotation rotates the current file once any of the policies says so, checked on every
+// Container.Append and Container.BulkAppend. Rotated files are queued for compression.
+func WithRotation(policies ...RotationPolicy) Option {
+	return func(o *Options) {
+		o.rotation = append(o.rotation, policies...)
+	}
+}
+
+// WithRetention deletes old archives according to retention, checked by the ArchiveManager
+// every retention.IntervalSec seconds. Retention errors are logged unless retention.OnError is set.
+func WithRetention(retention Retention) Option {
+	return func(o *Options) {
+		o.retention = &retention
+	}
+}
+
 // WithOpenRead sets the container to open the file for reading.
 // Defaults to false.
 func WithOpenRead() Option {
@@ -0,0 +1,174 @@
+package multi_container
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/difof/goul/binary/sbt"
+	"github.com/difof/goul/fs"
+	"github.com/difof/goul/task"
+)
+
+// DefaultRetentionIntervalSec is the default interval of the retention task in seconds.
+const DefaultRetentionIntervalSec = 60
+
+// Retention limits the archives kept in the root directory, enforced by the ArchiveManager on a schedule.
+//
+// Only compressed files are deleted along with their compressed sidecars, oldest first. Decompressed copies
+// may be in use by an iteration or by the compression of the file, so they're left alone. Zero limits are ignored.
+type Retention struct {
+	// KeepFiles is the number of most recent archives kept.
+	KeepFiles int
+	// MaxAge deletes the archives created more than MaxAge ago, according to their filename.
+	MaxAge time.Duration
+	// MaxBytes deletes the oldest archives until the files of the prefix, uncompressed ones included,
+	// take at most MaxBytes.
+	MaxBytes int64
+	// IntervalSec is the interval of the retention task in seconds, DefaultRetentionIntervalSec if 0.
+	IntervalSec int
+	// BeforeDelete is called with the files of an archive before deleting them, e.g. to ship them elsewhere.
+	// If it fails, the archive and the newer ones are kept until the next run.
+	BeforeDelete func(files []string) error
+	// OnError is called with the errors of the retention task, which keeps running.
+	OnError func(err error)
+}
+
+// archive is a compressed file of the prefix and the compressed sidecars deleted along with it.
+type archive struct {
+	filename string
+	created  time.Time
+	files    []string
+	size     int64
+}
+
+// StartRetention runs ApplyRetention every retention.IntervalSec seconds, until Close.
+func (am *ArchiveManager) StartRetention(retention Retention) (err error) {
+	interval := retention.IntervalSec
+	if interval <= 0 {
+		interval = DefaultRetentionIntervalSec
+	}
+
+	am.retentionRunner, err = task.Every(interval).Seconds().Do(func(*task.Task) error {
+		if err := am.ApplyRetention(retention); err != nil && retention.OnError != nil {
+			retention.OnError(err)
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// ApplyRetention deletes the archives exceeding the limits of retention.
+func (am *ArchiveManager) ApplyRetention(retention Retention) (err error) {
+	var archives []archive
+	if archives, err = am.archives(); err != nil {
+		return
+	}
+
+	var total int64
+	if retention.MaxBytes > 0 {
+		if total, err = am.prefixSize(); err != nil {
+			return
+		}
+	}
+
+	now := time.Now()
+
+	for i, a := range archives {
+		expired := retention.KeepFiles > 0 && i < len(archives)-retention.KeepFiles
+		expired = expired || retention.MaxAge > 0 && now.Sub(a.created) > retention.MaxAge
+		expired = expired || retention.MaxBytes > 0 && total > retention.MaxBytes
+
+		if !expired {
+			continue
+		}
+
+		if retention.BeforeDelete != nil {
+			if err = retention.BeforeDelete(a.files); err != nil {
+				return fmt.Errorf("before delete hook of %s failed: %w", a.filename, err)
+			}
+		}
+
+		for _, f := range a.files {
+			if err = os.Remove(f); err != nil {
+				return fmt.Errorf("failed to remove file %s: %w", f, err)
+			}
+		}
+
+		total -= a.size
+	}
+
+	return
+}
+
+// archives returns the compressed files of the prefix, oldest first.
+func (am *ArchiveManager) archives() (archives []archive, err error) {
+	var compressed []string
+	if compressed, err = am.globPrefixed(".sbt.gz"); err != nil {
+		return
+	}
+
+	for _, filename := range compressed {
+		var parts MultiContainerFilenameParts
+		if parts, err = SplitMultiContainerFilename(filename, time.UTC); err != nil {
+			err = fmt.Errorf("archives split filename error: %w", err)
+			return
+		}
+
+		if parts.Prefix() != am.prefix {
+			continue
+		}
+
+		a := archive{filename: filename, created: time.Unix(parts.Unix(), 0)}
+		decompressed := strings.TrimSuffix(filename, ".gz")
+
+		candidates := []string{filename}
+		for _, ext := range sbt.SidecarExtensions() {
+			candidates = append(candidates, decompressed+ext+".gz")
+		}
+
+		for _, f := range candidates {
+			if !fs.Exists(f) {
+				continue
+			}
+
+			var stat os.FileInfo
+			if stat, err = os.Stat(f); err != nil {
+				return
+			}
+
+			a.files = append(a.files, f)
+			a.size += stat.Size()
+		}
+
+		archives = append(archives, a)
+	}
+
+	return
+}
+
+// prefixSize returns the size of the files of the prefix, compressed or not.
+func (am *ArchiveManager) prefixSize() (size int64, err error) {
+	var files []string
+	if files, err = am.globPrefixed(".sbt*"); err != nil {
+		return
+	}
+
+	for _, f := range files {
+		if parts, err := SplitMultiContainerFilename(f, time.UTC); err != nil || parts.Prefix() != am.prefix {
+			continue
+		}
+
+		var stat os.FileInfo
+		if stat, err = os.Stat(f); err != nil {
+			return
+		}
+
+		size += stat.Size()
+	}
+
+	return
+}
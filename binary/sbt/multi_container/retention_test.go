@@ -0,0 +1,200 @@
+package multi_container
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/difof/goul/binary/sbt"
+)
+
+// createTestArchives creates compressed files of 100 bytes created at times, each with a compressed heap sidecar
+// of 10 bytes, and returns their filenames.
+func createTestArchives(t *testing.T, dir, prefix string, times ...time.Time) (archives []string) {
+	t.Helper()
+
+	for _, created := range times {
+		filename := filepath.Join(dir, NewMultiContainerFilenameParts(prefix, created).String())
+
+		for f, size := range map[string]int{filename + ".gz": 100, filename + sbt.HeapExtension + ".gz": 10} {
+			if err := os.WriteFile(f, make([]byte, size), 0666); err != nil {
+				t.Fatalf("failed to write %s: %v", f, err)
+			}
+		}
+
+		archives = append(archives, filename+".gz")
+	}
+
+	return
+}
+
+// remainingArchives returns the compressed files of dir, sidecars excluded.
+func remainingArchives(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.sbt.gz"))
+	if err != nil {
+		t.Fatalf("failed to list files: %v", err)
+	}
+
+	sort.Strings(files)
+
+	return files
+}
+
+func TestRetention(t *testing.T) {
+	now := time.Now()
+	times := []time.Time{now.Add(-5 * time.Hour), now.Add(-4 * time.Hour), now.Add(-3 * time.Hour),
+		now.Add(-2 * time.Hour), now.Add(-time.Hour)}
+
+	for name, test := range map[string]struct {
+		retention Retention
+		kept      int
+	}{
+		"keep files": {Retention{KeepFiles: 2}, 2},
+		"max age":    {Retention{MaxAge: 150 * time.Minute}, 2},
+		// 5 archives of 110 bytes and a current file of 100 bytes
+		"max bytes": {Retention{MaxBytes: 350}, 2},
+		"combined":  {Retention{KeepFiles: 4, MaxAge: 270 * time.Minute, MaxBytes: 1000}, 4},
+		"none":      {Retention{}, 5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			archives := createTestArchives(t, dir, "retention", times...)
+
+			current := filepath.Join(dir, NewMultiContainerFilenameParts("retention", now).String())
+			if err := os.WriteFile(current, make([]byte, 100), 0666); err != nil {
+				t.Fatalf("failed to write current file: %v", err)
+			}
+
+			// another prefix sharing the same start isn't touched
+			other := createTestArchives(t, dir, "retention2", times[0])
+
+			// neither is the decompressed copy of an archive, e.g. being iterated or compressed
+			decompressed := strings.TrimSuffix(archives[0], ".gz")
+			for _, f := range []string{decompressed, decompressed + sbt.HeapExtension} {
+				if err := os.WriteFile(f, nil, 0666); err != nil {
+					t.Fatalf("failed to write %s: %v", f, err)
+				}
+			}
+
+			am, err := NewArchiveManager(dir, "retention", 10, 0)
+			if err != nil {
+				t.Fatalf("failed to create archive manager: %v", err)
+			}
+			defer am.Close()
+
+			var deleted []string
+			test.retention.BeforeDelete = func(files []string) error {
+				if len(files) != 2 || files[1] != strings.TrimSuffix(files[0], ".gz")+sbt.HeapExtension+".gz" {
+					t.Errorf("unexpected archive files %v", files)
+				}
+
+				deleted = append(deleted, files[0])
+
+				return nil
+			}
+
+			if err = am.ApplyRetention(test.retention); err != nil {
+				t.Fatalf("failed to apply retention: %v", err)
+			}
+
+			expected := append(append([]string(nil), archives[len(archives)-test.kept:]...), other...)
+			sort.Strings(expected)
+
+			if remaining := remainingArchives(t, dir); strings.Join(remaining, ",") != strings.Join(expected, ",") {
+				t.Fatalf("expected archives %v, got %v", expected, remaining)
+			}
+
+			if len(deleted) != len(archives)-test.kept {
+				t.Fatalf("expected %d hook calls, got %v", len(archives)-test.kept, deleted)
+			}
+
+			for _, f := range []string{current, decompressed, decompressed + sbt.HeapExtension} {
+				if _, err = os.Stat(f); err != nil {
+					t.Fatalf("%s was deleted: %v", f, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRetentionHookFailure(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	archives := createTestArchives(t, dir, "retention", now.Add(-2*time.Hour), now.Add(-time.Hour))
+
+	am, err := NewArchiveManager(dir, "retention", 10, 0)
+	if err != nil {
+		t.Fatalf("failed to create archive manager: %v", err)
+	}
+	defer am.Close()
+
+	errShip := errors.New("ship failed")
+
+	err = am.ApplyRetention(Retention{
+		MaxAge: time.Minute,
+		BeforeDelete: func(files []string) error {
+			return errShip
+		},
+	})
+	if !errors.Is(err, errShip) {
+		t.Fatalf("expected hook error, got %v", err)
+	}
+
+	if remaining := remainingArchives(t, dir); len(remaining) != len(archives) {
+		t.Fatalf("expected archives to be kept, got %v", remaining)
+	}
+}
+
+func TestRetentionSchedule(t *testing.T) {
+	dir := t.TempDir()
+	createTestArchives(t, dir, "retention", time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+
+	mc, err := NewContainer[*TestMCRow, TestMCRow](dir, "retention",
+		WithRetention(Retention{KeepFiles: 1, IntervalSec: 1}),
+		WithCompressionPoolSize(0),
+	)
+	if err != nil {
+		t.Fatalf("failed to create multi container: %v", err)
+	}
+	defer mc.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(remainingArchives(t, dir)) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("retention didn't run, archives %v", remainingArchives(t, dir))
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestRetentionOpenFailure(t *testing.T) {
+	dir := t.TempDir()
+	archives := createTestArchives(t, dir, "retention", time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+
+	// the last file isn't a container
+	last := filepath.Join(dir, NewMultiContainerFilenamePartsFromNow("retention").String())
+	if err := os.WriteFile(last, []byte("garbage"), 0666); err != nil {
+		t.Fatalf("failed to write %s: %v", last, err)
+	}
+
+	_, err := NewContainer[*TestMCRow, TestMCRow](dir, "retention",
+		WithRetention(Retention{KeepFiles: 1, IntervalSec: 1}),
+		WithCompressionPoolSize(0),
+	)
+	if err == nil {
+		t.Fatalf("expected open error")
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	if remaining := remainingArchives(t, dir); len(remaining) != len(archives) {
+		t.Fatalf("retention ran after the failure, archives %v", remaining)
+	}
+}
@@ -0,0 +1,76 @@
+package multi_container
+
+import (
+	"math"
+	"time"
+)
+
+// FileStats describes the current file of a Container, passed to a RotationPolicy.
+type FileStats struct {
+	Filename string
+	// Created is the creation time of the file, from its name.
+	Created time.Time
+	Rows    int64
+	// Size is the size of the file, see sbt.Container.Size.
+	Size    int64
+	RowSize int64
+	// Now is the time of the write.
+	Now time.Time
+}
+
+// RotationPolicy returns how many more rows can be written to the current file before it's rotated.
+//
+// Policies are checked by Container.Append, Container.BulkAppend and Container.AcquireContainer: the file is
+// rotated once a policy returns 0 or less, unless it's empty, so every file holds at least one row.
+// Writes made to an acquired container aren't split, the file may grow over the policy until the next acquisition.
+type RotationPolicy func(stats FileStats) int64
+
+// MaxRows rotates files holding n rows.
+func MaxRows(n int64) RotationPolicy {
+	return func(stats FileStats) int64 {
+		return n - stats.Rows
+	}
+}
+
+// MaxBytes rotates files before they grow over n bytes.
+//
+// The size of block layout files doesn't count the rows buffered in their last block, so they may
+// grow over n by up to a block.
+func MaxBytes(n int64) RotationPolicy {
+	return func(stats FileStats) int64 {
+		if stats.RowSize == 0 {
+			return math.MaxInt64
+		}
+
+		return (n - stats.Size) / stats.RowSize
+	}
+}
+
+// Hourly rotates files created before the start of the current hour in tz.
+func Hourly(tz *time.Location) RotationPolicy {
+	return calendar(tz, func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+	})
+}
+
+// Daily rotates files created before the start of the current day in tz.
+func Daily(tz *time.Location) RotationPolicy {
+	return calendar(tz, func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	})
+}
+
+// calendar rotates files once the boundary following their creation, returned by next, is reached.
+func calendar(tz *time.Location, next func(time.Time) time.Time) RotationPolicy {
+	if tz == nil {
+		tz = time.UTC
+	}
+
+	return func(stats FileStats) int64 {
+		if stats.Now.Before(next(stats.Created.In(tz))) {
+			return math.MaxInt64
+		}
+
+		return 0
+	}
+}
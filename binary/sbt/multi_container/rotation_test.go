@@ -0,0 +1,184 @@
+package multi_container
+
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/difof/goul/binary/sbt"
+)
+
+func TestRotationPolicies(t *testing.T) {
+	tz, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+
+	created := time.Date(2024, 3, 1, 23, 15, 0, 0, tz)
+
+	for name, test := range map[string]struct {
+		policy   RotationPolicy
+		stats    FileStats
+		expected int64
+	}{
+		"rows":         {MaxRows(10), FileStats{Rows: 4}, 6},
+		"rows full":    {MaxRows(10), FileStats{Rows: 10}, 0},
+		"bytes":        {MaxBytes(1000), FileStats{Size: 640, RowSize: 18}, 20},
+		"bytes full":   {MaxBytes(1000), FileStats{Size: 990, RowSize: 18}, 0},
+		"hour":         {Hourly(tz), FileStats{Created: created, Now: created.Add(44 * time.Minute)}, math.MaxInt64},
+		"next hour":    {Hourly(tz), FileStats{Created: created, Now: created.Add(45 * time.Minute)}, 0},
+		"hour utc":     {Hourly(nil), FileStats{Created: created, Now: created.Add(45 * time.Minute)}, 0},
+		"day":          {Daily(tz), FileStats{Created: created, Now: created.Add(44 * time.Minute)}, math.MaxInt64},
+		"next day":     {Daily(tz), FileStats{Created: created, Now: created.Add(45 * time.Minute)}, 0},
+		"next day utc": {Daily(time.UTC), FileStats{Created: created, Now: created.Add(45 * time.Minute)}, math.MaxInt64},
+	} {
+		if n := test.policy(test.stats); n != test.expected {
+			t.Errorf("%s: expected %d rows, got %d", name, test.expected, n)
+		}
+	}
+}
+
+func TestRotationMaxRows(t *testing.T) {
+	dir := t.TempDir()
+
+	mc, err := NewContainer[*TestMCRow, TestMCRow](dir, "rotation",
+		WithRotation(MaxRows(10)),
+		WithCompressionPoolSize(0), // keep rotated files uncompressed
+	)
+	if err != nil {
+		t.Fatalf("failed to create multi container: %v", err)
+	}
+	defer mc.Close()
+
+	rows := make([]*TestMCRow, 25)
+	for i := range rows {
+		rows[i] = &TestMCRow{Name: "rotation", Value: uint64(i)}
+	}
+
+	if err = mc.BulkAppend(rows); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	if err = mc.Append(&TestMCRow{Name: "rotation", Value: 25}); err != nil {
+		t.Fatalf("failed to append row: %v", err)
+	}
+
+	if n := mc.AcquireContainer().NumRows(); n != 6 {
+		t.Errorf("expected 6 rows in the current file, got %d", n)
+	}
+	mc.ReleaseContainer()
+
+	files, err := filepath.Glob(filepath.Join(dir, "rotation_*.sbt"))
+	if err != nil {
+		t.Fatalf("failed to list files: %v", err)
+	}
+
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %v", files)
+	}
+
+	sort.Strings(files)
+	next := uint64(0)
+
+	for _, filename := range files {
+		// files rotated in the same second aren't dated in the future
+		parts, err := SplitMultiContainerFilename(filename, time.UTC)
+		if err != nil || parts.Unix() > time.Now().Unix() {
+			t.Fatalf("unexpected filename %s: %v", filename, err)
+		}
+
+		c, err := sbt.OpenRead[*TestMCRow, TestMCRow](filename)
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", filename, err)
+		}
+
+		for pos := int64(0); pos < c.NumRows(); pos++ {
+			row := new(TestMCRow)
+			if err = c.ReadAt(pos, row); err != nil {
+				t.Fatalf("failed to read row %d of %s: %v", pos, filename, err)
+			}
+
+			if row.Value != next {
+				t.Fatalf("expected value %d, got %d", next, row.Value)
+			}
+
+			next++
+		}
+
+		c.Close()
+	}
+
+	if next != 26 {
+		t.Fatalf("expected 26 rows, got %d", next)
+	}
+}
+
+func TestRotationAcquire(t *testing.T) {
+	dir := t.TempDir()
+
+	mc, err := NewContainer[*TestMCRow, TestMCRow](dir, "acquire",
+		WithRotation(MaxRows(10)),
+		WithCompressionPoolSize(0),
+	)
+	if err != nil {
+		t.Fatalf("failed to create multi container: %v", err)
+	}
+	defer mc.Close()
+
+	appender := sbt.NewBulkAppendContext[*TestMCRow, TestMCRow](5)
+
+	for i := 0; i < 25; i++ {
+		err := appender.Append(mc.AcquireContainer(), &TestMCRow{Name: "acquire", Value: uint64(i)})
+		mc.ReleaseContainer()
+
+		if err != nil {
+			t.Fatalf("failed to append row: %v", err)
+		}
+	}
+
+	err = appender.Close(mc.AcquireContainer())
+	mc.ReleaseContainer()
+
+	if err != nil {
+		t.Fatalf("failed to close appender: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "acquire_*.sbt"))
+	if err != nil {
+		t.Fatalf("failed to list files: %v", err)
+	}
+
+	sort.Strings(files)
+
+	var rows []int64
+	for _, filename := range files {
+		c, err := sbt.OpenRead[*TestMCRow, TestMCRow](filename)
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", filename, err)
+		}
+
+		rows = append(rows, c.NumRows())
+		c.Close()
+	}
+
+	if len(rows) != 3 || rows[0] != 10 || rows[1] != 10 || rows[2] != 5 {
+		t.Fatalf("expected 10, 10 and 5 rows, got %v", rows)
+	}
+}
+
+func TestFilenameSequence(t *testing.T) {
+	parts := NewMultiContainerFilenameParts("seq", time.Unix(1700000000, 0))
+	parts.seq = 12
+
+	split, err := SplitMultiContainerFilename(parts.String(), time.UTC)
+	if err != nil || split.Seq() != 12 || split.Unix() != 1700000000 || split.Prefix() != "seq" {
+		t.Fatalf("unexpected parts %+v of %s: %v", split, parts.String(), err)
+	}
+
+	first := NewMultiContainerFilenameParts("seq", time.Unix(1700000000, 0)).String()
+	if !sort.StringsAreSorted([]string{first, first + ".gz", parts.String()}) {
+		t.Fatalf("expected %s to sort after %s", parts.String(), first)
+	}
+}
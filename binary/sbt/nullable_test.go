@@ -0,0 +1,216 @@
+package sbt
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+type testNullableRow struct {
+	Symbol string
+	Price  *uint32
+	Note   *string
+}
+
+func (r *testNullableRow) Factory() Row {
+	return new(testNullableRow)
+}
+
+func (r *testNullableRow) Columns() RowSpec {
+	return NewRowSpec(
+		ColumnTypeString.New("Symbol", 8),
+		ColumnTypeUInt32.New("Price").AsNullable(),
+		ColumnTypeVarString.New("Note").AsNullable(),
+	)
+}
+
+func (r *testNullableRow) Encode(ctx *Encoder) error {
+	ctx.EncodeStringPadded(r.Symbol, 8)
+
+	if r.Price == nil {
+		if err := ctx.EncodeNull(); err != nil {
+			return err
+		}
+	} else {
+		ctx.EncodeUInt32(*r.Price)
+	}
+
+	if r.Note == nil {
+		return ctx.EncodeNull()
+	}
+
+	ctx.EncodeVarString(*r.Note)
+
+	return nil
+}
+
+func (r *testNullableRow) Decode(ctx *Decoder) (err error) {
+	r.Symbol = ctx.DecodeStringPadded(8)
+
+	r.Price = nil
+	if price := ctx.DecodeUInt32(); !ctx.IsNull(1) {
+		r.Price = &price
+	}
+
+	r.Note = nil
+	note, err := ctx.DecodeVarString()
+	if !ctx.IsNull(2) {
+		r.Note = &note
+	}
+
+	return
+}
+
+func testNullableRows(n int) []*testNullableRow {
+	rows := make([]*testNullableRow, n)
+	for i := range rows {
+		rows[i] = &testNullableRow{Symbol: "BTCUSDT"}
+
+		if i%2 == 0 {
+			price := uint32(i)
+			rows[i].Price = &price
+		}
+
+		if i%3 == 0 {
+			note := ""
+			if i%6 == 0 {
+				note = "note"
+			}
+			rows[i].Note = &note
+		}
+	}
+
+	return rows
+}
+
+func checkNullableRows(t *testing.T, c *Container[*testNullableRow, testNullableRow], rows []*testNullableRow) {
+	for i, expected := range rows {
+		row := new(testNullableRow)
+		if err := c.ReadAt(int64(i), row); err != nil {
+			t.Fatalf("failed to read row %d: %v", i, err)
+		}
+
+		if (row.Price == nil) != (expected.Price == nil) || row.Price != nil && *row.Price != *expected.Price ||
+			(row.Note == nil) != (expected.Note == nil) || row.Note != nil && *row.Note != *expected.Note {
+			t.Fatalf("row %d: expected %+v, got %+v", i, expected, row)
+		}
+	}
+}
+
+func TestNullableColumns(t *testing.T) {
+	spec := (&testNullableRow{}).Columns()
+	if spec.NullBitmapSize() != 1 || spec.RowSize() != 1+8+4+HeapRefSize || spec.Offset(0) != 1 {
+		t.Fatalf("unexpected spec sizes %d %d %d", spec.NullBitmapSize(), spec.RowSize(), spec.Offset(0))
+	}
+
+	for name, options := range map[string][]Option{"rows": nil, "columnar": {WithColumnar()}} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "nullable.sbt")
+
+			c, err := Create[*testNullableRow, testNullableRow](filename, options...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+
+			rows := testNullableRows(20)
+			if err = c.BulkAppend(rows); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			if err = c.Close(); err != nil {
+				t.Fatalf("failed to close container: %v", err)
+			}
+
+			if c, err = Open[*testNullableRow, testNullableRow](filename); err != nil {
+				t.Fatalf("failed to open container: %v", err)
+			}
+			defer c.Close()
+
+			checkNullableRows(t, c, rows)
+
+			prices, err := ReadColumn[uint32](c, "Price", 0, 3)
+			if err != nil || prices[0] != 0 || prices[1] != 0 || prices[2] != 2 {
+				t.Fatalf("unexpected prices %v: %v", prices, err)
+			}
+
+			a, err := OpenAny(filename)
+			if err != nil {
+				t.Fatalf("failed to open any container: %v", err)
+			}
+			defer a.Close()
+
+			row := new(AnyRow)
+			if err = a.ReadAt(1, row); err != nil {
+				t.Fatalf("failed to read row: %v", err)
+			}
+
+			if row.Get("Symbol") != "BTCUSDT" || row.Get("Price") != nil || row.Get("Note") != nil {
+				t.Fatalf("unexpected any row %v", row.Values)
+			}
+		})
+	}
+}
+
+func TestNullableExport(t *testing.T) {
+	for _, format := range []ExportFormat{ExportCSV, ExportJSONL, ExportParquet} {
+		t.Run(string(format), func(t *testing.T) {
+			dir := t.TempDir()
+
+			src, err := Create[*testNullableRow, testNullableRow](filepath.Join(dir, "src.sbt"))
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer src.Close()
+
+			// empty strings can't be told apart from nulls in CSV
+			rows := testNullableRows(20)
+			if format == ExportCSV {
+				for _, row := range rows {
+					if row.Note != nil && *row.Note == "" {
+						row.Note = nil
+					}
+				}
+			}
+
+			if err = src.BulkAppend(rows); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err = src.Export(&buf, format); err != nil {
+				t.Fatalf("failed to export: %v", err)
+			}
+
+			dst, err := Create[*testNullableRow, testNullableRow](filepath.Join(dir, "dst.sbt"))
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer dst.Close()
+
+			if _, err = dst.Import(&buf, format); err != nil {
+				t.Fatalf("failed to import: %v", err)
+			}
+
+			checkNullableRows(t, dst, rows)
+		})
+	}
+}
+
+func TestEncodeNullErrors(t *testing.T) {
+	e := NewEncoder(make([]byte, 4))
+	if err := e.EncodeNull(); !errors.Is(err, ErrNotNullable) {
+		t.Fatalf("expected not nullable error, got %v", err)
+	}
+
+	c, err := Create[*testRowV2, testRowV2](filepath.Join(t.TempDir(), "null.sbt"), WithSparseIndex("Price", 10))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	defer c.Close()
+
+	n, err := c.Import(bytes.NewBufferString(`{"Price":null}`), ExportJSONL)
+	if err != nil || n != 1 {
+		t.Fatalf("expected null to be imported as zero: %d %v", n, err)
+	}
+}
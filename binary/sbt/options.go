@@ -0,0 +1,173 @@
+package sbt
+
+import "time"
+
+type Options struct {
+	projectSchema bool
+	block         *BlockConfig
+	columnar      bool
+	mmap          bool
+	checksum      bool
+	key           *KeyConfig
+	tailInterval  time.Duration
+	byteOrder     ByteOrder
+	checks        CheckMode
+	sidecars      SidecarSet
+	durability    Durability
+	syncInterval  time.Duration
+	encryptionKey []byte
+	cipher        Cipher
+}
+
+type Option func(*Options)
+
+// newOptions applies the options over the defaults.
+func newOptions(options []Option) *Options {
+	o := &Options{byteOrder: LittleEndian, durability: DurabilityCommit}
+
+	for _, option := range options {
+		option(o)
+	}
+
+	if o.syncInterval == 0 {
+		o.syncInterval = DefaultSyncInterval
+	}
+
+	return o
+}
+
+// WithBlockCompression creates files using the block layout: rows are grouped into blocks of blockRows
+// rows, each compressed independently so any row can be read without decompressing the whole file.
+// blockRows defaults to DefaultBlockRows if 0. Ignored when opening existing files.
+//
+// Appended rows are buffered until their block is full, call Container.Flush to write them early.
+// Rows of full blocks can't be overwritten.
+func WithBlockCompression(compression Compression, blockRows uint32) Option {
+	return func(o *Options) {
+		if blockRows == 0 {
+			blockRows = DefaultBlockRows
+		}
+
+		o.block = &BlockConfig{
+			Rows:        blockRows,
+			Compression: compression,
+		}
+	}
+}
+
+// WithByteOrder creates files storing numeric columns in the given byte order, little endian by default.
+// The byte order is recorded in the header, so it's ignored when opening existing files.
+func WithByteOrder(order ByteOrder) Option {
+	return func(o *Options) {
+		o.byteOrder = order
+	}
+}
+
+// WithChecks sets the checks done by the Encoder and Decoder passed to rows, CheckNone by default.
+// Checked rows fail to be written or read instead of panicking or leaving stale bytes, at some cost.
+func WithChecks(mode CheckMode) Option {
+	return func(o *Options) {
+		o.checks = mode
+	}
+}
+
+// WithChecksum creates files storing the CRC32C of every row, or of every block with the block layout.
+// Corrupted rows fail to be read with ErrCorrupted and are reported by Container.Verify.
+// Ignored when opening existing files.
+func WithChecksum() Option {
+	return func(o *Options) {
+		o.checksum = true
+	}
+}
+
+// WithDurability sets when transactions are synced to durable storage, DurabilityCommit by default.
+func WithDurability(durability Durability) Option {
+	return func(o *Options) {
+		o.durability = durability
+	}
+}
+
+// WithSyncInterval syncs transactions with DurabilityInterval, at most every interval,
+// DefaultSyncInterval if 0.
+func WithSyncInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.durability = DurabilityInterval
+		o.syncInterval = interval
+	}
+}
+
+// WithEncryption creates files whose content is encrypted with key, a 16, 24 or 32 bytes key for
+// CipherAESGCM. It implies the block layout, uncompressed with DefaultBlockRows rows unless
+// WithBlockCompression is used: each block is sealed independently, so rows are still read at random.
+//
+// The key is required to open encrypted files, which fail with ErrEncrypted without it and with ErrWrongKey
+// with another one. It's ignored when opening files that aren't encrypted.
+func WithEncryption(key []byte) Option {
+	return func(o *Options) {
+		o.encryptionKey = key
+	}
+}
+
+// WithCipher sets the cipher of files created WithEncryption, CipherAESGCM by default.
+// Ignored when opening existing files.
+func WithCipher(cipher Cipher) Option {
+	return func(o *Options) {
+		o.cipher = cipher
+	}
+}
+
+// WithKeyColumn declares the integer, time or decimal column name as a monotonic key, e.g. a timestamp written with
+// Encoder.EncodeTime, so Container.SearchFirst and Container.Range can binary search it.
+//
+// The key is stored in the header of created files. When opening files declaring no key, it's only used in memory.
+func WithKeyColumn(name string) Option {
+	return func(o *Options) {
+		o.key = &KeyConfig{Column: name, Monotonic: true}
+	}
+}
+
+// WithSparseIndex declares the integer, time or decimal column name as a non-monotonic key, indexed by a sparse index
+// holding the min and max keys of every chunk of indexRows rows, stored in the IndexExtension sidecar.
+//
+// The key is stored in the header of created files. When opening files declaring no key,
+// the index is built in memory.
+func WithSparseIndex(name string, indexRows uint32) Option {
+	return func(o *Options) {
+		o.key = &KeyConfig{Column: name, IndexRows: indexRows}
+	}
+}
+
+// WithColumnar creates files storing each column contiguously per block, so ReadColumn only reads
+// the requested column. It implies the block layout, uncompressed with DefaultBlockRows rows unless
+// WithBlockCompression is used. Ignored when opening existing files.
+func WithColumnar() Option {
+	return func(o *Options) {
+		o.columnar = true
+	}
+}
+
+// WithSidecars stores the sidecars of the Container in the given SidecarSet, e.g. its heap, index and tombstones.
+// Containers opened from a file default to the files next to it, those opened from a Storage to memory.
+func WithSidecars(sidecars SidecarSet) Option {
+	return func(o *Options) {
+		o.sidecars = sidecars
+	}
+}
+
+// WithTailInterval sets the polling interval of Container.Tail, DefaultTailInterval if not set.
+func WithTailInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.tailInterval = interval
+	}
+}
+
+// WithSchemaProjection allows opening files whose stored RowSpec differs from the row type's Columns.
+//
+// Stored rows are projected onto the row type's layout by column name: removed columns are dropped,
+// new columns are zero filled and string/binary columns are truncated or padded to their new size.
+// Projected containers are read-only, use Migrate to rewrite a file into the new layout.
+func WithSchemaProjection() Option {
+	return func(o *Options) {
+		o.projectSchema = true
+	}
+}
@@ -0,0 +1,1510 @@
+package sbt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"time"
+)
+
+// Parquet support is limited to flat schemas of required and optional columns. Export writes uncompressed PLAIN
+// encoded data pages, with RLE/bit-packed hybrid encoded definition levels for optional columns. Import also reads
+// data page v2, dictionary, RLE, delta and byte stream split encodings and snappy, gzip and zstd compression,
+// rejecting anything else (nested or repeated columns, other codecs) with ErrUnsupportedParquet and malformed files
+// with ErrCorrupted.
+
+var (
+	parquetMagic = []byte("PAR1")
+
+	ErrUnsupportedParquet = errors.New("unsupported parquet file")
+)
+
+// parquetRowGroupRows is the number of rows per exported row group.
+const parquetRowGroupRows = 65536
+
+// parquet physical types
+const (
+	parquetBoolean           = 0
+	parquetInt32             = 1
+	parquetInt64             = 2
+	parquetFloat             = 4
+	parquetDouble            = 5
+	parquetByteArray         = 6
+	parquetFixedLenByteArray = 7
+)
+
+// parquet converted types
+const (
+	parquetUTF8            = 0
+	parquetDecimal         = 5
+	parquetTimestampMillis = 9
+	parquetTimestampMicros = 10
+	parquetUInt8           = 11
+	parquetUInt16          = 12
+	parquetUInt32          = 13
+	parquetUInt64          = 14
+	parquetInt8            = 15
+	parquetInt16           = 16
+	parquetInt32C          = 17
+)
+
+const (
+	parquetRequired = 0
+	parquetOptional = 1
+)
+
+// parquet encodings
+const (
+	parquetPlain                = 0
+	parquetPlainDictionary      = 2
+	parquetRLE                  = 3
+	parquetDeltaBinaryPacked    = 5
+	parquetDeltaLengthByteArray = 6
+	parquetDeltaByteArray       = 7
+	parquetRLEDictionary        = 8
+	parquetByteStreamSplit      = 9
+)
+
+// parquet page types
+const (
+	parquetDataPage       = 0
+	parquetDictionaryPage = 2
+	parquetDataPageV2     = 3
+)
+
+// parquet compression codecs
+const (
+	parquetCodecNone   = 0
+	parquetCodecSnappy = 1
+	parquetCodecGzip   = 2
+	parquetCodecZstd   = 6
+)
+
+// parquetType returns the physical and converted type of column, converted is -1 if there's none.
+func parquetType(column Column) (physical, converted int32, err error) {
+	switch column.Type {
+	case ColumnTypeBool:
+		return parquetBoolean, -1, nil
+	case ColumnTypeInt8:
+		return parquetInt32, parquetInt8, nil
+	case ColumnTypeInt16:
+		return parquetInt32, parquetInt16, nil
+	case ColumnTypeInt32:
+		return parquetInt32, parquetInt32C, nil
+	case ColumnTypeUInt8:
+		return parquetInt32, parquetUInt8, nil
+	case ColumnTypeUInt16:
+		return parquetInt32, parquetUInt16, nil
+	case ColumnTypeUInt32:
+		return parquetInt32, parquetUInt32, nil
+	case ColumnTypeInt64:
+		return parquetInt64, -1, nil
+	case ColumnTypeUInt64:
+		return parquetInt64, parquetUInt64, nil
+	case ColumnTypeFloat32:
+		return parquetFloat, -1, nil
+	case ColumnTypeFloat64:
+		return parquetDouble, -1, nil
+	case ColumnTypeString, ColumnTypeVarString:
+		return parquetByteArray, parquetUTF8, nil
+	case ColumnTypeBinary, ColumnTypeVarBinary, ColumnTypeUUID, ColumnTypeIPv4, ColumnTypeIPv6:
+		return parquetByteArray, -1, nil
+	case ColumnTypeDecimal:
+		return parquetInt64, parquetDecimal, nil
+	case ColumnTypeTime:
+		switch column.Precision {
+		case 3:
+			return parquetInt64, parquetTimestampMillis, nil
+		case 6:
+			return parquetInt64, parquetTimestampMicros, nil
+		}
+
+		return parquetInt64, -1, nil
+	}
+
+	return 0, 0, fmt.Errorf("unsupported column type %q", column.Type)
+}
+
+// toParquet converts a decoded value of column to the value of its parquet physical type.
+func toParquet(column Column, v any) any {
+	switch v := v.(type) {
+	case time.Time:
+		return timeUnits(v, column.Precision)
+	case Decimal:
+		return v.Unscaled
+	case UUID:
+		return v[:]
+	case netip.Addr:
+		if column.Type == ColumnTypeIPv4 {
+			b := v.Unmap().As4()
+			return b[:]
+		}
+
+		b := v.As16()
+		return b[:]
+	}
+
+	return v
+}
+
+// fromParquet converts a value of the parquet physical type of leaf to a value encodeValue accepts for column.
+func fromParquet(column Column, leaf parquetLeaf, v any) any {
+	switch column.Type {
+	case ColumnTypeTime:
+		i, ok := v.(int64)
+		if !ok {
+			return v
+		}
+
+		if leaf.precision != 0 {
+			return unitsTime(i, leaf.precision)
+		}
+
+		return unitsTime(i, column.Precision)
+	case ColumnTypeDecimal:
+		if leaf.converted != parquetDecimal {
+			break
+		}
+
+		switch i := v.(type) {
+		case int64:
+			return NewDecimal(i, leaf.scale)
+		case int32:
+			return NewDecimal(int64(i), leaf.scale)
+		}
+	}
+
+	return v
+}
+
+// parquetColumn buffers the PLAIN encoded values of a column within a row group.
+type parquetColumn struct {
+	physical  int32
+	converted int32
+	optional  bool
+	values    []byte
+	bits      int
+	levels    []byte
+	rows      int
+}
+
+// append PLAIN encodes v, nil values of optional columns only set their definition level to 0.
+func (p *parquetColumn) append(v any) {
+	if p.optional {
+		if p.rows%8 == 0 {
+			p.levels = append(p.levels, 0)
+		}
+
+		if v != nil {
+			p.levels[len(p.levels)-1] |= 1 << (p.rows % 8)
+		}
+
+		p.rows++
+
+		if v == nil {
+			return
+		}
+	}
+
+	switch p.physical {
+	case parquetBoolean:
+		if p.bits%8 == 0 {
+			p.values = append(p.values, 0)
+		}
+
+		if v.(bool) {
+			p.values[len(p.values)-1] |= 1 << (p.bits % 8)
+		}
+
+		p.bits++
+	case parquetInt32:
+		var i int64
+		switch v := v.(type) {
+		case uint8, uint16, uint32:
+			u, _ := uintValue(v, ColumnTypeUInt64)
+			i = int64(u)
+		default:
+			i, _ = intValue(v, ColumnTypeInt64)
+		}
+
+		p.values = binary.LittleEndian.AppendUint32(p.values, uint32(i))
+	case parquetInt64:
+		if u, ok := v.(uint64); ok {
+			p.values = binary.LittleEndian.AppendUint64(p.values, u)
+		} else {
+			p.values = binary.LittleEndian.AppendUint64(p.values, uint64(v.(int64)))
+		}
+	case parquetFloat:
+		p.values = binary.LittleEndian.AppendUint32(p.values, math.Float32bits(v.(float32)))
+	case parquetDouble:
+		p.values = binary.LittleEndian.AppendUint64(p.values, math.Float64bits(v.(float64)))
+	case parquetByteArray:
+		var b []byte
+		if s, ok := v.(string); ok {
+			b = []byte(s)
+		} else {
+			b = v.([]byte)
+		}
+
+		p.values = binary.LittleEndian.AppendUint32(p.values, uint32(len(b)))
+		p.values = append(p.values, b...)
+	}
+}
+
+// parquetWriter writes row groups to w, keeping track of the metadata for the footer.
+type parquetWriter struct {
+	w         io.Writer
+	offset    int64
+	rowGroups []byte
+	numGroups int
+	numRows   int64
+}
+
+func (p *parquetWriter) write(b []byte) (err error) {
+	_, err = p.w.Write(b)
+	p.offset += int64(len(b))
+
+	return
+}
+
+// writeRowGroup writes a row group of rows, one data page per column.
+func (p *parquetWriter) writeRowGroup(spec RowSpec, columns []parquetColumn, rows int64) (err error) {
+	group := new(thriftWriter)
+	group.listBegin(1, thriftStruct, len(columns))
+
+	var size int64
+	for i := range columns {
+		column := &columns[i]
+
+		// definition levels of optional columns, as a single bit-packed run of bit width 1
+		var levels []byte
+		if column.optional {
+			run := binary.AppendUvarint(nil, uint64(len(column.levels))<<1|1)
+			levels = binary.LittleEndian.AppendUint32(nil, uint32(len(run)+len(column.levels)))
+			levels = append(append(levels, run...), column.levels...)
+		}
+
+		pageSize := int32(len(levels) + len(column.values))
+
+		page := new(thriftWriter)
+		page.i32(1, parquetDataPage)
+		page.i32(2, pageSize)
+		page.i32(3, pageSize)
+		page.structBegin(5)
+		page.i32(1, int32(rows))
+		page.i32(2, parquetPlain)
+		page.i32(3, parquetRLE)
+		page.i32(4, parquetRLE)
+		page.structEnd()
+		page.stop()
+
+		pageOffset := p.offset
+		if err = p.write(page.buf); err != nil {
+			return
+		}
+
+		if err = p.write(levels); err != nil {
+			return
+		}
+
+		if err = p.write(column.values); err != nil {
+			return
+		}
+
+		chunkSize := int64(len(page.buf)) + int64(pageSize)
+		size += chunkSize
+
+		group.elemBegin()
+		group.i64(2, pageOffset)
+		group.structBegin(3)
+		group.i32(1, column.physical)
+		group.listBegin(2, thriftI32, 2)
+		group.varint(parquetPlain)
+		group.varint(parquetRLE)
+		group.listBegin(3, thriftBinary, 1)
+		group.bytes([]byte(spec[i].Name))
+		group.i32(4, parquetCodecNone)
+		group.i64(5, rows)
+		group.i64(6, chunkSize)
+		group.i64(7, chunkSize)
+		group.i64(9, pageOffset)
+		group.structEnd()
+		group.elemEnd()
+
+		column.values = column.values[:0]
+		column.bits = 0
+		column.levels = column.levels[:0]
+		column.rows = 0
+	}
+
+	group.i64(2, size)
+	group.i64(3, rows)
+	group.stop()
+
+	p.rowGroups = append(p.rowGroups, group.buf...)
+	p.numGroups++
+	p.numRows += rows
+
+	return
+}
+
+// writeFooter writes the file metadata and the trailing magic.
+func (p *parquetWriter) writeFooter(spec RowSpec, columns []parquetColumn) (err error) {
+	meta := new(thriftWriter)
+	meta.i32(1, 1)
+	meta.listBegin(2, thriftStruct, len(spec)+1)
+	meta.elemBegin()
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, int32(len(spec)))
+	meta.elemEnd()
+
+	for i, column := range spec {
+		meta.elemBegin()
+		meta.i32(1, columns[i].physical)
+		if columns[i].optional {
+			meta.i32(3, parquetOptional)
+		} else {
+			meta.i32(3, parquetRequired)
+		}
+		meta.binary(4, []byte(column.Name))
+		if columns[i].converted >= 0 {
+			meta.i32(6, columns[i].converted)
+		}
+		if columns[i].converted == parquetDecimal {
+			meta.i32(7, int32(column.Scale))
+			meta.i32(8, 18)
+		}
+		meta.elemEnd()
+	}
+
+	meta.i64(3, p.numRows)
+	meta.listBegin(4, thriftStruct, p.numGroups)
+	meta.buf = append(meta.buf, p.rowGroups...)
+	meta.binary(6, []byte("goul sbt"))
+	meta.stop()
+
+	if err = p.write(meta.buf); err != nil {
+		return
+	}
+
+	if err = p.write(binary.LittleEndian.AppendUint32(nil, uint32(len(meta.buf)))); err != nil {
+		return
+	}
+
+	return p.write(parquetMagic)
+}
+
+func (c *Container[P, RowType]) exportParquet(w io.Writer) (err error) {
+	columns := make([]parquetColumn, len(c.spec))
+	for i, column := range c.spec {
+		if columns[i].physical, columns[i].converted, err = parquetType(column); err != nil {
+			return
+		}
+
+		columns[i].optional = column.Nullable
+	}
+
+	p := &parquetWriter{w: w}
+	if err = p.write(parquetMagic); err != nil {
+		return
+	}
+
+	var rows int64
+	err = c.scanValues(func(values []any) (err error) {
+		for i, v := range values {
+			columns[i].append(toParquet(c.spec[i], v))
+		}
+
+		if rows++; rows == parquetRowGroupRows {
+			err = p.writeRowGroup(c.spec, columns, rows)
+			rows = 0
+		}
+
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	if rows > 0 {
+		if err = p.writeRowGroup(c.spec, columns, rows); err != nil {
+			return
+		}
+	}
+
+	return p.writeFooter(c.spec, columns)
+}
+
+// parquetLeaf is a column of an imported file.
+type parquetLeaf struct {
+	index     int
+	physical  int64
+	converted int64
+	size      int64
+	scale     uint8
+	precision uint8
+	optional  bool
+}
+
+// integer returns the integer value v of the physical type of l, unsigned if l has an unsigned converted type.
+func (l parquetLeaf) integer(v uint64) any {
+	switch {
+	case l.physical == parquetInt64 && l.converted == parquetUInt64:
+		return v
+	case l.physical == parquetInt64:
+		return int64(v)
+	case l.converted == parquetUInt8, l.converted == parquetUInt16, l.converted == parquetUInt32:
+		return uint32(v)
+	}
+
+	return int32(v)
+}
+
+// importParquet reads the whole file into memory, parquet metadata being stored at its end.
+func (c *Container[P, RowType]) importParquet(r io.Reader) (int64, error) {
+	return c.importRows(func(a *rowAppender[P, RowType]) (err error) {
+		var data []byte
+		if data, err = io.ReadAll(r); err != nil {
+			return fmt.Errorf("failed to read parquet file: %w", err)
+		}
+
+		size := len(data)
+		if size < 12 || !bytes.Equal(data[:4], parquetMagic) || !bytes.Equal(data[size-4:], parquetMagic) {
+			return fmt.Errorf("%w: bad magic", ErrUnsupportedParquet)
+		}
+
+		metaSize := int(binary.LittleEndian.Uint32(data[size-8:]))
+		if metaSize > size-12 {
+			return fmt.Errorf("%w: bad footer size %d", ErrCorrupted, metaSize)
+		}
+
+		var meta thriftFields
+		if meta, _, err = readThriftStruct(data[size-8-metaSize : size-8]); err != nil {
+			return fmt.Errorf("failed to read parquet metadata: %w", err)
+		}
+
+		var leaves []parquetLeaf
+		if leaves, err = c.parquetLeaves(meta.list(2)); err != nil {
+			return
+		}
+
+		values := make([]any, len(c.spec))
+		columns := make([][]any, len(leaves))
+
+		for _, group := range meta.list(4) {
+			group, ok := group.(thriftFields)
+			if !ok {
+				return fmt.Errorf("%w: bad row group", ErrCorrupted)
+			}
+
+			chunks := group.list(1)
+			rows := group.int(3)
+
+			if rows < 0 {
+				return fmt.Errorf("%w: bad row count %d", ErrCorrupted, rows)
+			}
+
+			if len(chunks) != len(leaves) {
+				return fmt.Errorf("%w: row group has %d columns, schema has %d", ErrCorrupted, len(chunks), len(leaves))
+			}
+
+			for i, chunk := range chunks {
+				chunk, ok := chunk.(thriftFields)
+				if !ok {
+					return fmt.Errorf("%w: bad column chunk", ErrCorrupted)
+				}
+
+				if columns[i], err = readParquetChunk(data, chunk, leaves[i], rows); err != nil {
+					return fmt.Errorf("column %q: %w", c.spec[leaves[i].index].Name, err)
+				}
+			}
+
+			for row := int64(0); row < rows; row++ {
+				for i := range values {
+					values[i] = nil
+				}
+
+				for i, leaf := range leaves {
+					values[leaf.index] = fromParquet(c.spec[leaf.index], leaf, columns[i][row])
+				}
+
+				if err = a.append(values); err != nil {
+					return
+				}
+			}
+		}
+
+		return
+	})
+}
+
+// parquetLeaves maps the schema elements of a flat parquet schema to the stored columns.
+func (c *Container[P, RowType]) parquetLeaves(schema []any) (leaves []parquetLeaf, err error) {
+	if len(schema) == 0 {
+		return nil, fmt.Errorf("%w: empty schema", ErrCorrupted)
+	}
+
+	for _, element := range schema[1:] {
+		element, ok := element.(thriftFields)
+		if !ok {
+			return nil, fmt.Errorf("%w: bad schema element", ErrCorrupted)
+		}
+
+		name := string(element.binary(4))
+
+		if element.has(5) {
+			return nil, fmt.Errorf("%w: nested column %q", ErrUnsupportedParquet, name)
+		}
+
+		repetition := element.int(3)
+		if repetition != parquetRequired && repetition != parquetOptional {
+			return nil, fmt.Errorf("%w: repeated column %q", ErrUnsupportedParquet, name)
+		}
+
+		leaf := parquetLeaf{index: c.spec.Index(name), physical: element.int(1), converted: -1}
+		if leaf.index < 0 {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+
+		if element.has(6) {
+			leaf.converted = element.int(6)
+		}
+
+		if leaf.size = element.int(2); leaf.physical == parquetFixedLenByteArray && leaf.size <= 0 {
+			return nil, fmt.Errorf("%w: bad fixed length %d of column %q", ErrCorrupted, leaf.size, name)
+		}
+
+		leaf.scale = uint8(element.int(7))
+		leaf.optional = repetition == parquetOptional
+
+		switch leaf.converted {
+		case parquetTimestampMillis:
+			leaf.precision = 3
+		case parquetTimestampMicros:
+			leaf.precision = 6
+		}
+
+		if logical, ok := element[10].(thriftFields); ok {
+			leaf.logicalType(logical)
+		}
+
+		leaves = append(leaves, leaf)
+	}
+
+	return
+}
+
+// logicalType fills in what the converted type of l doesn't tell from its logical type, newer writers only setting
+// the latter for nanosecond timestamps and some integers.
+func (l *parquetLeaf) logicalType(logical thriftFields) {
+	if decimal, ok := logical[5].(thriftFields); ok && l.converted < 0 {
+		l.converted = parquetDecimal
+		l.scale = uint8(decimal.int(1))
+	}
+
+	if timestamp, ok := logical[8].(thriftFields); ok {
+		unit, _ := timestamp[2].(thriftFields)
+		switch {
+		case unit.has(1):
+			l.precision = 3
+		case unit.has(2):
+			l.precision = 6
+		case unit.has(3):
+			l.precision = 9
+		}
+	}
+
+	if integer, ok := logical[10].(thriftFields); ok && l.converted < 0 {
+		if signed, _ := integer[2].(bool); !signed {
+			switch integer.int(1) {
+			case 8:
+				l.converted = parquetUInt8
+			case 16:
+				l.converted = parquetUInt16
+			case 32:
+				l.converted = parquetUInt32
+			case 64:
+				l.converted = parquetUInt64
+			}
+		}
+	}
+}
+
+// parquetChunk decodes the pages of a column chunk of rows values.
+type parquetChunk struct {
+	leaf       parquetLeaf
+	codec      int64
+	rows       int64
+	dictionary []any
+	values     []any
+}
+
+// readParquetChunk decodes the values of a column chunk.
+func readParquetChunk(data []byte, chunk thriftFields, leaf parquetLeaf, rows int64) (values []any, err error) {
+	meta, ok := chunk[3].(thriftFields)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing column metadata", ErrCorrupted)
+	}
+
+	// the row count of the metadata isn't trusted to preallocate the values
+	capacity := rows
+	if capacity > int64(len(data)) {
+		capacity = int64(len(data))
+	}
+
+	p := &parquetChunk{leaf: leaf, codec: meta.int(4), rows: rows, values: make([]any, 0, capacity)}
+
+	// the dictionary page, if any, comes first
+	offset := meta.int(9)
+	if dictionary := meta.int(11); dictionary > 0 && dictionary < offset {
+		offset = dictionary
+	}
+
+	for int64(len(p.values)) < rows {
+		if offset < 0 || offset >= int64(len(data)) {
+			return nil, fmt.Errorf("%w: bad page offset %d", ErrCorrupted, offset)
+		}
+
+		var header thriftFields
+		var n int
+		if header, n, err = readThriftStruct(data[offset:]); err != nil {
+			return nil, fmt.Errorf("failed to read page header: %w", err)
+		}
+
+		offset += int64(n)
+		pageSize := header.int(3)
+
+		if pageSize < 0 || offset+pageSize > int64(len(data)) {
+			return nil, fmt.Errorf("%w: bad page size %d", ErrCorrupted, pageSize)
+		}
+
+		if err = p.readPage(header, data[offset:offset+pageSize]); err != nil {
+			return
+		}
+
+		offset += pageSize
+	}
+
+	return p.values[:rows], nil
+}
+
+// readPage decodes a page of b, skipping index pages and pages of unknown types.
+func (p *parquetChunk) readPage(header thriftFields, b []byte) (err error) {
+	size := header.int(2)
+
+	switch header.int(1) {
+	case parquetDictionaryPage:
+		page, ok := header[7].(thriftFields)
+		if !ok {
+			return fmt.Errorf("%w: missing dictionary page header", ErrCorrupted)
+		}
+
+		if b, err = p.decompress(b, size); err != nil {
+			return
+		}
+
+		p.dictionary, err = decodeParquetPlain(nil, b, p.leaf, page.int(1))
+	case parquetDataPage:
+		page, ok := header[5].(thriftFields)
+		if !ok {
+			return fmt.Errorf("%w: missing data page header", ErrCorrupted)
+		}
+
+		if b, err = p.decompress(b, size); err != nil {
+			return
+		}
+
+		count := page.int(1)
+		if err = p.checkCount(count); err != nil {
+			return
+		}
+
+		var levels []uint64
+		if p.leaf.optional {
+			if levels, b, err = readParquetLevels(b, count); err != nil {
+				return
+			}
+		}
+
+		err = p.readValues(b, levels, page.int(2), count)
+	case parquetDataPageV2:
+		page, ok := header[8].(thriftFields)
+		if !ok {
+			return fmt.Errorf("%w: missing data page header", ErrCorrupted)
+		}
+
+		// levels come first and are never compressed
+		count, levelsSize := page.int(1), page.int(5)
+		if err = p.checkCount(count); err != nil {
+			return
+		}
+
+		if page.int(6) != 0 {
+			return fmt.Errorf("%w: repetition levels", ErrUnsupportedParquet)
+		}
+
+		if levelsSize < 0 || levelsSize > int64(len(b)) {
+			return fmt.Errorf("%w: bad definition levels size %d", ErrCorrupted, levelsSize)
+		}
+
+		var levels []uint64
+		if p.leaf.optional {
+			if levels, err = readParquetHybrid(b[:levelsSize], 1, count); err != nil {
+				return
+			}
+		}
+
+		b = b[levelsSize:]
+		if compressed, ok := page[7].(bool); !ok || compressed {
+			if b, err = p.decompress(b, size-levelsSize); err != nil {
+				return
+			}
+		}
+
+		err = p.readValues(b, levels, page.int(4), count)
+	}
+
+	return
+}
+
+// checkCount checks the value count of a data page fits in the rows of the chunk left to read.
+func (p *parquetChunk) checkCount(count int64) error {
+	if count < 0 || count > p.rows-int64(len(p.values)) {
+		return fmt.Errorf("%w: bad page value count %d", ErrCorrupted, count)
+	}
+
+	return nil
+}
+
+// decompress returns the size bytes b decompresses to with the codec of the chunk.
+func (p *parquetChunk) decompress(b []byte, size int64) (_ []byte, err error) {
+	if size < 0 || size > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: bad page size %d", ErrCorrupted, size)
+	}
+
+	switch p.codec {
+	case parquetCodecNone:
+	case parquetCodecSnappy:
+		b, err = snappyCompressor{}.decompress(b, int(size))
+	case parquetCodecGzip:
+		var r *gzip.Reader
+		if r, err = gzip.NewReader(bytes.NewReader(b)); err == nil {
+			b = make([]byte, size)
+			_, err = io.ReadFull(r, b)
+		}
+	case parquetCodecZstd:
+		b, err = zstdCompressor{}.decompress(b, int(size))
+	default:
+		return nil, fmt.Errorf("%w: compression codec %d", ErrUnsupportedParquet, p.codec)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decompress page: %v", ErrCorrupted, err)
+	}
+
+	if int64(len(b)) != size {
+		return nil, fmt.Errorf("%w: page decompressed to %d bytes, expected %d", ErrCorrupted, len(b), size)
+	}
+
+	return b, nil
+}
+
+// readValues appends the count values of a data page, nil where levels is 0 for optional columns.
+func (p *parquetChunk) readValues(b []byte, levels []uint64, encoding, count int64) (err error) {
+	defined := count
+	if levels != nil {
+		defined = 0
+		for _, level := range levels {
+			if level != 0 {
+				defined++
+			}
+		}
+	}
+
+	var values []any
+	if values, err = decodeParquetValues(b, p.leaf, encoding, p.dictionary, defined); err != nil {
+		return
+	}
+
+	if levels == nil {
+		p.values = append(p.values, values...)
+		return
+	}
+
+	for _, level := range levels {
+		if level != 0 {
+			p.values = append(p.values, values[0])
+			values = values[1:]
+		} else {
+			p.values = append(p.values, nil)
+		}
+	}
+
+	return
+}
+
+// decodeParquetValues decodes count values of b in encoding.
+func decodeParquetValues(b []byte, leaf parquetLeaf, encoding int64, dictionary []any, count int64) (values []any, err error) {
+	if count < 0 {
+		return nil, fmt.Errorf("%w: bad value count %d", ErrCorrupted, count)
+	}
+
+	switch encoding {
+	case parquetPlain:
+		return decodeParquetPlain(nil, b, leaf, count)
+	case parquetPlainDictionary, parquetRLEDictionary:
+		if len(b) < 1 {
+			return nil, fmt.Errorf("%w: short page", ErrCorrupted)
+		}
+
+		var indexes []uint64
+		if indexes, err = readParquetHybrid(b[1:], b[0], count); err != nil {
+			return
+		}
+
+		values = make([]any, len(indexes))
+		for i, index := range indexes {
+			if index >= uint64(len(dictionary)) {
+				return nil, fmt.Errorf("%w: dictionary index %d out of range", ErrCorrupted, index)
+			}
+
+			values[i] = dictionary[index]
+		}
+	case parquetRLE:
+		if leaf.physical != parquetBoolean {
+			return nil, fmt.Errorf("%w: RLE encoded physical type %d", ErrUnsupportedParquet, leaf.physical)
+		}
+
+		var bits []uint64
+		if bits, _, err = readParquetLevels(b, count); err != nil {
+			return
+		}
+
+		values = make([]any, len(bits))
+		for i, bit := range bits {
+			values[i] = bit != 0
+		}
+	case parquetDeltaBinaryPacked:
+		if leaf.physical != parquetInt32 && leaf.physical != parquetInt64 {
+			return nil, fmt.Errorf("%w: delta encoded physical type %d", ErrUnsupportedParquet, leaf.physical)
+		}
+
+		var ints []uint64
+		if ints, _, err = readParquetDelta(b, count); err != nil {
+			return
+		}
+
+		for _, v := range ints {
+			values = append(values, leaf.integer(v))
+		}
+	case parquetDeltaLengthByteArray, parquetDeltaByteArray:
+		if leaf.physical != parquetByteArray {
+			return nil, fmt.Errorf("%w: delta encoded physical type %d", ErrUnsupportedParquet, leaf.physical)
+		}
+
+		// prefix lengths shared with the previous value, then the suffixes length encoded
+		var prefixes []uint64
+		if encoding == parquetDeltaByteArray {
+			if prefixes, b, err = readParquetDelta(b, count); err != nil {
+				return
+			}
+		}
+
+		var lengths []uint64
+		if lengths, b, err = readParquetDelta(b, count); err != nil {
+			return
+		}
+
+		var prev []byte
+		for i, n := range lengths {
+			if n > uint64(len(b)) {
+				return nil, fmt.Errorf("%w: short page", ErrCorrupted)
+			}
+
+			v := b[:n:n]
+			b = b[n:]
+
+			if prefixes != nil {
+				if i >= len(prefixes) || prefixes[i] > uint64(len(prev)) {
+					return nil, fmt.Errorf("%w: bad delta prefix", ErrCorrupted)
+				}
+
+				v = append(prev[:prefixes[i]:prefixes[i]], v...)
+			}
+
+			values = append(values, v)
+			prev = v
+		}
+	case parquetByteStreamSplit:
+		// the bytes of each value are scattered into width streams
+		width := map[int64]int{parquetInt32: 4, parquetFloat: 4, parquetInt64: 8, parquetDouble: 8}[leaf.physical]
+		if leaf.physical == parquetFixedLenByteArray {
+			width = int(leaf.size)
+		}
+
+		if width == 0 {
+			return nil, fmt.Errorf("%w: byte stream split physical type %d", ErrUnsupportedParquet, leaf.physical)
+		}
+
+		n := len(b) / width
+		plain := make([]byte, n*width)
+		for i := 0; i < n; i++ {
+			for j := 0; j < width; j++ {
+				plain[i*width+j] = b[j*n+i]
+			}
+		}
+
+		return decodeParquetPlain(nil, plain, leaf, count)
+	default:
+		return nil, fmt.Errorf("%w: encoding %d", ErrUnsupportedParquet, encoding)
+	}
+
+	if int64(len(values)) < count {
+		return nil, fmt.Errorf("%w: short page", ErrCorrupted)
+	}
+
+	return values[:count], nil
+}
+
+// readParquetLevels reads count length prefixed RLE/bit-packed hybrid values of bit width 1, returning the rest of b.
+func readParquetLevels(b []byte, count int64) (levels []uint64, rest []byte, err error) {
+	if len(b) < 4 || uint64(binary.LittleEndian.Uint32(b)) > uint64(len(b)-4) {
+		return nil, nil, fmt.Errorf("%w: short definition levels", ErrCorrupted)
+	}
+
+	size := binary.LittleEndian.Uint32(b)
+	levels, err = readParquetHybrid(b[4:4+size], 1, count)
+
+	return levels, b[4+size:], err
+}
+
+// readParquetHybrid reads count RLE/bit-packed hybrid encoded values of bit width.
+func readParquetHybrid(b []byte, width uint8, count int64) (values []uint64, err error) {
+	short := fmt.Errorf("%w: short RLE/bit-packed data", ErrCorrupted)
+
+	if width > 32 {
+		return nil, fmt.Errorf("%w: bit width %d", ErrCorrupted, width)
+	}
+
+	for int64(len(values)) < count {
+		header, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, short
+		}
+		b = b[n:]
+
+		if header&1 == 0 {
+			// RLE run of a single value stored in whole bytes
+			size := int(width+7) / 8
+			if len(b) < size || header>>1 > uint64(count) {
+				return nil, short
+			}
+
+			var v uint64
+			for i := 0; i < size; i++ {
+				v |= uint64(b[i]) << (8 * i)
+			}
+			b = b[size:]
+
+			for i := uint64(0); i < header>>1 && int64(len(values)) < count; i++ {
+				values = append(values, v)
+			}
+
+			continue
+		}
+
+		// bit-packed groups of 8 values
+		groups := header >> 1
+		if width > 0 && groups > uint64(len(b))/uint64(width) || groups > uint64(count) {
+			return nil, short
+		}
+
+		remaining := count - int64(len(values))
+		if int64(groups)*8 < remaining {
+			remaining = int64(groups) * 8
+		}
+
+		size := int(groups) * int(width)
+		values = unpackParquetBits(values, b[:size], width, remaining)
+		b = b[size:]
+	}
+
+	return
+}
+
+// readParquetDelta reads at most limit DELTA_BINARY_PACKED encoded integers, returning the rest of b.
+func readParquetDelta(b []byte, limit int64) (values []uint64, rest []byte, err error) {
+	short := fmt.Errorf("%w: short delta encoded data", ErrCorrupted)
+
+	var header [3]uint64
+	for i := range header {
+		var n int
+		if header[i], n = binary.Uvarint(b); n <= 0 {
+			return nil, nil, short
+		}
+		b = b[n:]
+	}
+
+	blockSize, miniblocks, count := header[0], header[1], header[2]
+	if miniblocks == 0 || blockSize%miniblocks != 0 || blockSize/miniblocks%8 != 0 || count > uint64(limit) {
+		return nil, nil, fmt.Errorf("%w: bad delta header", ErrCorrupted)
+	}
+
+	first, n := binary.Varint(b)
+	if n <= 0 {
+		return nil, nil, short
+	}
+	b = b[n:]
+
+	capacity := count
+	if capacity > uint64(len(b)) {
+		capacity = uint64(len(b))
+	}
+
+	values = make([]uint64, 0, capacity)
+	if count > 0 {
+		values = append(values, uint64(first))
+	}
+
+	perMiniblock := blockSize / miniblocks
+	for uint64(len(values)) < count {
+		minDelta, n := binary.Varint(b)
+		if n <= 0 || uint64(len(b)-n) < miniblocks {
+			return nil, nil, short
+		}
+
+		widths := b[n : n+int(miniblocks)]
+		b = b[n+int(miniblocks):]
+
+		// the miniblocks after the last value are omitted
+		for _, width := range widths {
+			if uint64(len(values)) >= count {
+				break
+			}
+
+			if width > 64 {
+				return nil, nil, fmt.Errorf("%w: bit width %d", ErrCorrupted, width)
+			}
+
+			if width > 0 && perMiniblock > uint64(len(b))*8/uint64(width) {
+				return nil, nil, short
+			}
+
+			size := perMiniblock * uint64(width) / 8
+
+			n := count - uint64(len(values))
+			if perMiniblock < n {
+				n = perMiniblock
+			}
+
+			deltas := unpackParquetBits(nil, b[:size], width, int64(n))
+			for _, delta := range deltas {
+				values = append(values, values[len(values)-1]+uint64(minDelta)+delta)
+			}
+
+			b = b[size:]
+		}
+	}
+
+	return values, b, nil
+}
+
+// unpackParquetBits appends count values of width bits packed from the least significant bit of b.
+func unpackParquetBits(values []uint64, b []byte, width uint8, count int64) []uint64 {
+	for i := int64(0); i < count; i++ {
+		var v uint64
+		for j := uint64(0); j < uint64(width); j++ {
+			bit := uint64(i)*uint64(width) + j
+			v |= uint64(b[bit/8]>>(bit%8)&1) << j
+		}
+
+		values = append(values, v)
+	}
+
+	return values
+}
+
+// decodeParquetPlain appends count PLAIN encoded values of b.
+func decodeParquetPlain(values []any, b []byte, leaf parquetLeaf, count int64) ([]any, error) {
+	short := fmt.Errorf("%w: short page", ErrCorrupted)
+
+	for i := int64(0); i < count; i++ {
+		switch leaf.physical {
+		case parquetBoolean:
+			if i/8 >= int64(len(b)) {
+				return nil, short
+			}
+
+			values = append(values, b[i/8]&(1<<(i%8)) != 0)
+		case parquetInt32:
+			if len(b) < 4 {
+				return nil, short
+			}
+
+			values = append(values, leaf.integer(uint64(binary.LittleEndian.Uint32(b))))
+			b = b[4:]
+		case parquetInt64:
+			if len(b) < 8 {
+				return nil, short
+			}
+
+			values = append(values, leaf.integer(binary.LittleEndian.Uint64(b)))
+			b = b[8:]
+		case parquetFloat:
+			if len(b) < 4 {
+				return nil, short
+			}
+
+			values = append(values, math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			b = b[4:]
+		case parquetDouble:
+			if len(b) < 8 {
+				return nil, short
+			}
+
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+			b = b[8:]
+		case parquetByteArray:
+			if len(b) < 4 {
+				return nil, short
+			}
+
+			n := binary.LittleEndian.Uint32(b)
+			if uint64(n) > uint64(len(b)-4) {
+				return nil, short
+			}
+
+			values = append(values, b[4:4+n])
+			b = b[4+n:]
+		case parquetFixedLenByteArray:
+			if leaf.size > int64(len(b)) {
+				return nil, short
+			}
+
+			values = append(values, b[:leaf.size])
+			b = b[leaf.size:]
+		default:
+			return nil, fmt.Errorf("%w: physical type %d", ErrUnsupportedParquet, leaf.physical)
+		}
+	}
+
+	return values, nil
+}
+
+// thrift compact protocol types
+const (
+	thriftStop      = 0
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftByte      = 3
+	thriftI16       = 4
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftDouble    = 7
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftSet       = 10
+	thriftMap       = 11
+	thriftStruct    = 12
+)
+
+// thriftWriter writes structs in the thrift compact protocol.
+type thriftWriter struct {
+	buf   []byte
+	last  int16
+	stack []int16
+}
+
+func (w *thriftWriter) varint(v int64) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(v<<1)^uint64(v>>63))
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	if delta := id - w.last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.varint(int64(id))
+	}
+
+	w.last = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) bytes(b []byte) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *thriftWriter) binary(id int16, b []byte) {
+	w.field(id, thriftBinary)
+	w.bytes(b)
+}
+
+// listBegin writes the header of a list of size elements of type elem.
+func (w *thriftWriter) listBegin(id int16, elem byte, size int) {
+	w.field(id, thriftList)
+
+	if size < 15 {
+		w.buf = append(w.buf, byte(size)<<4|elem)
+	} else {
+		w.buf = append(w.buf, 0xF0|elem)
+		w.buf = binary.AppendUvarint(w.buf, uint64(size))
+	}
+}
+
+// structBegin starts a struct field.
+func (w *thriftWriter) structBegin(id int16) {
+	w.field(id, thriftStruct)
+	w.elemBegin()
+}
+
+// structEnd ends a struct field.
+func (w *thriftWriter) structEnd() {
+	w.elemEnd()
+}
+
+// elemBegin starts a struct list element.
+func (w *thriftWriter) elemBegin() {
+	w.stack = append(w.stack, w.last)
+	w.last = 0
+}
+
+// elemEnd ends a struct list element.
+func (w *thriftWriter) elemEnd() {
+	w.stop()
+	w.last = w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+}
+
+// stop ends the current struct.
+func (w *thriftWriter) stop() {
+	w.buf = append(w.buf, thriftStop)
+}
+
+// thriftFields holds the fields of a decoded struct by id.
+//
+// Values are bool, int64, float64, []byte, []any and thriftFields, maps are skipped.
+type thriftFields map[int16]any
+
+func (f thriftFields) has(id int16) bool {
+	_, ok := f[id]
+	return ok
+}
+
+func (f thriftFields) int(id int16) int64 {
+	v, _ := f[id].(int64)
+	return v
+}
+
+func (f thriftFields) binary(id int16) []byte {
+	v, _ := f[id].([]byte)
+	return v
+}
+
+func (f thriftFields) list(id int16) []any {
+	v, _ := f[id].([]any)
+	return v
+}
+
+// thriftReader reads values in the thrift compact protocol.
+type thriftReader struct {
+	buf    []byte
+	offset int
+	depth  int
+}
+
+var errThriftShort = fmt.Errorf("%w: truncated thrift data", ErrCorrupted)
+
+// readThriftStruct decodes the struct at the start of b, returning its size.
+func readThriftStruct(b []byte) (fields thriftFields, n int, err error) {
+	r := &thriftReader{buf: b}
+	fields, err = r.readStruct()
+
+	return fields, r.offset, err
+}
+
+func (r *thriftReader) byte() (b byte, err error) {
+	if r.offset >= len(r.buf) {
+		return 0, errThriftShort
+	}
+
+	b = r.buf[r.offset]
+	r.offset++
+
+	return
+}
+
+func (r *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf[r.offset:])
+	if n <= 0 {
+		return 0, errThriftShort
+	}
+
+	r.offset += n
+
+	return v, nil
+}
+
+func (r *thriftReader) varint() (int64, error) {
+	v, err := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (r *thriftReader) readStruct() (fields thriftFields, err error) {
+	if r.depth++; r.depth > 32 {
+		return nil, fmt.Errorf("%w: thrift data nested too deep", ErrCorrupted)
+	}
+	defer func() { r.depth-- }()
+
+	fields = make(thriftFields)
+	var id int16
+
+	for {
+		var header byte
+		if header, err = r.byte(); err != nil {
+			return
+		}
+
+		typ := header & 0x0F
+		if typ == thriftStop {
+			return
+		}
+
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			var v int64
+			if v, err = r.varint(); err != nil {
+				return
+			}
+
+			id = int16(v)
+		}
+
+		if fields[id], err = r.readValue(typ); err != nil {
+			return
+		}
+	}
+}
+
+func (r *thriftReader) readValue(typ byte) (v any, err error) {
+	switch typ {
+	case thriftBoolTrue:
+		return true, nil
+	case thriftBoolFalse:
+		return false, nil
+	case thriftByte:
+		var b byte
+		b, err = r.byte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return r.varint()
+	case thriftDouble:
+		if r.offset+8 > len(r.buf) {
+			return nil, errThriftShort
+		}
+
+		r.offset += 8
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.buf[r.offset-8:])), nil
+	case thriftBinary:
+		var n uint64
+		if n, err = r.uvarint(); err != nil {
+			return
+		}
+
+		if n > uint64(len(r.buf)-r.offset) {
+			return nil, errThriftShort
+		}
+
+		r.offset += int(n)
+		return r.buf[r.offset-int(n) : r.offset], nil
+	case thriftList, thriftSet:
+		return r.readList()
+	case thriftMap:
+		return nil, r.skipMap()
+	case thriftStruct:
+		return r.readStruct()
+	}
+
+	return nil, fmt.Errorf("%w: thrift type %d", ErrCorrupted, typ)
+}
+
+func (r *thriftReader) readList() (list []any, err error) {
+	var header byte
+	if header, err = r.byte(); err != nil {
+		return
+	}
+
+	size := uint64(header >> 4)
+	if size == 15 {
+		if size, err = r.uvarint(); err != nil {
+			return
+		}
+	}
+
+	if size > uint64(len(r.buf)-r.offset) {
+		return nil, errThriftShort
+	}
+
+	elem := header & 0x0F
+	list = make([]any, size)
+
+	for i := range list {
+		if elem == thriftBoolTrue || elem == thriftBoolFalse {
+			var b byte
+			b, err = r.byte()
+			list[i] = b == thriftBoolTrue
+		} else {
+			list[i], err = r.readValue(elem)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (r *thriftReader) skipMap() (err error) {
+	var size uint64
+	if size, err = r.uvarint(); err != nil || size == 0 {
+		return
+	}
+
+	var types byte
+	if types, err = r.byte(); err != nil {
+		return
+	}
+
+	if size > uint64(len(r.buf)-r.offset) {
+		return errThriftShort
+	}
+
+	for i := uint64(0); i < size; i++ {
+		if _, err = r.readValue(types >> 4); err != nil {
+			return
+		}
+
+		if _, err = r.readValue(types & 0x0F); err != nil {
+			return
+		}
+	}
+
+	return
+}
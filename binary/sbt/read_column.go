@@ -0,0 +1,108 @@
+package sbt
+
+import (
+	"fmt"
+
+	"github.com/difof/goul/generics"
+)
+
+// ReadColumn reads count values of the named column starting at row start, without decoding the other columns.
+//
+// T must match the column type: the Go types of the numeric and bool columns, string for str and vstr columns,
+// []byte for bin and vbin columns. Files created WithColumnar only read the requested column from disk.
+func ReadColumn[T any, P generics.Ptr[RowType], RowType any](
+	c *Container[P, RowType],
+	name string,
+	start, count int64,
+) (values []T, err error) {
+	i := c.spec.Index(name)
+	if i < 0 {
+		err = fmt.Errorf("unknown column %q", name)
+		return
+	}
+
+	column := c.spec[i]
+
+	var decodeErr error
+	decode, ok := columnDecoder(column, &decodeErr).(func(*Decoder) T)
+	if !ok {
+		err = fmt.Errorf("can't read %s column %q as %T", column.Type, name, *new(T))
+		return
+	}
+
+	raw := make([]byte, count*int64(column.Size))
+	col := columnSlice{offset: int64(c.spec.Offset(i)), size: int64(column.Size)}
+	if err = c.readColumn(start, col, raw); err != nil {
+		err = fmt.Errorf("failed to read column %q: %w", name, err)
+		return
+	}
+
+	// values are read back to back, so only bounds can be checked
+	decoder := c.newDecoder(raw)
+	if decoder.checks > CheckBounds {
+		decoder.checks = CheckBounds
+	}
+	values = make([]T, count)
+
+	for j := range values {
+		values[j] = decode(decoder)
+
+		if decodeErr == nil {
+			decodeErr = decoder.Err()
+		}
+
+		if decodeErr != nil {
+			err = fmt.Errorf("failed to decode column %q at row %d: %w", name, start+int64(j), decodeErr)
+			return
+		}
+	}
+
+	return
+}
+
+// columnDecoder returns a func(*Decoder) T decoding a single value of column,
+// variable-length decoding errors are stored in err. Returns nil for unknown column types.
+func columnDecoder(column Column, err *error) any {
+	size := int(column.Size)
+
+	switch column.Type {
+	case ColumnTypeString:
+		return func(d *Decoder) string { return d.DecodeStringPadded(size) }
+	case ColumnTypeBinary:
+		return func(d *Decoder) []byte { return append([]byte(nil), d.DecodeBytes(size)...) }
+	case ColumnTypeVarString:
+		return func(d *Decoder) (s string) {
+			s, *err = d.DecodeVarString()
+			return
+		}
+	case ColumnTypeVarBinary:
+		return func(d *Decoder) (b []byte) {
+			b, *err = d.DecodeVarBytes()
+			return
+		}
+	case ColumnTypeBool:
+		return (*Decoder).DecodeBool
+	case ColumnTypeInt8:
+		return (*Decoder).DecodeInt8
+	case ColumnTypeInt16:
+		return (*Decoder).DecodeInt16
+	case ColumnTypeInt32:
+		return (*Decoder).DecodeInt32
+	case ColumnTypeInt64:
+		return (*Decoder).DecodeInt64
+	case ColumnTypeUInt8:
+		return (*Decoder).DecodeUInt8
+	case ColumnTypeUInt16:
+		return (*Decoder).DecodeUInt16
+	case ColumnTypeUInt32:
+		return (*Decoder).DecodeUInt32
+	case ColumnTypeUInt64:
+		return (*Decoder).DecodeUInt64
+	case ColumnTypeFloat32:
+		return (*Decoder).DecodeFloat32
+	case ColumnTypeFloat64:
+		return (*Decoder).DecodeFloat64
+	}
+
+	return nil
+}
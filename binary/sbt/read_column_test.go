@@ -0,0 +1,94 @@
+package sbt
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadColumn(t *testing.T) {
+	layouts := map[string][]Option{
+		"rows":             nil,
+		"blocks":           {WithBlockCompression(CompressionSnappy, 100)},
+		"columnar":         {WithColumnar(), WithBlockCompression(CompressionNone, 100)},
+		"columnar-zstd":    {WithColumnar(), WithBlockCompression(CompressionZstd, 100)},
+		"columnar-default": {WithColumnar()},
+	}
+
+	for name, options := range layouts {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "columns.sbt")
+
+			c, err := Create[*testRowV2, testRowV2](filename, options...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+
+			rows := make([]*testRowV2, 250)
+			for i := range rows {
+				rows[i] = &testRowV2{Price: uint32(i), Symbol: fmt.Sprintf("SYM%d", i), Quantity: uint64(i * 10)}
+			}
+
+			if err = c.BulkAppend(rows); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			if err = c.Close(); err != nil {
+				t.Fatalf("failed to close container: %v", err)
+			}
+
+			if c, err = OpenRead[*testRowV2, testRowV2](filename); err != nil {
+				t.Fatalf("failed to open container: %v", err)
+			}
+			defer c.Close()
+
+			if columnar := strings.HasPrefix(name, "columnar"); columnar != (c.Flags()&FlagColumnar != 0) {
+				t.Fatalf("unexpected flags %x", c.Flags())
+			}
+
+			prices, err := ReadColumn[uint32](c, "Price", 50, 200)
+			if err != nil {
+				t.Fatalf("failed to read column: %v", err)
+			}
+
+			symbols, err := ReadColumn[string](c, "Symbol", 50, 200)
+			if err != nil {
+				t.Fatalf("failed to read column: %v", err)
+			}
+
+			for i := range prices {
+				if prices[i] != uint32(50+i) || symbols[i] != fmt.Sprintf("SYM%d", 50+i) {
+					t.Fatalf("unexpected values at %d: %d %q", 50+i, prices[i], symbols[i])
+				}
+			}
+
+			read := make([]*testRowV2, 120)
+			for i := range read {
+				read[i] = new(testRowV2)
+			}
+
+			if _, err = c.BulkRead(90, read); err != nil {
+				t.Fatalf("failed to bulk read: %v", err)
+			}
+
+			for i, row := range read {
+				if *row != *rows[90+i] {
+					t.Fatalf("unexpected row %d: %+v", 90+i, row)
+				}
+			}
+
+			if _, err = ReadColumn[string](c, "Quantity", 0, 1); err == nil {
+				t.Fatalf("expected type mismatch error")
+			}
+
+			if _, err = ReadColumn[uint64](c, "Missing", 0, 1); err == nil {
+				t.Fatalf("expected unknown column error")
+			}
+
+			if _, err = ReadColumn[uint64](c, "Quantity", 200, 51); err == nil {
+				t.Fatalf("expected out of bounds error")
+			}
+		})
+	}
+}
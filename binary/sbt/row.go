@@ -9,13 +9,63 @@ func NewRowSpec(columns ...Column) (r RowSpec) {
 	return
 }
 
-// RowSize returns the size of a row.
-func (s RowSpec) RowSize() (size uint8) {
+// RowSize returns the size of a row, including its null bitmap.
+func (s RowSpec) RowSize() (size uint32) {
 	for _, c := range s {
 		size += c.Size
 	}
 
-	return
+	return size + uint32(s.NullBitmapSize())
+}
+
+// NullBitmapSize returns the size of the null bitmap prefixed to every row, one bit per nullable column.
+func (s RowSpec) NullBitmapSize() int {
+	n := 0
+	for _, c := range s {
+		if c.Nullable {
+			n++
+		}
+	}
+
+	return (n + 7) / 8
+}
+
+// nullBit returns the index of the bit of column i in the null bitmap, or -1 if it isn't nullable.
+func (s RowSpec) nullBit(i int) int {
+	if i < 0 || i >= len(s) || !s[i].Nullable {
+		return -1
+	}
+
+	bit := 0
+	for _, c := range s[:i] {
+		if c.Nullable {
+			bit++
+		}
+	}
+
+	return bit
+}
+
+// HasHeap reports whether any column is variable-length.
+func (s RowSpec) HasHeap() bool {
+	for _, c := range s {
+		if c.Type.IsVariable() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate checks the columns of the spec.
+func (s RowSpec) validate() error {
+	for _, c := range s {
+		if err := c.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 type Row interface {
@@ -2,14 +2,16 @@ package sbt
 
 import (
 	"bytes"
+	"crypto/cipher"
 	"encoding/binary"
-	"encoding/json"
+	"errors"
 	"fmt"
 	binary2 "github.com/difof/goul/binary"
 	"github.com/difof/goul/generics"
 	"github.com/difof/goul/generics/containers"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sync"
@@ -50,39 +52,109 @@ func instanceOfRow[RowType any]() Row {
 //
 // It's useful for storing typed streams of data fast and efficiently.
 //
-// It's not thread-safe.
+// It's safe for concurrent use by a single writer and many readers: writes are serialized,
+// reads don't wait for appends and only see appended rows once the append returns.
+// Overwriting rows with Set while they're being read may return partially updated rows.
 type Container[P generics.Ptr[RowType], RowType any] struct {
 	flags      uint8
 	headerHash uint64
 	spec       RowSpec
 
 	contentOffset int64
-	file          *os.File
+	storage       Storage
+	sidecars      SidecarSet
 	pool          sync.Pool
 	filename      string
-	numRows       int64
 	headerSize    int32
+	opts          *Options
+	projection    *projection
+	heap          *heap
+	layout        layout
+	block         *BlockConfig
+	keyConfig     *KeyConfig
+	key           *keyColumn
+	tombstones    *tombstones
+	journal       txJournal
+	path          string
+	readOnly      bool
+	writeMu       sync.Mutex
+	// swapMu is held by readers, and locked by Compact while it replaces the layout and the storage
+	swapMu    sync.RWMutex
+	byteOrder ByteOrder
+	order     binary.ByteOrder
+	aead      cipher.AEAD
 }
 
 func open[P generics.Ptr[RowType], RowType any](
 	filename string,
 	mode int,
 	perm os.FileMode,
+	options []Option,
 ) (b *Container[P, RowType], err error) {
-	b = &Container[P, RowType]{
-		filename: filepath.Base(filename),
+	var sidecars SidecarSet = NewFileSidecars(filename, perm)
+	if opts := newOptions(options); opts.sidecars != nil {
+		sidecars = opts.sidecars
 	}
 
-	// open file
-	b.file, err = os.OpenFile(filename, mode, perm)
-	if err != nil {
+	if mode&(os.O_WRONLY|os.O_RDWR) != 0 {
+		if err = recoverCompaction(filename, sidecars); err != nil {
+			return
+		}
+	}
+
+	var storage *FileStorage
+	if storage, err = OpenFileStorage(filename, mode, perm); err != nil {
 		err = fmt.Errorf("failed to open file: %w", err)
 		return
 	}
 
+	return openStorage[P, RowType](storage, filename, sidecars, mode, options)
+}
+
+// openStorage opens the Container in storage, which is closed on failure.
+// path is the filename of the Container, empty if it's not stored in a file.
+func openStorage[P generics.Ptr[RowType], RowType any](
+	storage Storage,
+	path string,
+	sidecars SidecarSet,
+	mode int,
+	options []Option,
+) (b *Container[P, RowType], err error) {
+	b = &Container[P, RowType]{
+		storage:  storage,
+		sidecars: sidecars,
+		path:     path,
+		readOnly: mode&(os.O_WRONLY|os.O_RDWR) == 0,
+		opts:     newOptions(options),
+	}
+
+	if path != "" {
+		b.filename = filepath.Base(path)
+	}
+
+	if b.opts.sidecars != nil {
+		b.sidecars = b.opts.sidecars
+	}
+
+	b.journal.sidecars = b.sidecars
+
+	defer func() {
+		if err != nil {
+			b.Close()
+		}
+	}()
+
+	if !b.readOnly {
+		if err = recoverJournal(storage, b.sidecars); err != nil {
+			return
+		}
+	}
+
+	r := io.NewSectionReader(storage, 0, math.MaxInt64)
+
 	// read magic number
 	var magicNumber uint16
-	if err = binary.Read(b.file, binary.LittleEndian, &magicNumber); err != nil {
+	if err = binary.Read(r, binary.LittleEndian, &magicNumber); err != nil {
 		err = fmt.Errorf("failed to read magic number: %w", err)
 		return
 	}
@@ -93,26 +165,26 @@ func open[P generics.Ptr[RowType], RowType any](
 	}
 
 	// read flags
-	if err = binary.Read(b.file, binary.LittleEndian, &b.flags); err != nil {
+	if err = binary.Read(r, binary.LittleEndian, &b.flags); err != nil {
 		err = fmt.Errorf("failed to read flags: %w", err)
 		return
 	}
 
 	// read header hash
-	if err = binary.Read(b.file, binary.LittleEndian, &b.headerHash); err != nil {
+	if err = binary.Read(r, binary.LittleEndian, &b.headerHash); err != nil {
 		err = fmt.Errorf("failed to read header hash: %w", err)
 		return
 	}
 
 	// read header size
-	if err = binary.Read(b.file, binary.LittleEndian, &b.headerSize); err != nil {
+	if err = binary.Read(r, binary.LittleEndian, &b.headerSize); err != nil {
 		err = fmt.Errorf("failed to read header size: %w", err)
 		return
 	}
 
 	// read header
 	headerBytes := make([]byte, b.headerSize)
-	if _, err = b.file.Read(headerBytes); err != nil {
+	if _, err = io.ReadFull(r, headerBytes); err != nil {
 		err = fmt.Errorf("failed to read header: %w", err)
 		return
 	}
@@ -124,15 +196,49 @@ func open[P generics.Ptr[RowType], RowType any](
 	}
 
 	// unmarshal header
-	if err = json.Unmarshal(headerBytes, &b.spec); err != nil {
+	var header fileHeader
+	if header, err = unmarshalHeader(b.flags, headerBytes); err != nil {
 		err = fmt.Errorf("failed to unmarshal header: %w", err)
 		return
 	}
 
+	b.spec = header.Columns
+	b.block = header.Block
+	b.keyConfig = header.Key
+	b.byteOrder = header.ByteOrder
+	if b.order, err = header.ByteOrder.binary(); err != nil {
+		return
+	}
+
+	if err = b.checkSchema(); err != nil {
+		return
+	}
+
+	if header.Encryption != nil {
+		if b.aead, err = openEncryption(header.Encryption, b.opts.encryptionKey); err != nil {
+			return
+		}
+	}
+
+	if b.spec.HasHeap() {
+		if b.heap, err = openHeap(b.sidecars, mode); err != nil {
+			return
+		}
+
+		b.heap.aead = b.aead
+	}
+
 	b.contentOffset = int64(2 + 1 + 8 + 4 + b.headerSize)
 	b.pool = binary2.BytePoolN(int(b.spec.RowSize()))
-	if b.numRows, err = b.calculateNumRows(); err != nil {
-		err = fmt.Errorf("failed to calculate number of rows: %w", err)
+	if err = b.initLayout(); err != nil {
+		return
+	}
+
+	if err = b.initKey(mode); err != nil {
+		return
+	}
+
+	if b.tombstones, err = openTombstones(b.sidecars, mode); err != nil {
 		return
 	}
 
@@ -142,33 +248,121 @@ func open[P generics.Ptr[RowType], RowType any](
 // OpenRead opens a Container file for reading.
 func OpenRead[P generics.Ptr[RowType], RowType any](
 	filename string,
+	options ...Option,
 ) (b *Container[P, RowType], err error) {
-	return open[P, RowType](filename, os.O_RDONLY, 0666)
+	return open[P, RowType](filename, os.O_RDONLY, 0666, options)
 }
 
 // Open opens a Container file.
+//
+// It fails with a SchemaMismatchError if the stored RowSpec differs from the row type's Columns,
+// unless WithSchemaProjection is used.
 func Open[P generics.Ptr[RowType], RowType any](
 	filename string,
+	options ...Option,
+) (b *Container[P, RowType], err error) {
+	return open[P, RowType](filename, os.O_RDWR, 0666, options)
+}
+
+// OpenMmap opens a Container file for reading through a read-only memory mapping.
+//
+// Rows are decoded straight from the mapping, so bytes returned by Decoder.DecodeBytes and RawRow
+// point into it and are only valid until Close. Block layout and checksummed files are read as usual.
+// Writing returns ErrReadOnly.
+func OpenMmap[P generics.Ptr[RowType], RowType any](
+	filename string,
+	options ...Option,
 ) (b *Container[P, RowType], err error) {
-	return open[P, RowType](filename, os.O_RDWR, 0666)
+	options = append(options, func(o *Options) {
+		o.mmap = true
+	})
+
+	return open[P, RowType](filename, os.O_RDONLY, 0666, options)
 }
 
 // Create creates a Container file.
 func Create[P generics.Ptr[RowType], RowType any](
 	filename string,
+	options ...Option,
 ) (b *Container[P, RowType], err error) {
+	var storage *FileStorage
+	if storage, err = OpenFileStorage(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666); err != nil {
+		return
+	}
+
+	return createStorage[P, RowType](storage, filename, NewFileSidecars(filename, 0666), options)
+}
+
+// createStorage creates the Container in storage, which is truncated first and closed on failure.
+// path is the filename of the Container, empty if it's not stored in a file.
+func createStorage[P generics.Ptr[RowType], RowType any](
+	storage Storage,
+	path string,
+	sidecars SidecarSet,
+	options []Option,
+) (b *Container[P, RowType], err error) {
+	b = &Container[P, RowType]{
+		storage:  storage,
+		sidecars: sidecars,
+		path:     path,
+		opts:     newOptions(options),
+	}
+
+	if path != "" {
+		b.filename = filepath.Base(path)
+	}
+
+	if b.opts.sidecars != nil {
+		b.sidecars = b.opts.sidecars
+	}
+
+	b.journal.sidecars = b.sidecars
+
+	defer func() {
+		if err != nil {
+			b.Close()
+		}
+	}()
+
+	if err = b.sidecars.Remove(JournalExtension); err != nil && !errors.Is(err, os.ErrNotExist) {
+		err = fmt.Errorf("failed to remove journal: %w", err)
+		return
+	}
+
+	err = nil
+
 	ri := instanceOfRow[P]()
 	if ri == nil {
 		err = fmt.Errorf("failed to create instance of row")
 		return
 	}
 
-	header := ri.Columns()
+	spec := ri.Columns()
+	if spec.RowSize() == 0 {
+		err = fmt.Errorf("row has no columns")
+		return
+	}
 
-	b = &Container[P, RowType]{
-		spec:     header,
-		pool:     binary2.BytePoolN(int(header.RowSize())),
-		filename: filepath.Base(filename),
+	b.spec = spec
+	b.pool = binary2.BytePoolN(int(spec.RowSize()))
+
+	if err = spec.validate(); err != nil {
+		return
+	}
+
+	header, flags := newFileHeader(spec, b.opts)
+	b.flags = flags
+	b.block = header.Block
+	b.keyConfig = header.Key
+	b.byteOrder = header.ByteOrder
+	if b.order, err = header.ByteOrder.binary(); err != nil {
+		return
+	}
+
+	if header.Encryption != nil {
+		if b.aead, header.Encryption, err = newEncryption(header.Encryption.Cipher, b.opts.encryptionKey); err != nil {
+			return
+		}
 	}
 
 	buf := new(bytes.Buffer)
@@ -189,7 +383,7 @@ func Create[P generics.Ptr[RowType], RowType any](
 
 	// marshal header
 	var headerBytes []byte
-	if headerBytes, err = json.Marshal(header); err != nil {
+	if headerBytes, err = marshalHeader(header); err != nil {
 		err = fmt.Errorf("failed to marshal header: %w", err)
 		return
 	}
@@ -216,19 +410,34 @@ func Create[P generics.Ptr[RowType], RowType any](
 		return
 	}
 
-	// open file
-	if b.file, err = os.Create(filename); err != nil {
+	if err = b.storage.Truncate(0); err != nil {
+		err = fmt.Errorf("failed to truncate storage: %w", err)
 		return
 	}
 
-	if _, err = b.file.Write(buf.Bytes()); err != nil {
+	if spec.HasHeap() {
+		if b.heap, err = openHeap(b.sidecars, os.O_RDWR|os.O_TRUNC); err != nil {
+			return
+		}
+
+		b.heap.aead = b.aead
+	}
+
+	if _, err = b.storage.WriteAt(buf.Bytes(), 0); err != nil {
 		err = fmt.Errorf("failed to write header: %w", err)
 		return
 	}
 
 	b.contentOffset = int64(buf.Len())
-	if b.numRows, err = b.calculateNumRows(); err != nil {
-		err = fmt.Errorf("failed to calculate number of rows: %w", err)
+	if err = b.initLayout(); err != nil {
+		return
+	}
+
+	if err = b.initKey(os.O_RDWR | os.O_TRUNC); err != nil {
+		return
+	}
+
+	if b.tombstones, err = openTombstones(b.sidecars, os.O_RDWR|os.O_TRUNC); err != nil {
 		return
 	}
 
@@ -238,31 +447,263 @@ func Create[P generics.Ptr[RowType], RowType any](
 // Load opens or creates a Container file.
 func Load[P generics.Ptr[RowType], RowType any](
 	filename string,
+	options ...Option,
 ) (b *Container[P, RowType], err error) {
 	if _, err = os.Stat(filename); os.IsNotExist(err) {
-		return Create[P, RowType](filename)
+		return Create[P, RowType](filename, options...)
 	}
 
-	return Open[P, RowType](filename)
+	return Open[P, RowType](filename, options...)
 }
 
-// calculateNumRows returns the number of rows in the Container file.
-func (c *Container[P, RowType]) calculateNumRows() (numRows int64, err error) {
-	var size int64
-	if size, err = c.file.Seek(0, io.SeekEnd); err != nil {
-		err = fmt.Errorf("failed to seek to end: %w", err)
+// OpenStorage opens a Container stored in storage, e.g. a MemoryStorage or a FileStorage, see Open.
+// Its sidecars are kept in memory unless WithSidecars is used.
+func OpenStorage[P generics.Ptr[RowType], RowType any](
+	storage Storage,
+	options ...Option,
+) (b *Container[P, RowType], err error) {
+	return openStorage[P, RowType](storage, "", NewMemorySidecars(), os.O_RDWR, options)
+}
+
+// OpenStorageRead opens a Container stored in storage for reading, e.g. one returned by NewReaderStorage.
+// Its sidecars are kept in memory unless WithSidecars is used.
+func OpenStorageRead[P generics.Ptr[RowType], RowType any](
+	storage Storage,
+	options ...Option,
+) (b *Container[P, RowType], err error) {
+	return openStorage[P, RowType](storage, "", NewMemorySidecars(), os.O_RDONLY, options)
+}
+
+// CreateStorage creates a Container in storage, discarding its content.
+// Its sidecars are kept in memory unless WithSidecars is used.
+func CreateStorage[P generics.Ptr[RowType], RowType any](
+	storage Storage,
+	options ...Option,
+) (b *Container[P, RowType], err error) {
+	return createStorage[P, RowType](storage, "", NewMemorySidecars(), options)
+}
+
+// checkSchema compares the stored RowSpec with the row type's Columns,
+// setting up the projection if allowed by the options. Row types without Columns, like AnyRow, accept any RowSpec.
+func (c *Container[P, RowType]) checkSchema() (err error) {
+	ri := instanceOfRow[P]()
+	if ri == nil {
+		return fmt.Errorf("failed to create instance of row")
+	}
+
+	expected := ri.Columns()
+	if expected == nil || c.spec.Equal(expected) {
 		return
 	}
 
-	numRows = (size - c.contentOffset) / int64(c.spec.RowSize())
+	mismatch := &SchemaMismatchError{
+		Stored:   c.spec,
+		Expected: expected,
+		Changes:  DiffSpec(c.spec, expected),
+	}
+
+	if !c.opts.projectSchema {
+		return mismatch
+	}
+
+	if c.projection, err = newProjection(c.spec, expected); err != nil {
+		return fmt.Errorf("%w: %v", mismatch, err)
+	}
+
+	return
+}
+
+// decodeRow decodes a stored row into r, projecting it first if needed.
+func (c *Container[P, RowType]) decodeRow(r Row, decoder *Decoder, raw []byte) error {
+	if c.projection != nil {
+		projected := make([]byte, c.projection.size)
+		c.projection.project(projected, raw)
+		raw = projected
+
+		decoder.spec, decoder.nulls = c.projection.spec, c.projection.spec.NullBitmapSize()
+	}
+
+	decoder.Reset(raw)
+	decoder.beginRow()
+
+	if err := r.Decode(decoder); err != nil {
+		return err
+	}
+
+	return decoder.endRow()
+}
+
+// checkWritable returns an error if rows can't be written to the Container file.
+func (c *Container[P, RowType]) checkWritable() error {
+	if c.projection != nil {
+		return ErrProjected
+	}
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+
+	return nil
+}
+
+// readRowBytes returns count encoded rows starting at pos, read into buf if it's large enough.
+// Memory-mapped layouts return a slice of the mapping instead.
+func (c *Container[P, RowType]) readRowBytes(pos, count int64, buf []byte) (b []byte, err error) {
+	if raw, ok := c.layout.(rawLayout); ok {
+		return raw.rawRows(pos, count), nil
+	}
+
+	size := count * int64(c.spec.RowSize())
+	if int64(len(buf)) < size {
+		buf = make([]byte, size)
+	}
+
+	b = buf[:size]
+	err = c.layout.readRows(pos, b)
+
+	return
+}
+
+// readColumn reads the values of col of the rows starting at pos into raw, holding len(raw)/col.size values.
+func (c *Container[P, RowType]) readColumn(pos int64, col columnSlice, raw []byte) (err error) {
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+
+	if err = c.checkBounds(pos, int64(len(raw))/col.size); err != nil {
+		return
+	}
+
+	return c.layout.readColumn(pos, col, raw)
+}
+
+// newEncoder returns an Encoder bound to the Container's heap, RowSpec and byte order.
+func (c *Container[P, RowType]) newEncoder(buffer []byte) *Encoder {
+	e := NewEncoder(buffer)
+	e.heap = c.heap
+	e.spec = c.spec
+	e.order = c.order
+	e.nulls = c.spec.NullBitmapSize()
+	e.checks = c.opts.checks
+
+	return e
+}
+
+// newDecoder returns a Decoder bound to the Container's heap, RowSpec and byte order.
+func (c *Container[P, RowType]) newDecoder(buffer []byte) *Decoder {
+	d := NewDecoder(buffer)
+	d.heap = c.heap
+	d.spec = c.spec
+	d.order = c.order
+	d.nulls = c.spec.NullBitmapSize()
+	d.checks = c.opts.checks
+
+	return d
+}
+
+// encodeRow encodes row into the encoder's buffer.
+//
+// On failure, the heap payloads reserved by the current batch are discarded.
+func (c *Container[P, RowType]) encodeRow(encoder *Encoder, row P) (err error) {
+	defer func() {
+		if err != nil && c.heap != nil {
+			c.heap.discard()
+		}
+	}()
+
+	var r Row
+	if r, err = rowTypeToInterface(row); err != nil {
+		err = fmt.Errorf("failed to convert row to interface: %w", err)
+		return
+	}
+
+	encoder.beginRow()
+
+	if err = r.Encode(encoder); err == nil {
+		err = encoder.endRow()
+	}
+
+	if err != nil {
+		err = fmt.Errorf("failed to encode row: %w", err)
+		return
+	}
+
+	return
+}
+
+// flushHeap writes the heap payloads of the encoded rows, before the rows themselves are written.
+func (c *Container[P, RowType]) flushHeap() error {
+	if c.heap == nil {
+		return nil
+	}
+
+	return c.heap.flush()
+}
+
+// writeRows overwrites encoded rows starting at pos, updating the sparse index.
+func (c *Container[P, RowType]) writeRows(pos int64, b []byte) (err error) {
+	if err = c.layout.writeRows(pos, b); err != nil {
+		return
+	}
+
+	c.updateKeys(pos, b)
+	c.tombstones.set(pos, int64(len(b))/int64(c.spec.RowSize()), false)
+
+	return
+}
+
+// appendRows appends encoded rows, updating the sparse index.
+func (c *Container[P, RowType]) appendRows(b []byte) (err error) {
+	pos := c.NumRows()
+	if err = c.layout.appendRows(b); err != nil {
+		return
+	}
+
+	c.updateKeys(pos, b)
+
+	return
+}
+
+// initLayout sets up the content layout from the header.
+func (c *Container[P, RowType]) initLayout() (err error) {
+	c.layout, err = c.newLayout(c.storage, c.opts.mmap)
+
+	return
+}
+
+// closeLayout closes the content layout, if it holds resources.
+func (c *Container[P, RowType]) closeLayout() error {
+	if closer, ok := c.layout.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// newLayout returns the content layout of storage from the header.
+// With mmap, files are memory-mapped if possible.
+func (c *Container[P, RowType]) newLayout(storage Storage, mmap bool) (l layout, err error) {
+	file, isFile := storage.(*FileStorage)
+
+	if c.block != nil {
+		l, err = newBlockLayout(storage, c.contentOffset, c.spec, *c.block, c.flags, c.aead)
+	} else if mmap && isFile && c.flags&FlagChecksum == 0 {
+		l, err = newMmapLayout(file, c.contentOffset, c.spec.RowSize())
+	} else {
+		l, err = newRowLayout(storage, c.contentOffset, c.spec.RowSize(), c.flags)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("failed to load content: %w", err)
+	}
 
 	return
 }
 
 // checkBounds checks if the given index is within the bounds of the Container file.
+// The caller holds writeMu or swapMu.
 func (c *Container[P, RowType]) checkBounds(index, count int64) (err error) {
-	if index+count > c.NumRows() {
-		err = fmt.Errorf("index out of bounds: %d > %d", index+count, c.numRows)
+	if n := c.layout.numRows(); index+count > n {
+		err = fmt.Errorf("index out of bounds: %d > %d", index+count, n)
 		return
 	}
 
@@ -274,18 +715,105 @@ func (c *Container[P, RowType]) Filename() string {
 	return c.filename
 }
 
-// Close closes the Container file.
+// Flush writes rows buffered by the block layout and the updated sparse index entries.
+func (c *Container[P, RowType]) Flush() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.flush()
+}
+
+// flush is Flush without locking.
+func (c *Container[P, RowType]) flush() (err error) {
+	if c.layout == nil {
+		return
+	}
+
+	if err = c.layout.flush(); err != nil {
+		return
+	}
+
+	if c.key != nil && c.key.index != nil {
+		if err = c.key.index.flush(); err != nil {
+			return
+		}
+	}
+
+	if c.tombstones != nil {
+		err = c.tombstones.flush()
+	}
+
+	return
+}
+
+// Close flushes and closes the Container file.
 func (c *Container[P, RowType]) Close() (err error) {
-	if c.file != nil {
-		err = c.file.Close()
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	err = c.flush()
+
+	if err == nil && (c.journal.size > 0 || c.journal.pending) {
+		err = c.sync()
+	}
+
+	if jerr := c.journal.Close(); jerr != nil {
+		err = jerr
+	}
+
+	if lerr := c.closeLayout(); lerr != nil {
+		err = lerr
+	}
+
+	if c.heap != nil {
+		if herr := c.heap.Close(); herr != nil {
+			err = herr
+		}
+	}
+
+	if c.key != nil && c.key.index != nil {
+		if ierr := c.key.index.Close(); ierr != nil {
+			err = ierr
+		}
+	}
+
+	if c.tombstones != nil {
+		if terr := c.tombstones.Close(); terr != nil {
+			err = terr
+		}
+	}
+
+	if c.storage != nil {
+		if serr := c.storage.Close(); serr != nil {
+			err = serr
+		}
 	}
 
 	return
 }
 
-// Version returns the flags of the Container file.
+// Version returns the format version of the Container file.
 func (c *Container[P, RowType]) Version() uint8 {
-	return c.flags
+	return c.flags & formatVersionMask
+}
+
+// Flags returns the feature flags of the Container file.
+func (c *Container[P, RowType]) Flags() uint8 {
+	return c.flags &^ formatVersionMask
+}
+
+// ByteOrder returns the byte order of the numeric columns of the Container file.
+func (c *Container[P, RowType]) ByteOrder() ByteOrder {
+	if c.byteOrder == "" {
+		return LittleEndian
+	}
+
+	return c.byteOrder
+}
+
+// Block returns the block layout configuration, nil if the Container file isn't using it.
+func (c *Container[P, RowType]) Block() *BlockConfig {
+	return c.block
 }
 
 // Header returns the header of the Container file.
@@ -294,8 +822,14 @@ func (c *Container[P, RowType]) Header() RowSpec {
 }
 
 // SeekContent seeks to the content section of the Container file.
+// It fails if the Container isn't stored in a file.
 func (c *Container[P, RowType]) SeekContent() (err error) {
-	if _, err = c.file.Seek(int64(c.contentOffset), io.SeekStart); err != nil {
+	file, ok := c.storage.(*FileStorage)
+	if !ok {
+		return fmt.Errorf("can't seek %T", c.storage)
+	}
+
+	if _, err = file.Seek(int64(c.contentOffset), io.SeekStart); err != nil {
 		err = fmt.Errorf("failed to seek to content: %w", err)
 		return
 	}
@@ -304,8 +838,14 @@ func (c *Container[P, RowType]) SeekContent() (err error) {
 }
 
 // SeekEnd seeks to the end of the Container file.
+// It fails if the Container isn't stored in a file.
 func (c *Container[P, RowType]) SeekEnd() (err error) {
-	if _, err = c.file.Seek(0, io.SeekEnd); err != nil {
+	file, ok := c.storage.(*FileStorage)
+	if !ok {
+		return fmt.Errorf("can't seek %T", c.storage)
+	}
+
+	if _, err = file.Seek(0, io.SeekEnd); err != nil {
 		err = fmt.Errorf("failed to seek to end: %w", err)
 		return
 	}
@@ -315,16 +855,29 @@ func (c *Container[P, RowType]) SeekEnd() (err error) {
 
 // NumRows returns the number of rows.
 func (c *Container[P, RowType]) NumRows() int64 {
-	return c.numRows
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+
+	return c.layout.numRows()
 }
 
 // Size returns file size
 func (c *Container[P, RowType]) Size() int64 {
-	return c.numRows*int64(c.spec.RowSize()) + int64(c.headerSize+1+4+4)
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+
+	return c.layout.size()
 }
 
 // Set sets a row at the given index.
 func (c *Container[P, RowType]) Set(row P, index int64) (err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err = c.checkWritable(); err != nil {
+		return
+	}
+
 	if err = c.checkBounds(index, 1); err != nil {
 		return
 	}
@@ -332,19 +885,15 @@ func (c *Container[P, RowType]) Set(row P, index int64) (err error) {
 	buf := c.pool.Get().([]byte)
 	defer c.pool.Put(buf)
 
-	var r Row
-	r, err = rowTypeToInterface(row)
-	if err != nil {
-		err = fmt.Errorf("failed to convert row to interface: %w", err)
+	if err = c.encodeRow(c.newEncoder(buf), row); err != nil {
 		return
 	}
 
-	if err = r.Encode(NewEncoder(buf)); err != nil {
-		err = fmt.Errorf("failed to encode row: %w", err)
+	if err = c.flushHeap(); err != nil {
 		return
 	}
 
-	if _, err = c.file.WriteAt(buf, c.contentOffset+index*int64(c.spec.RowSize())); err != nil {
+	if err = c.writeRows(index, buf); err != nil {
 		err = fmt.Errorf("failed to write row: %w", err)
 		return
 	}
@@ -354,6 +903,13 @@ func (c *Container[P, RowType]) Set(row P, index int64) (err error) {
 
 // BulkSet sets a bulk of rows at the given index.
 func (c *Container[P, RowType]) BulkSet(index int64, rows []P) (err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err = c.checkWritable(); err != nil {
+		return
+	}
+
 	if err = c.checkBounds(index, int64(len(rows))); err != nil {
 		return
 	}
@@ -364,19 +920,12 @@ func (c *Container[P, RowType]) BulkSet(index int64, rows []P) (err error) {
 	tmp := c.pool.Get().([]byte)
 	defer c.pool.Put(tmp)
 
-	encoder := NewEncoder(nil)
+	encoder := c.newEncoder(nil)
 
 	for _, row := range rows {
-		var r Row
-		if r, err = rowTypeToInterface(row); err != nil {
-			err = fmt.Errorf("failed to convert row to interface: %w", err)
-			return
-		}
-
 		encoder.Reset(tmp)
 
-		if err = r.Encode(encoder); err != nil {
-			err = fmt.Errorf("failed to encode row: %w", err)
+		if err = c.encodeRow(encoder, row); err != nil {
 			return
 		}
 
@@ -386,7 +935,11 @@ func (c *Container[P, RowType]) BulkSet(index int64, rows []P) (err error) {
 		}
 	}
 
-	if _, err = c.file.WriteAt(buf.Bytes(), c.contentOffset+index*int64(c.spec.RowSize())); err != nil {
+	if err = c.flushHeap(); err != nil {
+		return
+	}
+
+	if err = c.writeRows(index, buf.Bytes()); err != nil {
 		err = fmt.Errorf("failed to write rows: %w", err)
 		return
 	}
@@ -396,39 +949,39 @@ func (c *Container[P, RowType]) BulkSet(index int64, rows []P) (err error) {
 
 // Append appends a row to the Container file.
 func (c *Container[P, RowType]) Append(row P) (err error) {
-	if err := c.SeekEnd(); err != nil {
-		return err
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err = c.checkWritable(); err != nil {
+		return
 	}
 
 	buf := c.pool.Get().([]byte)
 	defer c.pool.Put(buf)
 
-	var r Row
-	r, err = rowTypeToInterface(row)
-	if err != nil {
-		err = fmt.Errorf("failed to convert row to interface: %w", err)
+	if err = c.encodeRow(c.newEncoder(buf), row); err != nil {
 		return
 	}
 
-	if err = r.Encode(NewEncoder(buf)); err != nil {
-		err = fmt.Errorf("failed to encode row: %w", err)
+	if err = c.flushHeap(); err != nil {
 		return
 	}
 
-	if _, err = c.file.Write(buf); err != nil {
+	if err = c.appendRows(buf); err != nil {
 		err = fmt.Errorf("failed to write row: %w", err)
 		return
 	}
 
-	c.numRows++
-
 	return
 }
 
 // BulkAppend appends a bulk of rows to the Container file.
 func (c *Container[P, RowType]) BulkAppend(rows []P) (err error) {
-	if err := c.SeekEnd(); err != nil {
-		return err
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err = c.checkWritable(); err != nil {
+		return
 	}
 
 	buf := new(bytes.Buffer)
@@ -437,19 +990,12 @@ func (c *Container[P, RowType]) BulkAppend(rows []P) (err error) {
 	tmp := c.pool.Get().([]byte)
 	defer c.pool.Put(tmp)
 
-	encoder := NewEncoder(nil)
+	encoder := c.newEncoder(nil)
 
 	for _, row := range rows {
-		var r Row
-		if r, err = rowTypeToInterface(row); err != nil {
-			err = fmt.Errorf("failed to convert row to interface: %w", err)
-			return
-		}
-
 		encoder.Reset(tmp)
 
-		if err = r.Encode(encoder); err != nil {
-			err = fmt.Errorf("failed to encode row: %w", err)
+		if err = c.encodeRow(encoder, row); err != nil {
 			return
 		}
 
@@ -459,22 +1005,32 @@ func (c *Container[P, RowType]) BulkAppend(rows []P) (err error) {
 		}
 	}
 
-	if _, err = c.file.Write(buf.Bytes()); err != nil {
-		err = fmt.Errorf("failed to write rows: %w", err)
+	if err = c.flushHeap(); err != nil {
 		return
 	}
 
-	c.numRows += int64(len(rows))
+	if err = c.appendRows(buf.Bytes()); err != nil {
+		err = fmt.Errorf("failed to write rows: %w", err)
+		return
+	}
 
 	return
 }
 
 // ReadAt reads a row at a specified position.
 func (c *Container[P, RowType]) ReadAt(pos int64, row P) (err error) {
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+
 	if err = c.checkBounds(pos, 1); err != nil {
 		return
 	}
 
+	if c.tombstones.isDeleted(pos) {
+		err = fmt.Errorf("row %d: %w", pos, ErrDeleted)
+		return
+	}
+
 	var r Row
 	if r, err = rowTypeToInterface(row); err != nil {
 		err = fmt.Errorf("failed to convert row to interface: %w", err)
@@ -482,17 +1038,17 @@ func (c *Container[P, RowType]) ReadAt(pos int64, row P) (err error) {
 	}
 
 	// read row
-	rowBytes := c.pool.Get().([]byte)
-	defer c.pool.Put(rowBytes)
+	buf := c.pool.Get().([]byte)
+	defer c.pool.Put(buf)
 
-	offset := c.contentOffset + pos*int64(c.spec.RowSize())
-	if _, err = c.file.ReadAt(rowBytes, offset); err != nil {
+	var rowBytes []byte
+	if rowBytes, err = c.readRowBytes(pos, 1, buf); err != nil {
 		err = fmt.Errorf("failed to read row: %w", err)
 		return
 	}
 
 	// decode row
-	if err = r.Decode(NewDecoder(rowBytes)); err != nil {
+	if err = c.decodeRow(r, c.newDecoder(nil), rowBytes); err != nil {
 		err = fmt.Errorf("failed to decode row: %w", err)
 		return
 	}
@@ -500,58 +1056,110 @@ func (c *Container[P, RowType]) ReadAt(pos int64, row P) (err error) {
 	return
 }
 
-// BulkRead reads a bulk of rows at a specified position.
+// RawRow returns the encoded bytes of the row at pos.
+//
+// For memory-mapped containers it's a slice of the mapping, which must not be modified
+// and is only valid until Close. Otherwise the row is read into a new slice.
+func (c *Container[P, RowType]) RawRow(pos int64) (b []byte, err error) {
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+
+	if err = c.checkBounds(pos, 1); err != nil {
+		return
+	}
+
+	if b, err = c.readRowBytes(pos, 1, nil); err != nil {
+		err = fmt.Errorf("failed to read row: %w", err)
+		return
+	}
+
+	return
+}
+
+// BulkRead reads a bulk of rows at a specified position, skipping deleted rows.
 //
-// rows is a slice of rows to read into. The length of the slice is the number of rows to read.
+// rows is a slice of rows to read into. The length of the slice is the number of rows to read,
+// the live ones among them fill rows from the start.
 //
 // Use NumRows and pos 0 to read all rows, considering memory constraints, otherwise use Iter.
 //
 // returns the number of rows read and an error.
 func (c *Container[P, RowType]) BulkRead(pos int64, rows []P) (n int64, err error) {
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+
 	if err = c.checkBounds(pos, int64(len(rows))); err != nil {
 		return
 	}
 
-	rowSize := c.spec.RowSize()
-	offset := c.contentOffset + pos*int64(rowSize)
-	byteSize := int64(int(rowSize) * len(rows))
-
-	if byteSize >= c.Size() {
-		byteSize = c.Size() - offset
-	}
+	rowSize := int64(c.spec.RowSize())
 
 	// read rows
-	rowBytes := make([]byte, byteSize)
-	if _, err = c.file.ReadAt(rowBytes, int64(offset)); err != nil {
+	var rowBytes []byte
+	if rowBytes, err = c.readRowBytes(pos, int64(len(rows)), nil); err != nil {
 		err = fmt.Errorf("failed to read rows: %w", err)
 		return
 	}
 
-	decoder := NewDecoder(nil)
+	decoder := c.newDecoder(nil)
 
 	// decode rows
-	for ; n < int64(len(rows)); n++ {
+	for i := int64(0); i < int64(len(rows)); i++ {
+		if c.tombstones.isDeleted(pos + i) {
+			continue
+		}
+
 		var r Row
 		if r, err = rowTypeToInterface(rows[n]); err != nil {
 			err = fmt.Errorf("failed to convert row to interface: %w", err)
 			return
 		}
 
-		byteOffset := n * int64(rowSize)
-		byteEnd := byteOffset + int64(rowSize)
-
-		if byteEnd > int64(len(rowBytes)) {
-			break
+		if err = c.decodeRow(r, decoder, rowBytes[i*rowSize:(i+1)*rowSize]); err != nil {
+			err = fmt.Errorf("failed to decode row: %w", err)
+			return
 		}
 
-		decoder.Reset(rowBytes[byteOffset:byteEnd])
+		rows[n] = r.(P)
+		n++
+	}
+
+	return
+}
+
+// readLive reads the live rows among count rows starting at pos into new rows, along with their positions.
+// Rows out of bounds, e.g. after Compact, fail.
+func (c *Container[P, RowType]) readLive(pos, count int64) (keys []int64, rows []P, err error) {
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
 
-		if err = r.Decode(decoder); err != nil {
+	if err = c.checkBounds(pos, count); err != nil {
+		return
+	}
+
+	rowSize := int64(c.spec.RowSize())
+
+	var rowBytes []byte
+	if rowBytes, err = c.readRowBytes(pos, count, nil); err != nil {
+		err = fmt.Errorf("failed to read rows: %w", err)
+		return
+	}
+
+	decoder := c.newDecoder(nil)
+
+	for i := int64(0); i < count; i++ {
+		if c.tombstones.isDeleted(pos + i) {
+			continue
+		}
+
+		r := instanceOfRow[P]()
+		if err = c.decodeRow(r, decoder, rowBytes[i*rowSize:(i+1)*rowSize]); err != nil {
 			err = fmt.Errorf("failed to decode row: %w", err)
 			return
 		}
 
-		rows[n] = r.(P)
+		keys = append(keys, pos+i)
+		rows = append(rows, r.(P))
 	}
 
 	return
@@ -565,12 +1173,10 @@ func (c *Container[P, RowType]) Print(
 	start, count int64,
 	pf ColumnPrinter[P, RowType],
 ) error {
-	rows := make([]P, count)
-
 	readStart := time.Now()
-	if _, err := c.BulkRead(start, rows); err != nil {
+	keys, rows, err := c.readLive(start, count)
+	if err != nil {
 		return fmt.Errorf("failed to read rows: %v", err)
-
 	}
 	readCost := time.Since(readStart)
 
@@ -588,7 +1194,7 @@ func (c *Container[P, RowType]) Print(
 	for i, v := range rows {
 		cp := pf(v)
 		trows[i] = make(table.Row, len(cp)+1)
-		trows[i][0] = int64(i) + start
+		trows[i][0] = keys[i]
 		for j, v := range cp {
 			trows[i][j+1] = v
 		}
@@ -617,58 +1223,39 @@ func (c *Container[P, RowType]) IterHandler(iter *generics.Iterator[containers.T
 	go func() {
 		defer iter.IterationDone()
 
+		// iterate over a snapshot of the row count, rows appended meanwhile are not visited
+		numRows := c.NumRows()
+
 		bucketSize := Bucket10k
 		if iter.Args != nil {
 			bucketSize = iter.Args[0].(int64)
 		}
 
-		nr := c.NumRows()
-		_ = nr
-
-		if bucketSize > c.NumRows() {
-			bucketSize = c.NumRows()
-		}
-
-		rows := make([]P, bucketSize)
-
-		for i, r := range rows {
-			rows[i] = any(r).(Row).Factory().(P)
+		if bucketSize > numRows {
+			bucketSize = numRows
 		}
 
-		tuple := containers.NewTuple[int64, P](int64(0), nil)
-		pos := int64(0)
+		for pos := int64(0); pos < numRows; {
+			n := numRows - pos
+			if n > bucketSize {
+				n = bucketSize
+			}
 
-		for {
-			nRead, err := c.BulkRead(pos, rows)
+			keys, rows, err := c.readLive(pos, n)
 			if err != nil {
 				iter.SetError(err)
 				return
 			}
 
-			if nRead == 0 {
-				break
-			}
-
-			for i := int64(0); i < nRead; i++ {
-				tuple.Set(pos+i, rows[i])
-
+			for i := range rows {
 				select {
 				case <-iter.Done():
 					return
-				case iter.NextChannel() <- tuple:
+				case iter.NextChannel() <- containers.NewTuple(keys[i], rows[i]):
 				}
 			}
 
-			pos += nRead
-
-			if pos == c.NumRows() {
-				break
-			}
-
-			if pos+bucketSize >= c.NumRows() {
-				remaining := c.NumRows() - pos
-				rows = rows[:remaining]
-			}
+			pos += n
 		}
 	}()
 }
@@ -0,0 +1,91 @@
+package sbt
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/difof/goul/generics"
+)
+
+// ScanFunc is called by ParallelScan with the live rows among the bucket of rows starting at pos.
+// It's called concurrently by the workers, rows are reused once it returns.
+type ScanFunc[P generics.Ptr[RowType], RowType any] func(pos int64, rows []P) error
+
+// ParallelScan reads the rows with BulkRead in buckets of bucketSize rows, Bucket10k if 0, spread across
+// workers goroutines, runtime.GOMAXPROCS if 0, and calls fn with the live rows of every bucket.
+// Buckets are visited in no particular order, rows appended meanwhile are not visited.
+//
+// The scan stops at the first error of fn or of a read, or when ctx is done, returning the error.
+func (c *Container[P, RowType]) ParallelScan(ctx context.Context, workers int, bucketSize int64, fn ScanFunc[P, RowType]) error {
+	return c.scanBuckets(ctx, workers, bucketSize, func(bucketSize int64) bucketVisitor {
+		rows := make([]P, bucketSize)
+
+		return func(pos, n int64) error {
+			live, err := c.BulkRead(pos, rows[:n])
+			if err != nil {
+				return err
+			}
+
+			return fn(pos, rows[:live])
+		}
+	})
+}
+
+// bucketVisitor visits the n rows starting at pos.
+type bucketVisitor func(pos, n int64) error
+
+// scanBuckets splits a snapshot of the row count into buckets of bucketSize rows, visited by workers goroutines.
+// newVisitor is called once per worker with the normalized bucket size.
+func (c *Container[P, RowType]) scanBuckets(
+	ctx context.Context,
+	workers int,
+	bucketSize int64,
+	newVisitor func(bucketSize int64) bucketVisitor,
+) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if bucketSize <= 0 {
+		bucketSize = Bucket10k
+	}
+
+	numRows := c.NumRows()
+	if buckets := (numRows + bucketSize - 1) / bucketSize; int64(workers) > buckets {
+		workers = int(buckets)
+	}
+
+	var next atomic.Int64
+	g, ctx := errgroup.WithContext(ctx)
+
+	for i := 0; i < workers; i++ {
+		visit := newVisitor(bucketSize)
+
+		g.Go(func() error {
+			for {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				pos := next.Add(bucketSize) - bucketSize
+				if pos >= numRows {
+					return nil
+				}
+
+				n := numRows - pos
+				if n > bucketSize {
+					n = bucketSize
+				}
+
+				if err := visit(pos, n); err != nil {
+					return err
+				}
+			}
+		})
+	}
+
+	return g.Wait()
+}
@@ -0,0 +1,152 @@
+package sbt
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+var testScanSymbols = []string{"BTCUSDT", "ETHUSDT", "XRPUSDT"}
+
+func createScanContainer(t *testing.T, n int, options ...Option) *Container[*testRowV2, testRowV2] {
+	c, err := Create[*testRowV2, testRowV2](filepath.Join(t.TempDir(), "scan.sbt"), options...)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	rows := make([]*testRowV2, n)
+	for i := range rows {
+		rows[i] = &testRowV2{Price: uint32(i), Symbol: testScanSymbols[i%len(testScanSymbols)], Quantity: uint64(i * 2)}
+	}
+
+	if err = c.BulkAppend(rows); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	return c
+}
+
+func TestParallelScan(t *testing.T) {
+	for name, options := range map[string][]Option{"rows": nil, "columnar": {WithColumnar()}} {
+		t.Run(name, func(t *testing.T) {
+			c := createScanContainer(t, 10000, options...)
+
+			if err := c.DeleteRange(100, 50); err != nil {
+				t.Fatalf("failed to delete rows: %v", err)
+			}
+
+			var mu sync.Mutex
+			seen := make(map[uint32]bool)
+
+			err := c.ParallelScan(context.Background(), 4, 333, func(pos int64, rows []*testRowV2) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				for _, row := range rows {
+					if int64(row.Price) < pos || int64(row.Price) >= pos+333 || seen[row.Price] {
+						return errors.New("unexpected row")
+					}
+
+					seen[row.Price] = true
+				}
+
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to scan: %v", err)
+			}
+
+			if len(seen) != 10000-50 || seen[120] {
+				t.Fatalf("expected %d live rows, got %d", 10000-50, len(seen))
+			}
+		})
+	}
+}
+
+func TestParallelScanStop(t *testing.T) {
+	c := createScanContainer(t, 1000)
+
+	stop := errors.New("stop")
+	var calls atomic.Int64
+
+	err := c.ParallelScan(context.Background(), 2, 10, func(pos int64, rows []*testRowV2) error {
+		if calls.Add(1) == 5 {
+			return stop
+		}
+
+		return nil
+	})
+	if !errors.Is(err, stop) || calls.Load() >= 100 {
+		t.Fatalf("expected scan to stop, got %v after %d calls", err, calls.Load())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err = c.ParallelScan(ctx, 0, 0, func(int64, []*testRowV2) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled error, got %v", err)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	c := createScanContainer(t, 30000, WithColumnar())
+
+	if err := c.Delete(0); err != nil {
+		t.Fatalf("failed to delete row: %v", err)
+	}
+
+	stats, err := c.Aggregate(context.Background(), "Quantity")
+	if err != nil {
+		t.Fatalf("failed to aggregate: %v", err)
+	}
+
+	// rows 1 to 29999, quantity 2*i
+	if stats.Count != 29999 || stats.Min != 2 || stats.Max != 59998 || stats.Sum != 29999*30000 || stats.Avg() != 30000 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	groups, err := c.GroupBy(context.Background(), "Symbol", "Price")
+	if err != nil {
+		t.Fatalf("failed to group: %v", err)
+	}
+
+	if len(groups) != 3 || groups["BTCUSDT"].Count != 9999 || groups["BTCUSDT"].Min != 3 ||
+		groups["ETHUSDT"].Count != 10000 || groups["XRPUSDT"].Max != 29999 {
+		t.Fatalf("unexpected groups %+v", groups)
+	}
+
+	if _, err = c.Aggregate(context.Background(), "Symbol"); err == nil {
+		t.Fatalf("expected non-numeric column error")
+	}
+}
+
+func TestAggregateNullable(t *testing.T) {
+	c, err := Create[*testNullableRow, testNullableRow](filepath.Join(t.TempDir(), "nullable.sbt"))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	defer c.Close()
+
+	if err = c.BulkAppend(testNullableRows(100)); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	stats, err := c.Aggregate(context.Background(), "Price")
+	if err != nil || stats.Count != 50 || stats.Max != 98 || stats.Sum != 49*50 {
+		t.Fatalf("unexpected stats %+v: %v", stats, err)
+	}
+
+	groups, err := c.GroupBy(context.Background(), "Note", "Price")
+	if err != nil {
+		t.Fatalf("failed to group: %v", err)
+	}
+
+	// notes are set on every third row, "note" on every sixth, prices on every second
+	if groups["note"].Count != 17 || groups[""].Count != 0 || groups[nil].Count != 33 {
+		t.Fatalf("unexpected groups %+v", groups)
+	}
+}
@@ -0,0 +1,263 @@
+package sbt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/difof/goul/generics"
+)
+
+var (
+	ErrSchemaMismatch = errors.New("schema mismatch")
+	ErrProjected      = errors.New("can't write to a projected container")
+)
+
+type SchemaChangeKind string
+
+const (
+	SchemaColumnAdded   SchemaChangeKind = "added"
+	SchemaColumnRemoved SchemaChangeKind = "removed"
+	SchemaColumnMoved   SchemaChangeKind = "moved"
+	SchemaColumnResized SchemaChangeKind = "resized"
+	SchemaColumnRetyped SchemaChangeKind = "retyped"
+)
+
+// SchemaChange is a single column difference between two RowSpecs.
+type SchemaChange struct {
+	Kind SchemaChangeKind
+	Name string
+	// Old is the stored column, zero if the column was added.
+	Old Column
+	// New is the expected column, zero if the column was removed.
+	New Column
+}
+
+// String
+func (c SchemaChange) String() string {
+	switch c.Kind {
+	case SchemaColumnAdded:
+		return fmt.Sprintf("%s %s (%s.%d)", c.Name, c.Kind, c.New.Type, c.New.Size)
+	case SchemaColumnRemoved:
+		return fmt.Sprintf("%s %s (%s.%d)", c.Name, c.Kind, c.Old.Type, c.Old.Size)
+	}
+
+	return fmt.Sprintf("%s %s (%s.%d -> %s.%d)", c.Name, c.Kind, c.Old.Type, c.Old.Size, c.New.Type, c.New.Size)
+}
+
+// SchemaMismatchError is returned when the stored RowSpec of a file differs from the row type's Columns.
+//
+// It matches ErrSchemaMismatch with errors.Is.
+type SchemaMismatchError struct {
+	Stored   RowSpec
+	Expected RowSpec
+	Changes  []SchemaChange
+}
+
+// Error
+func (e *SchemaMismatchError) Error() string {
+	changes := make([]string, len(e.Changes))
+	for i, c := range e.Changes {
+		changes[i] = c.String()
+	}
+
+	return fmt.Sprintf("%s: %s", ErrSchemaMismatch, strings.Join(changes, ", "))
+}
+
+// Is
+func (e *SchemaMismatchError) Is(target error) bool {
+	return target == ErrSchemaMismatch
+}
+
+// Equal reports whether both specs have the same columns in the same order.
+func (s RowSpec) Equal(other RowSpec) bool {
+	if len(s) != len(other) {
+		return false
+	}
+
+	for i := range s {
+		if s[i] != other[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Index returns the index of the column with the given name, or -1.
+func (s RowSpec) Index(name string) int {
+	for i, c := range s {
+		if c.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Offset returns the byte offset of the column at index i within a row, after the null bitmap.
+func (s RowSpec) Offset(i int) (offset int) {
+	offset = s.NullBitmapSize()
+	for _, c := range s[:i] {
+		offset += int(c.Size)
+	}
+
+	return
+}
+
+// DiffSpec returns the column changes needed to go from stored to expected, matching columns by name.
+func DiffSpec(stored, expected RowSpec) (changes []SchemaChange) {
+	for i, n := range expected {
+		oi := stored.Index(n.Name)
+		if oi < 0 {
+			changes = append(changes, SchemaChange{Kind: SchemaColumnAdded, Name: n.Name, New: n})
+			continue
+		}
+
+		o := stored[oi]
+		switch {
+		case o.Type != n.Type, o.Precision != n.Precision, o.Scale != n.Scale, o.Nullable != n.Nullable:
+			changes = append(changes, SchemaChange{Kind: SchemaColumnRetyped, Name: n.Name, Old: o, New: n})
+		case o.Size != n.Size:
+			changes = append(changes, SchemaChange{Kind: SchemaColumnResized, Name: n.Name, Old: o, New: n})
+		case stored.Offset(oi) != expected.Offset(i):
+			changes = append(changes, SchemaChange{Kind: SchemaColumnMoved, Name: n.Name, Old: o, New: n})
+		}
+	}
+
+	for _, o := range stored {
+		if expected.Index(o.Name) < 0 {
+			changes = append(changes, SchemaChange{Kind: SchemaColumnRemoved, Name: o.Name, Old: o})
+		}
+	}
+
+	return
+}
+
+// projectionCopy copies n bytes of a stored row at src to the projected row at dst.
+type projectionCopy struct {
+	src, dst, n int
+}
+
+// projection maps rows of a stored RowSpec onto the layout of an expected RowSpec.
+type projection struct {
+	// spec is the RowSpec of projected rows.
+	spec   RowSpec
+	size   int
+	copies []projectionCopy
+	// nulls copies the null bit src of the stored row to the null bit dst of the projected row.
+	nulls []projectionCopy
+}
+
+// newProjection builds the projection from stored to expected.
+// Only string and binary columns can be resized, and no column can change its type, precision or scale.
+// Columns made nullable are never null, null values of columns made non-nullable become zero.
+// Added variable-length columns are empty, files without a heap don't need one to project them.
+func newProjection(stored, expected RowSpec) (p *projection, err error) {
+	p = &projection{spec: expected, size: int(expected.RowSize())}
+
+	for i, n := range expected {
+		oi := stored.Index(n.Name)
+		if oi < 0 {
+			continue
+		}
+
+		o := stored[oi]
+		if o.Type != n.Type {
+			err = fmt.Errorf("can't project column %s from %s to %s", n.Name, o.Type, n.Type)
+			return
+		}
+
+		if o.Precision != n.Precision || o.Scale != n.Scale {
+			err = fmt.Errorf("can't project column %s from %s(%d, %d) to %s(%d, %d)",
+				n.Name, o.Type, o.Precision, o.Scale, n.Type, n.Precision, n.Scale)
+			return
+		}
+
+		if o.Size != n.Size && o.Type != ColumnTypeString && o.Type != ColumnTypeBinary {
+			err = fmt.Errorf("can't project column %s of type %s from size %d to %d", n.Name, o.Type, o.Size, n.Size)
+			return
+		}
+
+		size := n.Size
+		if o.Size < size {
+			size = o.Size
+		}
+
+		p.copies = append(p.copies, projectionCopy{
+			src: stored.Offset(oi),
+			dst: expected.Offset(i),
+			n:   int(size),
+		})
+
+		if o.Nullable && n.Nullable {
+			p.nulls = append(p.nulls, projectionCopy{src: stored.nullBit(oi), dst: expected.nullBit(i)})
+		}
+	}
+
+	return
+}
+
+// project writes the projection of the stored row src into dst, which must be of the projected size.
+func (p *projection) project(dst, src []byte) {
+	for i := range dst {
+		dst[i] = 0
+	}
+
+	for _, c := range p.copies {
+		copy(dst[c.dst:c.dst+c.n], src[c.src:c.src+c.n])
+	}
+
+	for _, c := range p.nulls {
+		if src[c.src/8]&(1<<(c.src%8)) != 0 {
+			dst[c.dst/8] |= 1 << (c.dst % 8)
+		}
+	}
+}
+
+// Migrate rewrites the src file into dst using the layout of the row type's Columns.
+//
+// Rows are projected the same way as WithSchemaProjection, new columns are zero filled.
+// dst is overwritten if it exists.
+func Migrate[P generics.Ptr[RowType], RowType any](src, dst string) (err error) {
+	var in, out *Container[P, RowType]
+
+	if in, err = OpenRead[P, RowType](src, WithSchemaProjection()); err != nil {
+		return fmt.Errorf("failed to open source: %w", err)
+	}
+	defer in.Close()
+
+	if out, err = Create[P, RowType](dst); err != nil {
+		return fmt.Errorf("failed to create destination: %w", err)
+	}
+	defer func() {
+		if cerr := out.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close destination: %w", cerr)
+		}
+	}()
+
+	rows := make([]P, Bucket10k)
+
+	for pos := int64(0); pos < in.NumRows(); {
+		bucket := rows
+		if remaining := in.NumRows() - pos; remaining < int64(len(bucket)) {
+			bucket = bucket[:remaining]
+		}
+
+		var n int64
+		if n, err = in.BulkRead(pos, bucket); err != nil {
+			return fmt.Errorf("failed to read rows at %d: %w", pos, err)
+		}
+
+		// deleted rows are skipped, n may be less than the rows read
+		if n > 0 {
+			if err = out.BulkAppend(bucket[:n]); err != nil {
+				return fmt.Errorf("failed to write rows at %d: %w", pos, err)
+			}
+		}
+
+		pos += int64(len(bucket))
+	}
+
+	return
+}
@@ -0,0 +1,277 @@
+package sbt
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+type testRowV1 struct {
+	Symbol string
+	Price  uint32
+}
+
+func (r *testRowV1) Factory() Row {
+	return new(testRowV1)
+}
+
+func (r *testRowV1) Columns() RowSpec {
+	return NewRowSpec(
+		ColumnTypeString.New("Symbol", 8),
+		ColumnTypeUInt32.New("Price"),
+	)
+}
+
+func (r *testRowV1) Encode(ctx *Encoder) error {
+	ctx.EncodeStringPadded(r.Symbol, 8)
+	ctx.EncodeUInt32(r.Price)
+
+	return nil
+}
+
+func (r *testRowV1) Decode(ctx *Decoder) error {
+	r.Symbol = ctx.DecodeStringPadded(8)
+	r.Price = ctx.DecodeUInt32()
+
+	return nil
+}
+
+type testRowV2 struct {
+	Price    uint32
+	Symbol   string
+	Quantity uint64
+}
+
+func (r *testRowV2) Factory() Row {
+	return new(testRowV2)
+}
+
+func (r *testRowV2) Columns() RowSpec {
+	return NewRowSpec(
+		ColumnTypeUInt32.New("Price"),
+		ColumnTypeString.New("Symbol", 12),
+		ColumnTypeUInt64.New("Quantity"),
+	)
+}
+
+func (r *testRowV2) Encode(ctx *Encoder) error {
+	ctx.EncodeUInt32(r.Price)
+	ctx.EncodeStringPadded(r.Symbol, 12)
+	ctx.EncodeUInt64(r.Quantity)
+
+	return nil
+}
+
+func (r *testRowV2) Decode(ctx *Decoder) error {
+	r.Price = ctx.DecodeUInt32()
+	r.Symbol = ctx.DecodeStringPadded(12)
+	r.Quantity = ctx.DecodeUInt64()
+
+	return nil
+}
+
+func TestSchemaEvolution(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "v1.sbt")
+	dst := filepath.Join(dir, "v2.sbt")
+
+	c, err := Create[*testRowV1, testRowV1](src)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	if err = c.BulkAppend([]*testRowV1{{Symbol: "BTCUSDT", Price: 1}, {Symbol: "ETHUSDT", Price: 2}}); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	_, err = Open[*testRowV2, testRowV2](src)
+	var mismatch *SchemaMismatchError
+	if !errors.Is(err, ErrSchemaMismatch) || !errors.As(err, &mismatch) {
+		t.Fatalf("expected schema mismatch, got %v", err)
+	}
+
+	if len(mismatch.Changes) != 3 {
+		t.Fatalf("expected 3 changes, got %v", mismatch.Changes)
+	}
+
+	projected, err := Open[*testRowV2, testRowV2](src, WithSchemaProjection())
+	if err != nil {
+		t.Fatalf("failed to open projected container: %v", err)
+	}
+
+	row := new(testRowV2)
+	if err = projected.ReadAt(1, row); err != nil {
+		t.Fatalf("failed to read projected row: %v", err)
+	}
+
+	if *row != (testRowV2{Price: 2, Symbol: "ETHUSDT"}) {
+		t.Fatalf("unexpected projected row %+v", row)
+	}
+
+	if err = projected.Append(row); !errors.Is(err, ErrProjected) {
+		t.Fatalf("expected projected write error, got %v", err)
+	}
+
+	if err = projected.Close(); err != nil {
+		t.Fatalf("failed to close projected container: %v", err)
+	}
+
+	if err = Migrate[*testRowV2, testRowV2](src, dst); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	migrated, err := Open[*testRowV2, testRowV2](dst)
+	if err != nil {
+		t.Fatalf("failed to open migrated container: %v", err)
+	}
+	defer migrated.Close()
+
+	rows := make([]*testRowV2, migrated.NumRows())
+	if _, err = migrated.BulkRead(0, rows); err != nil {
+		t.Fatalf("failed to read migrated rows: %v", err)
+	}
+
+	if len(rows) != 2 || rows[0].Symbol != "BTCUSDT" || rows[1].Price != 2 {
+		t.Fatalf("unexpected migrated rows %+v %+v", rows[0], rows[1])
+	}
+}
+
+// testRowV3 adds a variable-length column to testRowV2.
+type testRowV3 struct {
+	testRowV2
+	Note string
+}
+
+func (r *testRowV3) Factory() Row {
+	return new(testRowV3)
+}
+
+func (r *testRowV3) Columns() RowSpec {
+	return append(r.testRowV2.Columns(), ColumnTypeVarString.New("Note"))
+}
+
+func (r *testRowV3) Encode(ctx *Encoder) error {
+	r.testRowV2.Encode(ctx)
+	ctx.EncodeVarString(r.Note)
+
+	return nil
+}
+
+func (r *testRowV3) Decode(ctx *Decoder) (err error) {
+	r.testRowV2.Decode(ctx)
+	r.Note, err = ctx.DecodeVarString()
+
+	return
+}
+
+func TestMigrateHeap(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src.sbt"), filepath.Join(dir, "dst.sbt")
+
+	c, err := Create[*testRowV2, testRowV2](src)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	if err = c.BulkAppend(testTxRows(0, 10)); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if err = Migrate[*testRowV3, testRowV3](src, dst); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	migrated, err := Open[*testRowV3, testRowV3](dst)
+	if err != nil {
+		t.Fatalf("failed to open migrated container: %v", err)
+	}
+	defer migrated.Close()
+
+	appended := &testRowV3{testRowV2: testRowV2{Price: 10}, Note: "heap"}
+	if err = migrated.Append(appended); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+
+	for pos := int64(0); pos <= 10; pos++ {
+		row := new(testRowV3)
+		if err = migrated.ReadAt(pos, row); err != nil {
+			t.Fatalf("failed to read row %d: %v", pos, err)
+		}
+
+		expected := &testRowV3{testRowV2: *testTxRows(int(pos), 1)[0]}
+		if pos == 10 {
+			expected = appended
+		}
+
+		if *row != *expected {
+			t.Fatalf("row %d: expected %+v, got %+v", pos, expected, row)
+		}
+	}
+}
+
+func TestMigrateTombstones(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src.sbt"), filepath.Join(dir, "dst.sbt")
+
+	c, err := Create[*testRowV2, testRowV2](src)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	if err = c.BulkAppend(testTxRows(0, 25000)); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	// a whole bucket and a row of the next one
+	if err = c.DeleteRange(0, 10000); err != nil {
+		t.Fatalf("failed to delete rows: %v", err)
+	}
+
+	if err = c.Delete(15000); err != nil {
+		t.Fatalf("failed to delete row: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if err = Migrate[*testRowV2, testRowV2](src, dst); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	migrated, err := Open[*testRowV2, testRowV2](dst)
+	if err != nil {
+		t.Fatalf("failed to open migrated container: %v", err)
+	}
+	defer migrated.Close()
+
+	if migrated.NumRows() != 14999 {
+		t.Fatalf("expected 14999 rows, got %d", migrated.NumRows())
+	}
+
+	expected := uint32(10000)
+	for pos := int64(0); pos < migrated.NumRows(); pos++ {
+		row := new(testRowV2)
+		if err = migrated.ReadAt(pos, row); err != nil {
+			t.Fatalf("failed to read row %d: %v", pos, err)
+		}
+
+		if expected == 15000 {
+			expected++
+		}
+
+		if row.Price != expected {
+			t.Fatalf("row %d: expected price %d, got %d", pos, expected, row.Price)
+		}
+
+		expected++
+	}
+}
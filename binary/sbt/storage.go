@@ -0,0 +1,282 @@
+package sbt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Storage is the random access storage of a Container or of one of its sidecars.
+type Storage interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+	// Size returns the current size of the storage.
+	Size() (int64, error)
+	// Truncate changes the size of the storage.
+	Truncate(size int64) error
+	// Sync commits the written content to durable storage.
+	Sync() error
+}
+
+// SidecarSet opens the sidecar storages of a Container, e.g. its heap, index or tombstones.
+type SidecarSet interface {
+	// Open opens the sidecar with the extension ext, e.g. HeapExtension, using the os.OpenFile flags.
+	// Missing sidecars fail with an error wrapping os.ErrNotExist unless flag has os.O_CREATE.
+	Open(ext string, flag int) (Storage, error)
+	// Remove removes the sidecar with the extension ext.
+	Remove(ext string) error
+}
+
+// FileStorage is a Storage backed by a file.
+type FileStorage struct {
+	*os.File
+}
+
+// OpenFileStorage opens the named file with the os.OpenFile flags.
+func OpenFileStorage(name string, flag int, perm os.FileMode) (*FileStorage, error) {
+	file, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStorage{file}, nil
+}
+
+// Size
+func (s *FileStorage) Size() (int64, error) {
+	stat, err := s.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return stat.Size(), nil
+}
+
+// fileSidecars stores sidecars in files next to the Container file.
+type fileSidecars struct {
+	path string
+	perm os.FileMode
+}
+
+// NewFileSidecars returns the SidecarSet of the Container file path, each sidecar being stored in path+ext.
+func NewFileSidecars(path string, perm os.FileMode) SidecarSet {
+	return &fileSidecars{path: path, perm: perm}
+}
+
+// Open
+func (s *fileSidecars) Open(ext string, flag int) (Storage, error) {
+	storage, err := OpenFileStorage(s.path+ext, flag, s.perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+// Remove
+func (s *fileSidecars) Remove(ext string) error {
+	return os.Remove(s.path + ext)
+}
+
+// MemoryStorage is a Storage held in memory, safe for concurrent use. Close is a no-op, so it can be reopened.
+type MemoryStorage struct {
+	mu   sync.RWMutex
+	data []byte
+}
+
+// NewMemoryStorage returns a MemoryStorage holding data, e.g. a Container file read from an HTTP body.
+func NewMemoryStorage(data []byte) *MemoryStorage {
+	return &MemoryStorage{data: data}
+}
+
+// Bytes returns a copy of the content.
+func (s *MemoryStorage) Bytes() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]byte(nil), s.data...)
+}
+
+// ReadAt
+func (s *MemoryStorage) ReadAt(p []byte, off int64) (n int, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+
+	if off >= int64(len(s.data)) {
+		return 0, io.EOF
+	}
+
+	if n = copy(p, s.data[off:]); n < len(p) {
+		err = io.EOF
+	}
+
+	return
+}
+
+// WriteAt
+func (s *MemoryStorage) WriteAt(p []byte, off int64) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+
+	if end := off + int64(len(p)); end > int64(len(s.data)) {
+		s.grow(end)
+	}
+
+	return copy(s.data[off:], p), nil
+}
+
+// grow extends the content with zeros up to size bytes.
+func (s *MemoryStorage) grow(size int64) {
+	if size <= int64(cap(s.data)) {
+		n := len(s.data)
+		s.data = s.data[:size]
+
+		for i := n; i < len(s.data); i++ {
+			s.data[i] = 0
+		}
+
+		return
+	}
+
+	data := make([]byte, size, size+size/2)
+	copy(data, s.data)
+	s.data = data
+}
+
+// Size
+func (s *MemoryStorage) Size() (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.data)), nil
+}
+
+// Truncate
+func (s *MemoryStorage) Truncate(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if size < 0 {
+		return fmt.Errorf("negative size %d", size)
+	}
+
+	if size <= int64(len(s.data)) {
+		s.data = s.data[:size]
+		return nil
+	}
+
+	s.grow(size)
+
+	return nil
+}
+
+// Sync
+func (s *MemoryStorage) Sync() error {
+	return nil
+}
+
+// Close
+func (s *MemoryStorage) Close() error {
+	return nil
+}
+
+// MemorySidecars is a SidecarSet held in memory. It keeps its sidecars after the Container is closed,
+// so it can be passed again to reopen the Container.
+type MemorySidecars struct {
+	mu       sync.Mutex
+	sidecars map[string]*MemoryStorage
+}
+
+// NewMemorySidecars
+func NewMemorySidecars() *MemorySidecars {
+	return &MemorySidecars{sidecars: make(map[string]*MemoryStorage)}
+}
+
+// Open
+func (s *MemorySidecars) Open(ext string, flag int) (Storage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	storage, ok := s.sidecars[ext]
+	if !ok {
+		if flag&os.O_CREATE == 0 {
+			return nil, fmt.Errorf("sidecar %s: %w", ext, os.ErrNotExist)
+		}
+
+		storage = NewMemoryStorage(nil)
+		s.sidecars[ext] = storage
+	}
+
+	if flag&os.O_TRUNC != 0 {
+		if err := storage.Truncate(0); err != nil {
+			return nil, err
+		}
+	}
+
+	return storage, nil
+}
+
+// Remove
+func (s *MemorySidecars) Remove(ext string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sidecars[ext]; !ok {
+		return fmt.Errorf("sidecar %s: %w", ext, os.ErrNotExist)
+	}
+
+	delete(s.sidecars, ext)
+
+	return nil
+}
+
+// readerStorage is a read-only Storage over an io.ReaderAt of known size.
+type readerStorage struct {
+	io.ReaderAt
+	size int64
+}
+
+// NewReaderStorage returns a read-only Storage reading the size bytes of r, e.g. an object store range reader.
+// Writes fail with ErrReadOnly, and Close closes r if it's an io.Closer.
+func NewReaderStorage(r io.ReaderAt, size int64) Storage {
+	return &readerStorage{ReaderAt: r, size: size}
+}
+
+// WriteAt
+func (s *readerStorage) WriteAt([]byte, int64) (int, error) {
+	return 0, ErrReadOnly
+}
+
+// Size
+func (s *readerStorage) Size() (int64, error) {
+	return s.size, nil
+}
+
+// Truncate
+func (s *readerStorage) Truncate(int64) error {
+	return ErrReadOnly
+}
+
+// Sync
+func (s *readerStorage) Sync() error {
+	return nil
+}
+
+// Close
+func (s *readerStorage) Close() error {
+	if closer, ok := s.ReaderAt.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
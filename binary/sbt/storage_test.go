@@ -0,0 +1,142 @@
+package sbt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	sidecars := NewMemorySidecars()
+	storage := NewMemoryStorage(nil)
+
+	for name, options := range map[string][]Option{"rows": nil, "blocks": {WithBlockCompression(CompressionZstd, 16)}} {
+		t.Run(name, func(t *testing.T) {
+			options := append(options, WithSidecars(sidecars))
+
+			c, err := CreateStorage[*testNullableRow, testNullableRow](storage, options...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+
+			rows := testNullableRows(100)
+			if err = c.BulkAppend(rows); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			if err = c.DeleteRange(0, 50); err != nil {
+				t.Fatalf("failed to delete rows: %v", err)
+			}
+
+			if err = c.Close(); err != nil {
+				t.Fatalf("failed to close container: %v", err)
+			}
+
+			if c.Filename() != "" {
+				t.Fatalf("expected no filename, got %q", c.Filename())
+			}
+
+			if c, err = OpenStorage[*testNullableRow, testNullableRow](storage, options...); err != nil {
+				t.Fatalf("failed to open container: %v", err)
+			}
+			defer c.Close()
+
+			if c.NumDeleted() != 50 {
+				t.Fatalf("expected 50 deleted rows, got %d", c.NumDeleted())
+			}
+
+			if err = c.Compact(); err != nil {
+				t.Fatalf("failed to compact: %v", err)
+			}
+
+			if c.NumRows() != 50 || c.NumDeleted() != 0 {
+				t.Fatalf("expected 50 live rows, got %d rows and %d deleted", c.NumRows(), c.NumDeleted())
+			}
+
+			if _, err = sidecars.Open(TombstoneExtension, os.O_RDONLY); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("expected tombstones to be removed, got %v", err)
+			}
+
+			checkNullableRows(t, c, rows[50:])
+		})
+	}
+}
+
+func TestReaderStorage(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "reader.sbt")
+
+	c, err := Create[*testRowV2, testRowV2](filename)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	if err = c.Append(&testRowV2{Price: 42, Symbol: "BTCUSDT", Quantity: 7}); err != nil {
+		t.Fatalf("failed to append row: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	r, err := OpenStorageRead[*testRowV2, testRowV2](NewReaderStorage(bytes.NewReader(b), int64(len(b))))
+	if err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer r.Close()
+
+	row := new(testRowV2)
+	if err = r.ReadAt(0, row); err != nil || row.Price != 42 || row.Symbol != "BTCUSDT" {
+		t.Fatalf("unexpected row %+v: %v", row, err)
+	}
+
+	if err = r.Append(row); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected read-only error, got %v", err)
+	}
+
+	w, err := OpenStorage[*testRowV2, testRowV2](NewReaderStorage(bytes.NewReader(b), int64(len(b))))
+	if err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer w.Close()
+
+	if err = w.Append(row); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected read-only storage error, got %v", err)
+	}
+}
+
+func TestMemoryStorageIO(t *testing.T) {
+	s := NewMemoryStorage(make([]byte, 2, 16))
+
+	if _, err := s.WriteAt([]byte("sbt"), 4); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	if b := s.Bytes(); !bytes.Equal(b, []byte("\x00\x00\x00\x00sbt")) {
+		t.Fatalf("unexpected content %q", b)
+	}
+
+	b := make([]byte, 4)
+	if n, err := s.ReadAt(b, 5); n != 2 || err != io.EOF || string(b[:n]) != "bt" {
+		t.Fatalf("unexpected read %d %q: %v", n, b[:n], err)
+	}
+
+	if err := s.Truncate(5); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+
+	if err := s.Truncate(7); err != nil {
+		t.Fatalf("failed to grow: %v", err)
+	}
+
+	if b := s.Bytes(); !bytes.Equal(b, []byte("\x00\x00\x00\x00s\x00\x00")) {
+		t.Fatalf("unexpected content after truncate %q", b)
+	}
+}
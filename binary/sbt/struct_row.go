@@ -0,0 +1,450 @@
+package sbt
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StructTagKey is the struct tag key used to customize StructRow columns.
+const StructTagKey = "sbt"
+
+// StructTag is the parsed form of a `sbt:"name,type,size"` struct tag.
+type StructTag struct {
+	Name string
+	Type ColumnType
+	Size uint32
+	// Precision is the precision of time columns, MaxTimePrecision by default.
+	Precision uint8
+	Skip      bool
+}
+
+// ParseStructTag parses a `sbt:"name,type,size"` struct tag value.
+//
+// Every part is optional, e.g. `sbt:",str,16"` only sets type and size.
+// For time columns the last part is the precision instead, e.g. `sbt:"ts,time,3"` for milliseconds.
+// The value "-" marks the field as skipped.
+func ParseStructTag(tag string) (t StructTag, err error) {
+	if tag == "-" {
+		t.Skip = true
+		return
+	}
+
+	parts := strings.Split(tag, ",")
+	if len(parts) > 3 {
+		err = fmt.Errorf("invalid sbt tag %q: too many parts", tag)
+		return
+	}
+
+	t.Name = strings.TrimSpace(parts[0])
+
+	if len(parts) > 1 {
+		t.Type = ColumnType(strings.TrimSpace(parts[1]))
+	}
+
+	if t.Type == ColumnTypeTime {
+		t.Precision = MaxTimePrecision
+	}
+
+	if len(parts) > 2 && strings.TrimSpace(parts[2]) != "" && t.Type == ColumnTypeTime {
+		var precision uint64
+		if precision, err = strconv.ParseUint(strings.TrimSpace(parts[2]), 10, 8); err != nil || precision > MaxTimePrecision {
+			err = fmt.Errorf("invalid sbt tag %q precision, expected 0 to %d", tag, MaxTimePrecision)
+			return
+		}
+
+		t.Precision = uint8(precision)
+	} else if len(parts) > 2 && strings.TrimSpace(parts[2]) != "" {
+		var size uint64
+		if size, err = strconv.ParseUint(strings.TrimSpace(parts[2]), 10, 32); err != nil {
+			err = fmt.Errorf("invalid sbt tag %q size: %w", tag, err)
+			return
+		}
+
+		t.Size = uint32(size)
+	}
+
+	return
+}
+
+// StructRow adapts any plain struct to the Row interface using reflection.
+//
+// Columns are derived from the exported fields of T in declaration order,
+// named after the field and typed after the field kind. The `sbt:"name,type,size"`
+// tag overrides any of them, and `sbt:"-"` skips the field.
+//
+// Strings and byte slices are stored padded to the column size, or in the heap with the
+// vstr and vbin column types. time.Time is stored as unix nanoseconds in an i64 column,
+// or in a time column of the tag precision, e.g. `sbt:"ts,time,3"`.
+//
+//	c, err := sbt.Create[*sbt.StructRow[Trade], sbt.StructRow[Trade]]("trades.sbt")
+//	err = c.Append(sbt.NewStructRow(Trade{Symbol: "BTCUSDT", Price: 42}))
+type StructRow[T any] struct {
+	Value T
+}
+
+// NewStructRow wraps v in a StructRow.
+func NewStructRow[T any](v T) *StructRow[T] {
+	return &StructRow[T]{Value: v}
+}
+
+// StructSpec returns the RowSpec derived from T, or an error if T can't be used as a row.
+func StructSpec[T any]() (RowSpec, error) {
+	codec, err := structCodecOf(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	return codec.spec, nil
+}
+
+// Factory
+func (r *StructRow[T]) Factory() Row {
+	return new(StructRow[T])
+}
+
+// Columns returns the RowSpec derived from T.
+//
+// It panics if T can't be used as a row, use StructSpec to validate T beforehand.
+func (r *StructRow[T]) Columns() RowSpec {
+	spec, err := StructSpec[T]()
+	if err != nil {
+		panic(err)
+	}
+
+	return spec
+}
+
+// Encode
+func (r *StructRow[T]) Encode(ctx *Encoder) error {
+	codec, err := structCodecOf(reflect.TypeOf(r.Value))
+	if err != nil {
+		return err
+	}
+
+	codec.encode(ctx, reflect.ValueOf(&r.Value).Elem())
+
+	return nil
+}
+
+// Decode
+func (r *StructRow[T]) Decode(ctx *Decoder) error {
+	codec, err := structCodecOf(reflect.TypeOf(r.Value))
+	if err != nil {
+		return err
+	}
+
+	return codec.decode(ctx, reflect.ValueOf(&r.Value).Elem())
+}
+
+// structField is a single column mapped to a struct field.
+type structField struct {
+	index     int
+	column    Column
+	encode    func(e *Encoder, v reflect.Value, size int)
+	decode    func(d *Decoder, v reflect.Value, size int)
+	decodeVar func(d *Decoder, v reflect.Value) error
+}
+
+// structCodec encodes and decodes a struct type column by column.
+type structCodec struct {
+	spec   RowSpec
+	fields []structField
+}
+
+type structCodecResult struct {
+	codec *structCodec
+	err   error
+}
+
+var structCodecCache sync.Map
+
+// structCodecOf returns the cached codec of t, building it on first use.
+func structCodecOf(t reflect.Type) (*structCodec, error) {
+	if cached, ok := structCodecCache.Load(t); ok {
+		res := cached.(structCodecResult)
+		return res.codec, res.err
+	}
+
+	codec, err := newStructCodec(t)
+	structCodecCache.Store(t, structCodecResult{codec, err})
+
+	return codec, err
+}
+
+func newStructCodec(t reflect.Type) (codec *structCodec, err error) {
+	if t == nil || t.Kind() != reflect.Struct {
+		err = fmt.Errorf("sbt: %v is not a struct", t)
+		return
+	}
+
+	codec = &structCodec{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		var tag StructTag
+		if tag, err = ParseStructTag(field.Tag.Get(StructTagKey)); err != nil {
+			err = fmt.Errorf("sbt: field %s.%s: %w", t.Name(), field.Name, err)
+			return
+		}
+
+		if tag.Skip {
+			continue
+		}
+
+		var sf structField
+		if sf, err = newStructField(field, tag); err != nil {
+			err = fmt.Errorf("sbt: field %s.%s: %w", t.Name(), field.Name, err)
+			return
+		}
+
+		sf.index = i
+		codec.fields = append(codec.fields, sf)
+		codec.spec = append(codec.spec, sf.column)
+	}
+
+	if len(codec.fields) == 0 {
+		err = fmt.Errorf("sbt: %s has no columns", t.Name())
+		return
+	}
+
+	return
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// newStructField maps a struct field to a column and its encode/decode functions.
+func newStructField(field reflect.StructField, tag StructTag) (sf structField, err error) {
+	typ, size := tag.Type, tag.Size
+
+	inferred, ok := inferColumnType(field.Type)
+	if !ok {
+		err = fmt.Errorf("unsupported type %s", field.Type)
+		return
+	}
+
+	if typ == "" {
+		typ = inferred
+	} else if !columnTypeCompatible(inferred, typ) && !(field.Type == timeType && typ == ColumnTypeTime) {
+		err = fmt.Errorf("column type %s is not compatible with %s", typ, field.Type)
+		return
+	}
+
+	name := tag.Name
+	if name == "" {
+		name = field.Name
+	}
+
+	if typ.IsVariable() {
+		if field.Type.Kind() == reflect.Array {
+			err = fmt.Errorf("column type %s can't be used with %s", typ, field.Type)
+			return
+		}
+
+		sf.column = NewColumn(name, typ)
+		if size > 0 && size != sf.column.Size {
+			err = fmt.Errorf("column type %s can't have size %d", typ, size)
+			return
+		}
+
+		sf.encode, sf.decodeVar = structFieldVarCodec(field.Type)
+
+		return
+	}
+
+	if size == 0 && field.Type.Kind() == reflect.Array {
+		size = uint32(field.Type.Len())
+	}
+
+	if size > 0 {
+		sf.column = NewColumn(name, typ, size)
+	} else if typ == ColumnTypeTime {
+		sf.column = NewTimeColumn(name, tag.Precision)
+	} else {
+		sf.column = NewColumn(name, typ)
+	}
+
+	if sf.column.Size == 0 {
+		err = fmt.Errorf("unknown size for column type %s", typ)
+		return
+	}
+
+	if typ != ColumnTypeString && typ != ColumnTypeBinary && sf.column.Size != NewColumn(name, typ).Size {
+		err = fmt.Errorf("column type %s can't have size %d", typ, sf.column.Size)
+		return
+	}
+
+	sf.encode, sf.decode = structFieldCodec(field.Type)
+
+	return
+}
+
+// inferColumnType returns the natural column type of a Go type.
+func inferColumnType(t reflect.Type) (ColumnType, bool) {
+	if t == timeType {
+		return ColumnTypeInt64, true
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return ColumnTypeBool, true
+	case reflect.Int8:
+		return ColumnTypeInt8, true
+	case reflect.Int16:
+		return ColumnTypeInt16, true
+	case reflect.Int32:
+		return ColumnTypeInt32, true
+	case reflect.Int, reflect.Int64:
+		return ColumnTypeInt64, true
+	case reflect.Uint8:
+		return ColumnTypeUInt8, true
+	case reflect.Uint16:
+		return ColumnTypeUInt16, true
+	case reflect.Uint32:
+		return ColumnTypeUInt32, true
+	case reflect.Uint, reflect.Uint64:
+		return ColumnTypeUInt64, true
+	case reflect.Float32:
+		return ColumnTypeFloat32, true
+	case reflect.Float64:
+		return ColumnTypeFloat64, true
+	case reflect.String:
+		return ColumnTypeString, true
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return ColumnTypeBinary, true
+		}
+	}
+
+	return "", false
+}
+
+// columnTypeCompatible reports whether a field of inferred type can be stored as typ.
+// Strings and binaries, fixed or variable, are interchangeable, everything else must match exactly.
+func columnTypeCompatible(inferred, typ ColumnType) bool {
+	if inferred == typ {
+		return true
+	}
+
+	isBlob := func(t ColumnType) bool { return t == ColumnTypeString || t == ColumnTypeBinary || t.IsVariable() }
+
+	return isBlob(inferred) && isBlob(typ)
+}
+
+// structFieldCodec returns the encode and decode functions of a Go type.
+func structFieldCodec(t reflect.Type) (
+	enc func(e *Encoder, v reflect.Value, size int),
+	dec func(d *Decoder, v reflect.Value, size int),
+) {
+	if t == timeType {
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeTime(v.Interface().(time.Time)) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.Set(reflect.ValueOf(d.DecodeTime())) }
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeBool(v.Bool()) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.SetBool(d.DecodeBool()) }
+	case reflect.Int8:
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeInt8(int8(v.Int())) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.SetInt(int64(d.DecodeInt8())) }
+	case reflect.Int16:
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeInt16(int16(v.Int())) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.SetInt(int64(d.DecodeInt16())) }
+	case reflect.Int32:
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeInt32(int32(v.Int())) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.SetInt(int64(d.DecodeInt32())) }
+	case reflect.Int, reflect.Int64:
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeInt64(v.Int()) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.SetInt(d.DecodeInt64()) }
+	case reflect.Uint8:
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeUInt8(uint8(v.Uint())) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.SetUint(uint64(d.DecodeUInt8())) }
+	case reflect.Uint16:
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeUInt16(uint16(v.Uint())) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.SetUint(uint64(d.DecodeUInt16())) }
+	case reflect.Uint32:
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeUInt32(uint32(v.Uint())) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.SetUint(uint64(d.DecodeUInt32())) }
+	case reflect.Uint, reflect.Uint64:
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeUInt64(v.Uint()) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.SetUint(d.DecodeUInt64()) }
+	case reflect.Float32:
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeFloat32(float32(v.Float())) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.SetFloat(float64(d.DecodeFloat32())) }
+	case reflect.Float64:
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeFloat64(v.Float()) }
+		dec = func(d *Decoder, v reflect.Value, _ int) { v.SetFloat(d.DecodeFloat64()) }
+	case reflect.String:
+		enc = func(e *Encoder, v reflect.Value, size int) { e.EncodeStringPadded(v.String(), size) }
+		dec = func(d *Decoder, v reflect.Value, size int) { v.SetString(d.DecodeStringPadded(size)) }
+	case reflect.Slice:
+		enc = func(e *Encoder, v reflect.Value, size int) { e.EncodeBytesPadded(v.Bytes(), size) }
+		dec = func(d *Decoder, v reflect.Value, size int) {
+			b := make([]byte, size)
+			copy(b, d.DecodeBytes(size))
+			v.SetBytes(b)
+		}
+	case reflect.Array:
+		enc = func(e *Encoder, v reflect.Value, size int) { e.EncodeBytesPadded(v.Slice(0, v.Len()).Bytes(), size) }
+		dec = func(d *Decoder, v reflect.Value, size int) {
+			reflect.Copy(v, reflect.ValueOf(d.DecodeBytes(size)))
+		}
+	}
+
+	return
+}
+
+// structFieldVarCodec returns the encode and decode functions of a string or byte slice stored in the heap.
+func structFieldVarCodec(t reflect.Type) (
+	enc func(e *Encoder, v reflect.Value, size int),
+	dec func(d *Decoder, v reflect.Value) error,
+) {
+	if t.Kind() == reflect.String {
+		enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeVarString(v.String()) }
+		dec = func(d *Decoder, v reflect.Value) error {
+			s, err := d.DecodeVarString()
+			v.SetString(s)
+			return err
+		}
+		return
+	}
+
+	enc = func(e *Encoder, v reflect.Value, _ int) { e.EncodeVarBytes(v.Bytes()) }
+	dec = func(d *Decoder, v reflect.Value) error {
+		b, err := d.DecodeVarBytes()
+		v.SetBytes(b)
+		return err
+	}
+
+	return
+}
+
+func (c *structCodec) encode(e *Encoder, v reflect.Value) {
+	for _, f := range c.fields {
+		f.encode(e, v.Field(f.index), int(f.column.Size))
+	}
+}
+
+func (c *structCodec) decode(d *Decoder, v reflect.Value) error {
+	for _, f := range c.fields {
+		if f.decodeVar != nil {
+			if err := f.decodeVar(d, v.Field(f.index)); err != nil {
+				return fmt.Errorf("failed to decode column %s: %w", f.column.Name, err)
+			}
+			continue
+		}
+
+		f.decode(d, v.Field(f.index), int(f.column.Size))
+	}
+
+	return nil
+}
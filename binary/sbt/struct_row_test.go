@@ -0,0 +1,100 @@
+package sbt
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type TestStructRow struct {
+	Symbol   string    `sbt:"symbol,str,12"`
+	Price    float64   `sbt:"price"`
+	Volume   uint32    `sbt:"volume"`
+	Side     bool      `sbt:"side"`
+	Time     time.Time `sbt:"time"`
+	Checksum [4]byte   `sbt:"checksum"`
+	Internal string    `sbt:"-"`
+	private  int
+}
+
+func TestStructSpec(t *testing.T) {
+	spec, err := StructSpec[TestStructRow]()
+	if err != nil {
+		t.Fatalf("failed to derive spec: %v", err)
+	}
+
+	expected := NewRowSpec(
+		ColumnTypeString.New("symbol", 12),
+		ColumnTypeFloat64.New("price"),
+		ColumnTypeUInt32.New("volume"),
+		ColumnTypeBool.New("side"),
+		ColumnTypeInt64.New("time"),
+		ColumnTypeBinary.New("checksum", 4),
+	)
+
+	if len(spec) != len(expected) {
+		t.Fatalf("expected %d columns, got %d", len(expected), len(spec))
+	}
+
+	for i := range expected {
+		if spec[i] != expected[i] {
+			t.Fatalf("column %d: expected %+v, got %+v", i, expected[i], spec[i])
+		}
+	}
+
+	type invalidRow struct {
+		Price float64 `sbt:"price,i64"`
+	}
+
+	if _, err = StructSpec[invalidRow](); err == nil {
+		t.Fatalf("expected incompatible column type error")
+	}
+}
+
+func TestStructRowRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "struct.sbt")
+
+	c, err := Create[*StructRow[TestStructRow], StructRow[TestStructRow]](filename)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	now := time.Unix(0, time.Now().UnixNano())
+	rows := []*StructRow[TestStructRow]{
+		NewStructRow(TestStructRow{Symbol: "BTCUSDT", Price: 42.5, Volume: 7, Side: true, Time: now, Checksum: [4]byte{1, 2, 3, 4}}),
+		NewStructRow(TestStructRow{Symbol: "ETHUSDT", Price: 3.25, Volume: 9, Time: now.Add(time.Second)}),
+	}
+
+	if err = c.BulkAppend(rows); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if c, err = Open[*StructRow[TestStructRow], StructRow[TestStructRow]](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	if c.NumRows() != int64(len(rows)) {
+		t.Fatalf("expected %d rows, got %d", len(rows), c.NumRows())
+	}
+
+	for i, expected := range rows {
+		row := new(StructRow[TestStructRow])
+		if err = c.ReadAt(int64(i), row); err != nil {
+			t.Fatalf("failed to read row %d: %v", i, err)
+		}
+
+		if !row.Value.Time.Equal(expected.Value.Time) {
+			t.Fatalf("row %d: expected time %v, got %v", i, expected.Value.Time, row.Value.Time)
+		}
+
+		row.Value.Time = expected.Value.Time
+		if row.Value != expected.Value {
+			t.Fatalf("row %d: expected %+v, got %+v", i, expected.Value, row.Value)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package sbt
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/difof/goul/generics"
+	"github.com/difof/goul/generics/containers"
+)
+
+// DefaultTailInterval is the default polling interval of Container.Tail.
+const DefaultTailInterval = 100 * time.Millisecond
+
+// Refresh re-reads the Container file to pick up rows appended by another process.
+//
+// Rows appended through this Container are visible without it.
+func (c *Container[P, RowType]) Refresh() (err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	from := c.NumRows()
+
+	if err = c.layout.refresh(); err != nil {
+		err = fmt.Errorf("failed to refresh content: %w", err)
+		return
+	}
+
+	// the heap is written before the rows referencing it, so it's refreshed after them
+	if c.heap != nil {
+		if err = c.heap.refresh(); err != nil {
+			return
+		}
+	}
+
+	if err = c.tombstones.refresh(); err != nil {
+		return
+	}
+
+	if c.key != nil && c.key.index != nil && c.NumRows() > from {
+		var keys []int64
+		if keys, err = c.readKeys(from, c.NumRows()-from); err != nil {
+			return
+		}
+
+		c.key.index.update(from, keys)
+	}
+
+	return
+}
+
+// Tail returns an iterator yielding the rows from fromRow on, then following the rows appended to the Container
+// file until ctx is done or the iterator is closed.
+//
+// The file is polled with Refresh every WithTailInterval, so rows appended by another process are picked up.
+// Files written by another process with the block layout are only followed once their blocks are flushed.
+func (c *Container[P, RowType]) Tail(ctx context.Context, fromRow int64) *generics.Iterator[containers.Tuple[int64, P]] {
+	return (&tailIterable[P, RowType]{c: c, ctx: ctx, from: fromRow}).Iter()
+}
+
+// tailIterable is the Iterable of Container.Tail.
+type tailIterable[P generics.Ptr[RowType], RowType any] struct {
+	c    *Container[P, RowType]
+	ctx  context.Context
+	from int64
+}
+
+func (t *tailIterable[P, RowType]) Iter() *generics.Iterator[containers.Tuple[int64, P]] {
+	return generics.NewIterator[containers.Tuple[int64, P]](t)
+}
+
+func (t *tailIterable[P, RowType]) AsIterable() generics.Iterable[containers.Tuple[int64, P]] {
+	return t
+}
+
+func (t *tailIterable[P, RowType]) IterHandler(iter *generics.Iterator[containers.Tuple[int64, P]]) {
+	go func() {
+		defer iter.IterationDone()
+
+		interval := t.c.opts.tailInterval
+		if interval <= 0 {
+			interval = DefaultTailInterval
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		pos := t.from
+
+		for {
+			if numRows := t.c.NumRows(); pos < numRows {
+				n := numRows - pos
+				if n > Bucket1k {
+					n = Bucket1k
+				}
+
+				keys, rows, err := t.c.readLive(pos, n)
+				if err != nil {
+					iter.SetError(err)
+					return
+				}
+
+				for i := range rows {
+					select {
+					case <-t.ctx.Done():
+						return
+					case <-iter.Done():
+						return
+					case iter.NextChannel() <- containers.NewTuple(keys[i], rows[i]):
+					}
+				}
+
+				pos += n
+
+				continue
+			}
+
+			select {
+			case <-t.ctx.Done():
+				return
+			case <-iter.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := t.c.Refresh(); err != nil {
+				iter.SetError(err)
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,108 @@
+package sbt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTail(t *testing.T) {
+	for name, options := range map[string][]Option{
+		"rows":   nil,
+		"blocks": {WithBlockCompression(CompressionZstd, 32)},
+		"mmap":   nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "tail.sbt")
+
+			w, err := Create[*testRowV2, testRowV2](filename, options...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer w.Close()
+
+			appendRows := func(from, n int) {
+				rows := make([]*testRowV2, n)
+				for i := range rows {
+					rows[i] = &testRowV2{Price: uint32(from + i)}
+				}
+
+				if err := w.BulkAppend(rows); err != nil {
+					t.Errorf("failed to append rows: %v", err)
+				}
+
+				if err := w.Flush(); err != nil {
+					t.Errorf("failed to flush rows: %v", err)
+				}
+			}
+
+			appendRows(0, 50)
+
+			// a separate handle follows the file as another process would
+			open := OpenRead[*testRowV2, testRowV2]
+			if name == "mmap" {
+				open = OpenMmap[*testRowV2, testRowV2]
+			}
+
+			r, err := open(filename, WithTailInterval(time.Millisecond))
+			if err != nil {
+				t.Fatalf("failed to open container: %v", err)
+			}
+			defer r.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			done := make(chan struct{})
+			defer func() { <-done }()
+
+			go func() {
+				defer close(done)
+
+				for b := 1; b < 10; b++ {
+					time.Sleep(2 * time.Millisecond)
+					appendRows(b*50, 50)
+				}
+			}()
+
+			it := r.Tail(ctx, 20)
+			defer it.Close()
+
+			next := int64(20)
+			for item := range it.Next() {
+				if item.Key() != next || item.Value().Price != uint32(next) {
+					t.Fatalf("unexpected row %d: %+v", item.Key(), item.Value())
+				}
+
+				if next++; next == 500 {
+					break
+				}
+			}
+
+			if it.Error() != nil || next != 500 {
+				t.Fatalf("tailed up to %d: %v", next, it.Error())
+			}
+		})
+	}
+}
+
+func TestTailCancel(t *testing.T) {
+	c, err := Create[*testRowV2, testRowV2](filepath.Join(t.TempDir(), "tail.sbt"))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it := c.Tail(ctx, 0)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	for range it.Next() {
+		t.Fatalf("unexpected row")
+	}
+}
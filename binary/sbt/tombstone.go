@@ -0,0 +1,555 @@
+package sbt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// TombstoneExtension is appended to the Container filename to get its tombstone bitmap sidecar filename.
+const TombstoneExtension = ".del"
+
+var ErrDeleted = errors.New("row is deleted")
+
+// tombstones is the bitmap of deleted rows, persisted in a sidecar created on the first delete.
+type tombstones struct {
+	mu        sync.RWMutex
+	sidecars  SidecarSet
+	writable  bool
+	file      Storage
+	bits      []byte
+	count     atomic.Int64
+	dirtyFrom int
+	dirtyTo   int
+}
+
+// openTombstones opens the tombstone sidecar, if it exists.
+func openTombstones(sidecars SidecarSet, mode int) (t *tombstones, err error) {
+	t = &tombstones{
+		sidecars: sidecars,
+		writable: mode&(os.O_WRONLY|os.O_RDWR) != 0,
+	}
+
+	if t.file, err = sidecars.Open(TombstoneExtension, mode&^os.O_CREATE); errors.Is(err, os.ErrNotExist) {
+		t.file, err = nil, nil
+		return
+	} else if err != nil {
+		err = fmt.Errorf("failed to open tombstones: %w", err)
+		return
+	}
+
+	err = t.load()
+
+	return
+}
+
+// load reads the bitmap from the sidecar.
+func (t *tombstones) load() (err error) {
+	var b []byte
+	if b, err = io.ReadAll(io.NewSectionReader(t.file, 0, 1<<62)); err != nil {
+		return fmt.Errorf("failed to read tombstones: %w", err)
+	}
+
+	count := 0
+	for _, v := range b {
+		count += bits.OnesCount8(v)
+	}
+
+	t.bits = b
+	t.count.Store(int64(count))
+	t.dirtyFrom, t.dirtyTo = 0, 0
+
+	return
+}
+
+// refresh reloads the bitmap to pick up rows deleted by another process.
+// It's a no-op while deletions are pending, this process being the writer.
+func (t *tombstones) refresh() (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.dirtyFrom != t.dirtyTo {
+		return
+	}
+
+	if t.file == nil {
+		if t.file, err = t.sidecars.Open(TombstoneExtension, os.O_RDONLY); errors.Is(err, os.ErrNotExist) {
+			t.file, err = nil, nil
+			return
+		} else if err != nil {
+			return fmt.Errorf("failed to open tombstones: %w", err)
+		}
+	}
+
+	return t.load()
+}
+
+// isDeleted reports whether the row at pos is deleted.
+func (t *tombstones) isDeleted(pos int64) bool {
+	if t.count.Load() == 0 {
+		return false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	i := int(pos / 8)
+
+	return i < len(t.bits) && t.bits[i]&(1<<(pos%8)) != 0
+}
+
+// set marks count rows starting at pos as deleted or live.
+func (t *tombstones) set(pos, count int64, deleted bool) {
+	if !deleted && t.count.Load() == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for ; count > 0; pos, count = pos+1, count-1 {
+		i, bit := int(pos/8), byte(1<<(pos%8))
+
+		if i >= len(t.bits) {
+			if !deleted {
+				return
+			}
+
+			t.bits = append(t.bits, make([]byte, i+1-len(t.bits))...)
+		}
+
+		if (t.bits[i]&bit != 0) == deleted {
+			continue
+		}
+
+		t.bits[i] ^= bit
+
+		if deleted {
+			t.count.Add(1)
+		} else {
+			t.count.Add(-1)
+		}
+
+		if t.dirtyFrom == t.dirtyTo {
+			t.dirtyFrom, t.dirtyTo = i, i+1
+		} else if i < t.dirtyFrom {
+			t.dirtyFrom = i
+		} else if i >= t.dirtyTo {
+			t.dirtyTo = i + 1
+		}
+	}
+}
+
+// flush writes the updated bytes of the bitmap, creating the sidecar if needed.
+func (t *tombstones) flush() (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.dirtyFrom == t.dirtyTo || !t.writable {
+		return
+	}
+
+	if t.file == nil {
+		if t.file, err = t.sidecars.Open(TombstoneExtension, os.O_RDWR|os.O_CREATE); err != nil {
+			return fmt.Errorf("failed to create tombstones: %w", err)
+		}
+	}
+
+	if _, err = t.file.WriteAt(t.bits[t.dirtyFrom:t.dirtyTo], int64(t.dirtyFrom)); err != nil {
+		return fmt.Errorf("failed to write tombstones: %w", err)
+	}
+
+	t.dirtyFrom, t.dirtyTo = 0, 0
+
+	return
+}
+
+// discard drops the pending deletions, refresh reloads the bitmap afterwards.
+func (t *tombstones) discard() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.dirtyFrom, t.dirtyTo = 0, 0
+}
+
+// truncate clears the bitmap and truncates the sidecar.
+func (t *tombstones) truncate() (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.bits = nil
+	t.count.Store(0)
+	t.dirtyFrom, t.dirtyTo = 0, 0
+
+	if t.file == nil {
+		return
+	}
+
+	if err = t.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate tombstones: %w", err)
+	}
+
+	return t.file.Sync()
+}
+
+// reset clears the bitmap and removes the sidecar.
+func (t *tombstones) reset() (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.bits = nil
+	t.count.Store(0)
+	t.dirtyFrom, t.dirtyTo = 0, 0
+
+	if t.file == nil {
+		return
+	}
+
+	if err = t.file.Close(); err != nil {
+		return
+	}
+
+	t.file = nil
+
+	if err = t.sidecars.Remove(TombstoneExtension); err != nil {
+		return fmt.Errorf("failed to remove tombstones: %w", err)
+	}
+
+	return
+}
+
+// Close
+func (t *tombstones) Close() error {
+	if t.file == nil {
+		return nil
+	}
+
+	return t.file.Close()
+}
+
+// Delete marks the row at pos as deleted, Iter, Tail and BulkRead skip it and ReadAt returns ErrDeleted.
+//
+// Positions of the other rows don't change until Compact. Deletions are persisted in the TombstoneExtension
+// sidecar on Flush or Close. Setting a deleted row makes it live again.
+func (c *Container[P, RowType]) Delete(pos int64) (err error) {
+	return c.DeleteRange(pos, 1)
+}
+
+// DeleteRange marks count rows starting at pos as deleted, see Delete.
+func (c *Container[P, RowType]) DeleteRange(pos, count int64) (err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err = c.checkWritable(); err != nil {
+		return
+	}
+
+	if err = c.checkBounds(pos, count); err != nil {
+		return
+	}
+
+	c.tombstones.set(pos, count, true)
+
+	return
+}
+
+// IsDeleted reports whether the row at pos is deleted.
+func (c *Container[P, RowType]) IsDeleted(pos int64) bool {
+	return c.tombstones.isDeleted(pos)
+}
+
+// NumDeleted returns the number of deleted rows, included in NumRows until Compact.
+func (c *Container[P, RowType]) NumDeleted() int64 {
+	return c.tombstones.count.Load()
+}
+
+// Compact rewrites the Container file without its deleted rows and, in block files, the tail frames replaced by
+// later frames, preserving the header.
+//
+// Row positions change, the sparse index is rebuilt and heap payloads of deleted rows are not reclaimed.
+// Readers keep reading the rows while they're rewritten, and wait while the file is replaced.
+func (c *Container[P, RowType]) Compact() (err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err = c.checkWritable(); err != nil {
+		return
+	}
+
+	if err = c.flush(); err != nil {
+		return
+	}
+
+	// superseded tail frames of block files are dropped too
+	if l, ok := c.layout.(*blockLayout); c.NumDeleted() == 0 && (!ok || l.supersededSize() == 0) {
+		return
+	}
+
+	// readers keep reading the rows while they're rewritten
+	var replace func() error
+	if file, ok := c.storage.(*FileStorage); ok && c.path != "" {
+		replace, err = c.compactFile(file)
+	} else {
+		replace, err = c.compactStorage()
+	}
+
+	if err != nil {
+		return
+	}
+
+	c.swapMu.Lock()
+	defer c.swapMu.Unlock()
+
+	if err = c.closeLayout(); err != nil {
+		return c.restoreLayout(err)
+	}
+
+	if err = replace(); err != nil {
+		return
+	}
+
+	if c.key != nil && c.key.index != nil {
+		if c.key.index.file != nil {
+			if err = c.key.index.file.Truncate(0); err != nil {
+				return fmt.Errorf("failed to truncate index: %w", err)
+			}
+		}
+
+		err = c.rebuildIndex()
+	}
+
+	return
+}
+
+// compactingSuffix and compactedSuffix are appended to the Container filename to get the file compacted into,
+// and its name once it's complete. The compacted file replaces the Container file after the tombstones are removed,
+// so a compaction interrupted in between is completed by recoverCompaction.
+const (
+	compactingSuffix = ".compact"
+	compactedSuffix  = ".compacted"
+)
+
+// compactFile writes the compacted content to a temporary file, and returns the function replacing
+// the Container file with it once the layout is closed.
+func (c *Container[P, RowType]) compactFile(file *FileStorage) (replace func() error, err error) {
+	tmp := c.path + compactingSuffix
+
+	var dst *FileStorage
+	if dst, err = OpenFileStorage(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666); err != nil {
+		return
+	}
+
+	err = c.writeCompacted(dst)
+
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return
+	}
+
+	replace = func() error {
+		return c.replaceFile(file, tmp)
+	}
+
+	return
+}
+
+// replaceFile replaces the Container file with tmp and reopens it.
+// The original file and layout are reopened if tmp can't be marked complete, past that point the compaction
+// is completed when the Container is opened for writing if it fails.
+func (c *Container[P, RowType]) replaceFile(file *FileStorage, tmp string) (err error) {
+	compacted := c.path + compactedSuffix
+
+	if err = file.Close(); err == nil {
+		err = os.Rename(tmp, compacted)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return c.restoreFile(fmt.Errorf("failed to replace file: %w", err))
+	}
+
+	// the tombstones don't apply to the compacted file, they're removed before it replaces the Container file
+	if err = c.tombstones.reset(); err == nil {
+		err = os.Rename(compacted, c.path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	var reopened *FileStorage
+	if reopened, err = OpenFileStorage(c.path, os.O_RDWR, 0666); err != nil {
+		return fmt.Errorf("failed to reopen file: %w", err)
+	}
+
+	c.storage = reopened
+
+	return c.initLayout()
+}
+
+// recoverCompaction completes the compaction of the Container file at path interrupted once the compacted file
+// was complete, removing the tombstones of the original file. The file of an incomplete compaction is removed.
+func recoverCompaction(path string, sidecars SidecarSet) (err error) {
+	if err = os.Remove(path + compactingSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove compacting file: %w", err)
+	}
+
+	compacted := path + compactedSuffix
+	if _, err = os.Stat(compacted); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to recover compaction: %w", err)
+	}
+
+	if err = sidecars.Remove(TombstoneExtension); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to recover compaction: %w", err)
+	}
+
+	if err = os.Rename(compacted, path); err != nil {
+		return fmt.Errorf("failed to recover compaction: %w", err)
+	}
+
+	return nil
+}
+
+// restoreFile reopens the original Container file and its layout after a failed compaction, returning err.
+func (c *Container[P, RowType]) restoreFile(err error) error {
+	file, oerr := OpenFileStorage(c.path, os.O_RDWR, 0666)
+	if oerr != nil {
+		return fmt.Errorf("%w, failed to reopen file: %v", err, oerr)
+	}
+
+	c.storage = file
+
+	return c.restoreLayout(err)
+}
+
+// restoreLayout sets up the layout of the original content after a failed compaction, returning err.
+func (c *Container[P, RowType]) restoreLayout(err error) error {
+	if lerr := c.initLayout(); lerr != nil {
+		return fmt.Errorf("%w, failed to restore layout: %v", err, lerr)
+	}
+
+	return err
+}
+
+// compactStorage compacts the content in memory, and returns the function overwriting the Container storage
+// with it once the layout is closed.
+func (c *Container[P, RowType]) compactStorage() (replace func() error, err error) {
+	dst := NewMemoryStorage(nil)
+	if err = c.writeCompacted(dst); err != nil {
+		return
+	}
+
+	replace = func() error {
+		return c.replaceStorage(dst.Bytes())
+	}
+
+	return
+}
+
+// replaceStorage overwrites the Container storage with the compacted content b, storages not being renamed.
+// The storage and the tombstones are journaled first, so a failed or interrupted compaction is rolled back
+// like a commit.
+func (c *Container[P, RowType]) replaceStorage(b []byte) (err error) {
+	j := &journal{}
+	if err = j.add("", c.storage, [2]int64{0, math.MaxInt64}); err == nil {
+		err = j.add(TombstoneExtension, c.tombstones.file, [2]int64{0, math.MaxInt64})
+	}
+
+	if err == nil {
+		err = c.journal.append(j, true)
+	}
+
+	if err != nil {
+		return c.restoreLayout(fmt.Errorf("failed to journal compaction: %w", err))
+	}
+
+	if _, err = c.storage.WriteAt(b, 0); err == nil {
+		if err = c.storage.Truncate(int64(len(b))); err == nil {
+			err = c.storage.Sync()
+		}
+	}
+
+	// the tombstones are truncated rather than removed, so the journal restores them
+	if err == nil {
+		err = c.tombstones.truncate()
+	}
+
+	if err != nil {
+		err = fmt.Errorf("failed to write compacted content: %w", err)
+
+		if uerr := c.undo(j); uerr != nil {
+			return fmt.Errorf("failed to roll back: %v: %w", uerr, err)
+		}
+
+		return
+	}
+
+	if err = c.journal.clear(true); err != nil {
+		return
+	}
+
+	if err = c.initLayout(); err != nil {
+		return
+	}
+
+	return c.tombstones.reset()
+}
+
+// writeCompacted writes the header and the live rows to dst.
+func (c *Container[P, RowType]) writeCompacted(dst Storage) (err error) {
+	header := make([]byte, c.contentOffset)
+	if _, err = c.storage.ReadAt(header, 0); err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+
+	if _, err = dst.WriteAt(header, 0); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	var l layout
+	if l, err = c.newLayout(dst, false); err != nil {
+		return
+	}
+
+	rowSize := int64(c.spec.RowSize())
+	rows := make([]byte, Bucket10k*rowSize)
+
+	for pos := int64(0); pos < c.NumRows(); pos += Bucket10k {
+		n := c.NumRows() - pos
+		if n > Bucket10k {
+			n = Bucket10k
+		}
+
+		if err = c.layout.readRows(pos, rows[:n*rowSize]); err != nil {
+			return
+		}
+
+		live := rows[:0]
+		for i := int64(0); i < n; i++ {
+			if !c.tombstones.isDeleted(pos + i) {
+				live = append(live, rows[i*rowSize:(i+1)*rowSize]...)
+			}
+		}
+
+		if err = l.appendRows(live); err != nil {
+			return
+		}
+	}
+
+	if err = l.flush(); err != nil {
+		return
+	}
+
+	return dst.Sync()
+}
@@ -0,0 +1,343 @@
+package sbt
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTombstones(t *testing.T) {
+	for name, options := range map[string][]Option{
+		"rows":   {WithChecksum()},
+		"blocks": {WithBlockCompression(CompressionFlate, 16), WithSparseIndex("Price", 8)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "tombstones.sbt")
+
+			c, err := Create[*testRowV2, testRowV2](filename, options...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+
+			rows := make([]*testRowV2, 100)
+			for i := range rows {
+				rows[i] = &testRowV2{Price: uint32(i)}
+			}
+
+			if err = c.BulkAppend(rows); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			if err = c.Delete(10); err != nil {
+				t.Fatalf("failed to delete row: %v", err)
+			}
+
+			if err = c.DeleteRange(20, 10); err != nil {
+				t.Fatalf("failed to delete rows: %v", err)
+			}
+
+			if err = c.Close(); err != nil {
+				t.Fatalf("failed to close container: %v", err)
+			}
+
+			if c, err = Open[*testRowV2, testRowV2](filename); err != nil {
+				t.Fatalf("failed to open container: %v", err)
+			}
+			defer c.Close()
+
+			if !c.IsDeleted(10) || !c.IsDeleted(29) || c.IsDeleted(30) || c.NumDeleted() != 11 {
+				t.Fatalf("unexpected tombstones, %d deleted", c.NumDeleted())
+			}
+
+			if err = c.ReadAt(25, new(testRowV2)); !errors.Is(err, ErrDeleted) {
+				t.Fatalf("expected deleted error, got %v", err)
+			}
+
+			read := make([]*testRowV2, 30)
+			for i := range read {
+				read[i] = new(testRowV2)
+			}
+
+			n, err := c.BulkRead(0, read)
+			if err != nil || n != 19 || read[9].Price != 9 || read[10].Price != 11 || read[18].Price != 19 {
+				t.Fatalf("unexpected bulk read of %d rows: %v", n, err)
+			}
+
+			var printed bytes.Buffer
+			if err = c.Print(&printed, 5, 10, func(row *testRowV2) []any {
+				return []any{row.Price}
+			}); err != nil {
+				t.Fatalf("failed to print rows: %v", err)
+			}
+
+			if strings.Contains(printed.String(), "| 10 |") || !strings.Contains(printed.String(), "| 11 |") {
+				t.Fatalf("unexpected printed rows\n%s", printed.String())
+			}
+
+			it := c.IterBucketSize(Bucket100 / 3)
+			count := 0
+			for item := range it.Next() {
+				if c.IsDeleted(item.Key()) || item.Value().Price != uint32(item.Key()) {
+					t.Fatalf("unexpected row %d: %+v", item.Key(), item.Value())
+				}
+				count++
+			}
+
+			if it.Error() != nil || count != 89 {
+				t.Fatalf("iterated %d rows: %v", count, it.Error())
+			}
+
+			// setting a deleted row revives it
+			if err = c.Set(&testRowV2{Price: 29}, 29); err != nil && !errors.Is(err, ErrImmutableBlock) {
+				t.Fatalf("failed to set row: %v", err)
+			}
+
+			deleted := c.NumDeleted()
+			flags := c.Flags()
+
+			if err = c.Compact(); err != nil {
+				t.Fatalf("failed to compact: %v", err)
+			}
+
+			if c.NumRows() != 100-deleted || c.NumDeleted() != 0 || c.Flags() != flags {
+				t.Fatalf("unexpected compacted container: %d rows, %d deleted, flags %x", c.NumRows(), c.NumDeleted(), c.Flags())
+			}
+
+			if _, err = os.Stat(filename + TombstoneExtension); !os.IsNotExist(err) {
+				t.Fatalf("expected tombstones to be removed: %v", err)
+			}
+
+			row := new(testRowV2)
+			if err = c.ReadAt(10, row); err != nil || row.Price != 11 {
+				t.Fatalf("unexpected row 10 %+v: %v", row, err)
+			}
+
+			if c.Key() != nil {
+				if pos, err := c.SearchFirst(30); err != nil || pos != 30-deleted {
+					t.Fatalf("unexpected search result %d: %v", pos, err)
+				}
+			}
+
+			if err = c.Append(&testRowV2{Price: 100}); err != nil {
+				t.Fatalf("failed to append after compaction: %v", err)
+			}
+
+			if report, err := c.Verify(); err != nil || !report.OK() {
+				t.Fatalf("unexpected report %+v: %v", report, err)
+			}
+		})
+	}
+}
+
+func TestDeleteReadOnly(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "readonly.sbt")
+
+	c, err := Create[*testRowV2, testRowV2](filename)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	if err = c.Append(&testRowV2{}); err != nil {
+		t.Fatalf("failed to append row: %v", err)
+	}
+
+	c.Close()
+
+	if c, err = OpenRead[*testRowV2, testRowV2](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	if err = c.Delete(0); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected read-only error, got %v", err)
+	}
+}
+
+// copyFile copies the file src to dst.
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	if err = os.WriteFile(dst, b, 0666); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+func TestCompactRecovery(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "compact.sbt")
+
+	c, err := Create[*testRowV2, testRowV2](filename)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	if err = c.BulkAppend(testTxRows(0, 100)); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	if err = c.DeleteRange(10, 20); err != nil {
+		t.Fatalf("failed to delete rows: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	// the original file and its tombstones, with or without them being removed yet
+	crashed := []string{filepath.Join(dir, "tombstones.sbt"), filepath.Join(dir, "removed.sbt")}
+	for _, name := range crashed {
+		copyFile(t, filename, name)
+	}
+
+	copyFile(t, filename+TombstoneExtension, crashed[0]+TombstoneExtension)
+
+	if c, err = Open[*testRowV2, testRowV2](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+
+	if err = c.Compact(); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	for _, name := range crashed {
+		copyFile(t, filename, name+compactedSuffix)
+
+		if err = os.WriteFile(name+compactingSuffix, []byte("torn"), 0666); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+
+		// the compaction is completed on open
+		recovered, err := Open[*testRowV2, testRowV2](name)
+		if err != nil {
+			t.Fatalf("failed to open container: %v", err)
+		}
+
+		if recovered.NumRows() != 80 || recovered.NumDeleted() != 0 {
+			t.Fatalf("%s: compaction not recovered, %d rows, %d deleted", name, recovered.NumRows(), recovered.NumDeleted())
+		}
+
+		checkTxPrices(t, recovered, append(testPrices(0, 10), testPrices(30, 70)...)...)
+
+		if err = recovered.Close(); err != nil {
+			t.Fatalf("failed to close container: %v", err)
+		}
+
+		for _, ext := range []string{compactingSuffix, compactedSuffix, TombstoneExtension} {
+			if _, err = os.Stat(name + ext); !os.IsNotExist(err) {
+				t.Fatalf("expected %s to be removed: %v", name+ext, err)
+			}
+		}
+	}
+}
+
+// testPrices returns the prices of testTxRows.
+func testPrices(from, n int) []uint32 {
+	prices := make([]uint32, n)
+	for i := range prices {
+		prices[i] = uint32(from + i)
+	}
+
+	return prices
+}
+
+// syncHookStorage calls hook on Sync, once set.
+type syncHookStorage struct {
+	*MemoryStorage
+	hook func() error
+}
+
+func (s *syncHookStorage) Sync() error {
+	if s.hook != nil {
+		return s.hook()
+	}
+
+	return s.MemoryStorage.Sync()
+}
+
+func TestCompactStorageRecovery(t *testing.T) {
+	storage, sidecars := &syncHookStorage{MemoryStorage: NewMemoryStorage(nil)}, NewMemorySidecars()
+
+	c, err := CreateStorage[*testRowV2, testRowV2](storage, WithSidecars(sidecars))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	defer c.Close()
+
+	if err = c.BulkAppend(testTxRows(0, 100)); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	if err = c.DeleteRange(10, 20); err != nil {
+		t.Fatalf("failed to delete rows: %v", err)
+	}
+
+	if err = c.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+
+	// the storage is overwritten when it's synced, the crash copies its content and the sidecars
+	var content []byte
+	crashed := NewMemorySidecars()
+	storage.hook = func() error {
+		content = append([]byte(nil), storage.Bytes()...)
+
+		for _, ext := range []string{JournalExtension, TombstoneExtension} {
+			src, err := sidecars.Open(ext, os.O_RDONLY)
+			if err != nil {
+				t.Fatalf("failed to open sidecar: %v", err)
+			}
+
+			size, _ := src.Size()
+			b := make([]byte, size)
+			src.ReadAt(b, 0)
+
+			dst, _ := crashed.Open(ext, os.O_RDWR|os.O_CREATE)
+			dst.WriteAt(b, 0)
+		}
+
+		storage.hook = nil
+
+		return errors.New("crash")
+	}
+
+	expected := append(testPrices(0, 10), testPrices(30, 70)...)
+
+	if err = c.Compact(); err == nil {
+		t.Fatalf("expected compaction error")
+	}
+
+	// the failed compaction is rolled back
+	row := new(testRowV2)
+	if err = c.ReadAt(30, row); err != nil || row.Price != 30 || c.NumRows() != 100 || c.NumDeleted() != 20 {
+		t.Fatalf("compaction not rolled back, %d rows, %d deleted: %v", c.NumRows(), c.NumDeleted(), err)
+	}
+
+	// and so is the interrupted one
+	recovered, err := OpenStorage[*testRowV2, testRowV2](NewMemoryStorage(content), WithSidecars(crashed))
+	if err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer recovered.Close()
+
+	if recovered.NumRows() != 100 || recovered.NumDeleted() != 20 {
+		t.Fatalf("compaction not rolled back, %d rows, %d deleted", recovered.NumRows(), recovered.NumDeleted())
+	}
+
+	if err = c.Compact(); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+
+	checkTxPrices(t, c, expected...)
+}
@@ -0,0 +1,451 @@
+package sbt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/difof/goul/generics"
+)
+
+// Durability selects when committed transactions are synced to durable storage, see WithDurability.
+type Durability uint8
+
+const (
+	// DurabilityNone never syncs. Transactions survive a crash of the process, not of the system.
+	DurabilityNone Durability = iota
+	// DurabilityCommit syncs every commit before it returns, the default.
+	DurabilityCommit
+	// DurabilityInterval syncs commits once the sync interval elapsed since the last sync.
+	// The commits made in between survive a crash of the process, a crash of the system may lose them.
+	DurabilityInterval
+)
+
+// DefaultSyncInterval is the default sync interval of DurabilityInterval.
+const DefaultSyncInterval = time.Second
+
+var ErrTxDone = errors.New("transaction already committed or rolled back")
+
+// Tx is a batch of writes applied atomically by Commit: after a crash, either all of them
+// or none of them are visible once the Container is reopened.
+//
+// Writes are buffered until Commit, they're not visible to reads of the Container before.
+// A Tx isn't safe for concurrent use.
+type Tx[P generics.Ptr[RowType], RowType any] struct {
+	c    *Container[P, RowType]
+	ops  []txOp[P]
+	done bool
+}
+
+// txOp is a buffered write of a Tx, pos is -1 for appends.
+type txOp[P any] struct {
+	pos  int64
+	rows []P
+}
+
+// txWrite is an encoded txOp.
+type txWrite struct {
+	pos  int64
+	rows []byte
+}
+
+// Begin starts a transaction.
+func (c *Container[P, RowType]) Begin() (tx *Tx[P, RowType], err error) {
+	if err = c.checkWritable(); err != nil {
+		return
+	}
+
+	return &Tx[P, RowType]{c: c}, nil
+}
+
+// Append appends rows on Commit.
+func (tx *Tx[P, RowType]) Append(rows ...P) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	tx.ops = append(tx.ops, txOp[P]{pos: -1, rows: rows})
+
+	return nil
+}
+
+// Set overwrites the rows starting at index on Commit, including rows appended earlier by the transaction.
+func (tx *Tx[P, RowType]) Set(index int64, rows ...P) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	if index < 0 {
+		return fmt.Errorf("negative index %d", index)
+	}
+
+	tx.ops = append(tx.ops, txOp[P]{pos: index, rows: rows})
+
+	return nil
+}
+
+// Rollback drops the buffered writes.
+func (tx *Tx[P, RowType]) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	tx.done = true
+	tx.ops = nil
+
+	return nil
+}
+
+// Commit applies the buffered writes, in order.
+//
+// The Container storage and sidecars are journaled first, so if the commit fails or the process crashes,
+// it's rolled back, on failure or when the Container is reopened. The commit is synced according
+// to the Durability of the Container.
+func (tx *Tx[P, RowType]) Commit() (err error) {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	tx.done = true
+
+	return tx.c.commit(tx.ops)
+}
+
+// commit applies the ops of a transaction.
+func (c *Container[P, RowType]) commit(ops []txOp[P]) (err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err = c.checkWritable(); err != nil {
+		return
+	}
+
+	if err = c.flush(); err != nil {
+		return
+	}
+
+	var writes []txWrite
+	if writes, err = c.encodeOps(ops); err != nil {
+		return
+	}
+
+	var j *journal
+	if j, err = c.newJournal(writes); err == nil {
+		err = c.journal.append(j, c.opts.durability != DurabilityNone)
+	}
+
+	if err != nil {
+		if c.heap != nil {
+			c.heap.discard()
+		}
+
+		return
+	}
+
+	if err = c.applyWrites(writes); err != nil {
+		if uerr := c.undo(j); uerr != nil {
+			return fmt.Errorf("failed to roll back: %v: %w", uerr, err)
+		}
+
+		return
+	}
+
+	return c.settle()
+}
+
+// settle clears the journal records of applied writes, syncing them according to the Durability of the Container.
+// Records of commits that aren't synced are cleared too, or reopening the Container would roll them back.
+func (c *Container[P, RowType]) settle() error {
+	switch c.opts.durability {
+	case DurabilityCommit:
+		return c.sync()
+	case DurabilityInterval:
+		if time.Since(c.journal.synced) >= c.opts.syncInterval {
+			return c.sync()
+		}
+
+		c.journal.pending = true
+	}
+
+	return c.journal.clear(false)
+}
+
+// encodeOps encodes the rows of ops, checking they're within the bounds of the Container once
+// the previous ops are applied. Their heap payloads are reserved, not written.
+func (c *Container[P, RowType]) encodeOps(ops []txOp[P]) (writes []txWrite, err error) {
+	rowSize := int(c.spec.RowSize())
+	numRows := c.NumRows()
+	encoder := c.newEncoder(nil)
+
+	for _, op := range ops {
+		n := int64(len(op.rows))
+
+		if op.pos < 0 {
+			numRows += n
+		} else if op.pos+n > numRows {
+			if c.heap != nil {
+				c.heap.discard()
+			}
+
+			return nil, fmt.Errorf("index out of bounds: %d > %d", op.pos+n, numRows)
+		}
+
+		w := txWrite{pos: op.pos, rows: make([]byte, len(op.rows)*rowSize)}
+
+		for i, row := range op.rows {
+			encoder.Reset(w.rows[i*rowSize : (i+1)*rowSize])
+
+			if err = c.encodeRow(encoder, row); err != nil {
+				return
+			}
+		}
+
+		writes = append(writes, w)
+	}
+
+	return
+}
+
+// newJournal records the state of the Container storage and sidecars overwritten by writes.
+func (c *Container[P, RowType]) newJournal(writes []txWrite) (j *journal, err error) {
+	j = &journal{}
+
+	var content, deleted [][2]int64
+
+	switch l := c.layout.(type) {
+	case *rowLayout:
+		for _, w := range writes {
+			if w.pos >= 0 {
+				n := int64(len(w.rows)) / l.rowSize
+				content = append(content, [2]int64{l.offset + w.pos*l.stride, l.offset + (w.pos+n)*l.stride})
+			}
+		}
+	case *blockLayout:
+		// frames are only appended after the end
+		l.mu.RLock()
+		content = append(content, [2]int64{l.end, math.MaxInt64})
+		l.mu.RUnlock()
+	}
+
+	for _, w := range writes {
+		if w.pos >= 0 {
+			n := int64(len(w.rows)) / int64(c.spec.RowSize())
+			deleted = append(deleted, [2]int64{w.pos / 8, (w.pos + n + 7) / 8})
+		}
+	}
+
+	if err = j.add("", c.storage, content...); err != nil {
+		return nil, fmt.Errorf("failed to journal content: %w", err)
+	}
+
+	if c.heap != nil {
+		if err = j.add(HeapExtension, c.heap.file); err != nil {
+			return nil, fmt.Errorf("failed to journal heap: %w", err)
+		}
+	}
+
+	if c.key != nil && c.key.index != nil && c.key.index.file != nil {
+		if err = j.add(IndexExtension, c.key.index.file, [2]int64{0, math.MaxInt64}); err != nil {
+			return nil, fmt.Errorf("failed to journal index: %w", err)
+		}
+	}
+
+	if err = j.add(TombstoneExtension, c.tombstones.file, deleted...); err != nil {
+		return nil, fmt.Errorf("failed to journal tombstones: %w", err)
+	}
+
+	return
+}
+
+// applyWrites writes the heap payloads, then the rows of writes.
+func (c *Container[P, RowType]) applyWrites(writes []txWrite) (err error) {
+	if err = c.flushHeap(); err != nil {
+		return
+	}
+
+	for _, w := range writes {
+		if w.pos < 0 {
+			err = c.appendRows(w.rows)
+		} else {
+			err = c.writeRows(w.pos, w.rows)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to write rows: %w", err)
+		}
+	}
+
+	return c.flush()
+}
+
+// undo rolls back the failed commit journaled by j, then reloads the Container state.
+func (c *Container[P, RowType]) undo(j *journal) (err error) {
+	if c.heap != nil {
+		c.heap.discard()
+	}
+
+	if err = j.undo(c.storage, c.sidecars); err != nil {
+		return
+	}
+
+	if err = c.journal.drop(); err != nil {
+		return
+	}
+
+	if err = c.initLayout(); err != nil {
+		return
+	}
+
+	if c.heap != nil {
+		if err = c.heap.refresh(); err != nil {
+			return
+		}
+	}
+
+	c.tombstones.discard()
+	if err = c.tombstones.refresh(); err != nil {
+		return
+	}
+
+	if c.key != nil && c.key.index != nil {
+		err = c.rebuildIndex()
+	}
+
+	return
+}
+
+// Sync commits the written rows and sidecars to durable storage, including the transactions
+// not synced yet with DurabilityInterval.
+func (c *Container[P, RowType]) Sync() (err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err = c.checkWritable(); err != nil {
+		return
+	}
+
+	if err = c.flush(); err != nil {
+		return
+	}
+
+	return c.sync()
+}
+
+// sync is Sync without locking, it clears the journal once the Container is synced.
+func (c *Container[P, RowType]) sync() (err error) {
+	files := []Storage{c.storage}
+
+	if c.heap != nil {
+		files = append(files, c.heap.file)
+	}
+
+	if c.key != nil && c.key.index != nil && c.key.index.file != nil {
+		files = append(files, c.key.index.file)
+	}
+
+	if c.tombstones != nil && c.tombstones.file != nil {
+		files = append(files, c.tombstones.file)
+	}
+
+	for _, file := range files {
+		if err = file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync: %w", err)
+		}
+	}
+
+	return c.journal.clear(true)
+}
+
+// txJournal is the journal sidecar of a Container, opened by the first commit.
+type txJournal struct {
+	sidecars SidecarSet
+	file     Storage
+	// size is the size of the records of the commit being applied, last the offset of the last one.
+	size   int64
+	last   int64
+	synced time.Time
+	// pending is set by commits not synced yet with DurabilityInterval.
+	pending bool
+}
+
+// append writes the record of j, syncing it if durable.
+func (t *txJournal) append(j *journal, durable bool) (err error) {
+	if t.file == nil {
+		if t.file, err = t.sidecars.Open(JournalExtension, os.O_RDWR|os.O_CREATE|os.O_TRUNC); err != nil {
+			return fmt.Errorf("failed to open journal: %w", err)
+		}
+
+		t.size, t.synced = 0, time.Now()
+	}
+
+	b := j.marshal()
+	if _, err = t.file.WriteAt(b, t.size); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	if durable {
+		if err = t.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync journal: %w", err)
+		}
+	}
+
+	t.last = t.size
+	t.size += int64(len(b))
+
+	return
+}
+
+// drop removes the last record, once it's undone.
+func (t *txJournal) drop() (err error) {
+	if err = t.file.Truncate(t.last); err != nil {
+		return fmt.Errorf("failed to truncate journal: %w", err)
+	}
+
+	t.size = t.last
+
+	return t.file.Sync()
+}
+
+// clear removes the records, syncing the journal if durable, which marks the Container synced.
+func (t *txJournal) clear(durable bool) (err error) {
+	if durable {
+		t.synced, t.pending = time.Now(), false
+	}
+
+	if t.file == nil || t.size == 0 {
+		return
+	}
+
+	if err = t.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to clear journal: %w", err)
+	}
+
+	t.size, t.last = 0, 0
+
+	if durable {
+		err = t.file.Sync()
+	}
+
+	return
+}
+
+// Close closes the journal sidecar and removes it, it must be cleared first.
+func (t *txJournal) Close() (err error) {
+	if t.file == nil {
+		return
+	}
+
+	if err = t.file.Close(); err != nil {
+		return
+	}
+
+	t.file = nil
+
+	if t.size == 0 {
+		err = t.sidecars.Remove(JournalExtension)
+	}
+
+	return
+}
@@ -0,0 +1,352 @@
+package sbt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testTxRows(from, n int) []*testRowV2 {
+	rows := make([]*testRowV2, n)
+	for i := range rows {
+		rows[i] = &testRowV2{Price: uint32(from + i), Symbol: "TX"}
+	}
+
+	return rows
+}
+
+func checkTxPrices(t *testing.T, c *Container[*testRowV2, testRowV2], prices ...uint32) {
+	t.Helper()
+
+	if c.NumRows() != int64(len(prices)) {
+		t.Fatalf("expected %d rows, got %d", len(prices), c.NumRows())
+	}
+
+	for i, price := range prices {
+		row := new(testRowV2)
+		if err := c.ReadAt(int64(i), row); err != nil {
+			t.Fatalf("failed to read row %d: %v", i, err)
+		}
+
+		if row.Price != price {
+			t.Fatalf("row %d: expected price %d, got %d", i, price, row.Price)
+		}
+	}
+}
+
+func TestTx(t *testing.T) {
+	for name, options := range map[string][]Option{
+		"rows":   {WithChecksum(), WithSparseIndex("Price", 4)},
+		"blocks": {WithBlockCompression(CompressionFlate, 16), WithSparseIndex("Price", 4)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "tx.sbt")
+
+			c, err := Create[*testRowV2, testRowV2](filename, options...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+
+			if err = c.BulkAppend(testTxRows(0, 10)); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			tx, err := c.Begin()
+			if err != nil {
+				t.Fatalf("failed to begin: %v", err)
+			}
+
+			if err = tx.Append(testTxRows(10, 5)...); err != nil {
+				t.Fatalf("failed to append: %v", err)
+			}
+
+			if err = tx.Set(8, testTxRows(100, 1)...); err != nil {
+				t.Fatalf("failed to set: %v", err)
+			}
+
+			if err = tx.Set(12, testTxRows(200, 1)...); err != nil {
+				t.Fatalf("failed to set: %v", err)
+			}
+
+			if c.NumRows() != 10 {
+				t.Fatalf("uncommitted rows are visible, %d rows", c.NumRows())
+			}
+
+			if err = tx.Commit(); err != nil {
+				t.Fatalf("failed to commit: %v", err)
+			}
+
+			if err = tx.Commit(); !errors.Is(err, ErrTxDone) {
+				t.Fatalf("expected done error, got %v", err)
+			}
+
+			expected := []uint32{0, 1, 2, 3, 4, 5, 6, 7, 100, 9, 10, 11, 200, 13, 14}
+			checkTxPrices(t, c, expected...)
+
+			if tx, err = c.Begin(); err != nil {
+				t.Fatalf("failed to begin: %v", err)
+			}
+
+			if err = tx.Append(testTxRows(20, 5)...); err != nil {
+				t.Fatalf("failed to append: %v", err)
+			}
+
+			if err = tx.Rollback(); err != nil {
+				t.Fatalf("failed to roll back: %v", err)
+			}
+
+			if err = tx.Append(testTxRows(20, 5)...); !errors.Is(err, ErrTxDone) {
+				t.Fatalf("expected done error, got %v", err)
+			}
+
+			if err = c.Close(); err != nil {
+				t.Fatalf("failed to close container: %v", err)
+			}
+
+			if _, err = os.Stat(filename + JournalExtension); !os.IsNotExist(err) {
+				t.Fatalf("expected journal to be removed, got %v", err)
+			}
+
+			if c, err = Open[*testRowV2, testRowV2](filename); err != nil {
+				t.Fatalf("failed to open container: %v", err)
+			}
+			defer c.Close()
+
+			checkTxPrices(t, c, expected...)
+
+			if pos, err := c.SearchFirst(200); err != nil || pos != 12 {
+				t.Fatalf("expected key 200 at 12, got %d: %v", pos, err)
+			}
+		})
+	}
+}
+
+// crashCommit applies tx like Commit, but leaves its record in the journal as a crash before the sync would.
+func crashCommit(t *testing.T, tx *Tx[*testRowV2, testRowV2]) {
+	t.Helper()
+
+	c := tx.c
+
+	writes, err := c.encodeOps(tx.ops)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	j, err := c.newJournal(writes)
+	if err != nil {
+		t.Fatalf("failed to journal: %v", err)
+	}
+
+	if err = c.journal.append(j, true); err != nil {
+		t.Fatalf("failed to write journal: %v", err)
+	}
+
+	if err = c.applyWrites(writes); err != nil {
+		t.Fatalf("failed to apply: %v", err)
+	}
+}
+
+func TestTxRecovery(t *testing.T) {
+	for name, options := range map[string][]Option{
+		"rows":   {WithChecksum()},
+		"blocks": {WithBlockCompression(CompressionNone, 16)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			storage, sidecars := NewMemoryStorage(nil), NewMemorySidecars()
+
+			c, err := CreateStorage[*testRowV2, testRowV2](storage, append(options, WithSidecars(sidecars))...)
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+
+			if err = c.BulkAppend(testTxRows(0, 10)); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			if err = c.Delete(3); err != nil {
+				t.Fatalf("failed to delete: %v", err)
+			}
+
+			if err = c.Flush(); err != nil {
+				t.Fatalf("failed to flush: %v", err)
+			}
+
+			tx, err := c.Begin()
+			if err != nil {
+				t.Fatalf("failed to begin: %v", err)
+			}
+
+			tx.Set(2, testTxRows(100, 2)...)
+			tx.Append(testTxRows(10, 20)...)
+
+			crashCommit(t, tx)
+
+			if c.NumRows() != 30 || c.IsDeleted(3) {
+				t.Fatalf("commit not applied, %d rows", c.NumRows())
+			}
+
+			// a torn record of a later commit is ignored
+			journal, err := sidecars.Open(JournalExtension, os.O_RDWR)
+			if err != nil {
+				t.Fatalf("failed to open journal: %v", err)
+			}
+
+			size, _ := journal.Size()
+			if _, err = journal.WriteAt(journalMagic, size); err != nil {
+				t.Fatalf("failed to write journal: %v", err)
+			}
+
+			// reopen the crashed content without closing c
+			recovered, err := OpenStorage[*testRowV2, testRowV2](NewMemoryStorage(storage.Bytes()), WithSidecars(sidecars))
+			if err != nil {
+				t.Fatalf("failed to recover container: %v", err)
+			}
+			defer recovered.Close()
+
+			if recovered.NumRows() != 10 || !recovered.IsDeleted(3) {
+				t.Fatalf("commit not rolled back, %d rows", recovered.NumRows())
+			}
+
+			row := new(testRowV2)
+			if err = recovered.ReadAt(2, row); err != nil || row.Price != 2 {
+				t.Fatalf("row 2 not rolled back, price %d: %v", row.Price, err)
+			}
+
+			if size, _ = journal.Size(); size != 0 {
+				t.Fatalf("expected journal to be cleared, got %d bytes", size)
+			}
+		})
+	}
+}
+
+func TestTxFailure(t *testing.T) {
+	storage, sidecars := NewMemoryStorage(nil), NewMemorySidecars()
+
+	c, err := CreateStorage[*testRowV2, testRowV2](storage, WithBlockCompression(CompressionFlate, 16),
+		WithSidecars(sidecars))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	defer c.Close()
+
+	if err = c.BulkAppend(testTxRows(0, 20)); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	if err = c.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+
+	size := c.Size()
+
+	tx, _ := c.Begin()
+	tx.Append(testTxRows(20, 30)...)
+	tx.Set(25, testTxRows(100, 1)...)
+	tx.Set(40, testTxRows(100, 20)...)
+
+	if err = tx.Commit(); err == nil {
+		t.Fatalf("expected out of bounds error")
+	}
+
+	// rows of full blocks are immutable, the commit fails once its appends are applied
+	tx, _ = c.Begin()
+	tx.Append(testTxRows(20, 30)...)
+	tx.Set(0, testTxRows(100, 1)...)
+
+	if err = tx.Commit(); !errors.Is(err, ErrImmutableBlock) {
+		t.Fatalf("expected immutable block error, got %v", err)
+	}
+
+	if c.NumRows() != 20 || c.Size() != size {
+		t.Fatalf("commit not rolled back, %d rows", c.NumRows())
+	}
+
+	if journal, err := sidecars.Open(JournalExtension, os.O_RDONLY); err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	} else if size, _ := journal.Size(); size != 0 {
+		t.Fatalf("expected journal to be cleared, got %d bytes", size)
+	}
+
+	if err = c.Append(&testRowV2{Price: 20}); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+
+	checkTxPrices(t, c, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20)
+}
+
+func TestTxDurability(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "durability.sbt")
+
+	c, err := Create[*testRowV2, testRowV2](filename, WithSyncInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		tx, _ := c.Begin()
+		tx.Append(testTxRows(i, 1)...)
+
+		if err = tx.Commit(); err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+	}
+
+	// the records of applied commits are cleared, even before they're synced
+	if stat, err := os.Stat(filename + JournalExtension); err != nil || stat.Size() != 0 {
+		t.Fatalf("expected cleared journal: %v", err)
+	}
+
+	// a crash of the process before the sync keeps the commits
+	crashed := filepath.Join(t.TempDir(), "crashed.sbt")
+	for _, ext := range []string{"", JournalExtension} {
+		b, err := os.ReadFile(filename + ext)
+		if err != nil {
+			t.Fatalf("failed to read file: %v", err)
+		}
+
+		if err = os.WriteFile(crashed+ext, b, 0666); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	reopened, err := Open[*testRowV2, testRowV2](crashed)
+	if err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+
+	checkTxPrices(t, reopened, 0, 1, 2)
+
+	if err = reopened.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if err = c.Sync(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+
+	if stat, err := os.Stat(filename + JournalExtension); err != nil || stat.Size() != 0 {
+		t.Fatalf("expected cleared journal: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if c, err = Open[*testRowV2, testRowV2](filename, WithDurability(DurabilityNone)); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	checkTxPrices(t, c, 0, 1, 2)
+
+	tx, _ := c.Begin()
+	tx.Append(testTxRows(3, 1)...)
+
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	checkTxPrices(t, c, 0, 1, 2, 3)
+}
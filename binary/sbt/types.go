@@ -0,0 +1,203 @@
+package sbt
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ByteOrder is the byte order of the numeric columns of a file, recorded in its header.
+type ByteOrder string
+
+const (
+	LittleEndian ByteOrder = "little"
+	BigEndian    ByteOrder = "big"
+)
+
+// binary returns the encoding/binary byte order of o, files without a recorded byte order are little endian.
+func (o ByteOrder) binary() (binary.ByteOrder, error) {
+	switch o {
+	case "", LittleEndian:
+		return binary.LittleEndian, nil
+	case BigEndian:
+		return binary.BigEndian, nil
+	}
+
+	return nil, fmt.Errorf("unknown byte order %q", o)
+}
+
+const (
+	// MaxTimePrecision is the precision of nanosecond timestamps.
+	MaxTimePrecision = 9
+	// MaxDecimalScale is the largest scale of decimals, keeping 10^scale within an int64.
+	MaxDecimalScale = 18
+)
+
+var pow10 = func() (p [MaxDecimalScale + 1]int64) {
+	p[0] = 1
+	for i := 1; i < len(p); i++ {
+		p[i] = p[i-1] * 10
+	}
+
+	return
+}()
+
+// timeUnits returns t as a count of 10^-precision seconds since the unix epoch.
+func timeUnits(t time.Time, precision uint8) int64 {
+	if precision > MaxTimePrecision {
+		precision = MaxTimePrecision
+	}
+
+	return t.Unix()*pow10[precision] + int64(t.Nanosecond())/pow10[MaxTimePrecision-precision]
+}
+
+// unitsTime returns the time of a count of 10^-precision seconds since the unix epoch.
+func unitsTime(v int64, precision uint8) time.Time {
+	if precision > MaxTimePrecision {
+		precision = MaxTimePrecision
+	}
+
+	return time.Unix(v/pow10[precision], v%pow10[precision]*pow10[MaxTimePrecision-precision])
+}
+
+// Decimal is a fixed-point decimal number, Unscaled * 10^-Scale.
+type Decimal struct {
+	Unscaled int64
+	Scale    uint8
+}
+
+// NewDecimal returns the decimal unscaled * 10^-scale.
+func NewDecimal(unscaled int64, scale uint8) Decimal {
+	return Decimal{Unscaled: unscaled, Scale: scale}
+}
+
+// DecimalFromFloat returns f rounded to scale digits after the decimal point.
+func DecimalFromFloat(f float64, scale uint8) (d Decimal, err error) {
+	if scale > MaxDecimalScale {
+		return d, fmt.Errorf("decimal scale %d exceeds %d", scale, MaxDecimalScale)
+	}
+
+	unscaled := math.Round(f * float64(pow10[scale]))
+	if math.IsNaN(unscaled) || unscaled >= math.MaxInt64 || unscaled < math.MinInt64 {
+		return d, fmt.Errorf("value %g overflows decimal(%d)", f, scale)
+	}
+
+	return Decimal{Unscaled: int64(unscaled), Scale: scale}, nil
+}
+
+// ParseDecimal parses a decimal number like "-12.340", its scale being the number of digits after the point.
+func ParseDecimal(s string) (d Decimal, err error) {
+	digits := s
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		digits = s[:i] + s[i+1:]
+		if len(s)-i-1 > MaxDecimalScale {
+			return d, fmt.Errorf("decimal %q has more than %d digits after the point", s, MaxDecimalScale)
+		}
+
+		d.Scale = uint8(len(s) - i - 1)
+	}
+
+	if d.Unscaled, err = strconv.ParseInt(digits, 10, 64); err != nil {
+		return d, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	return
+}
+
+// Rescale returns d with scale digits after the point, truncating extra digits.
+func (d Decimal) Rescale(scale uint8) (Decimal, error) {
+	if scale > MaxDecimalScale || d.Scale > MaxDecimalScale {
+		return d, fmt.Errorf("decimal scale %d exceeds %d", scale, MaxDecimalScale)
+	}
+
+	if scale <= d.Scale {
+		return Decimal{Unscaled: d.Unscaled / pow10[d.Scale-scale], Scale: scale}, nil
+	}
+
+	m := pow10[scale-d.Scale]
+	if d.Unscaled > math.MaxInt64/m || d.Unscaled < math.MinInt64/m {
+		return d, fmt.Errorf("decimal %s overflows decimal(%d)", d, scale)
+	}
+
+	return Decimal{Unscaled: d.Unscaled * m, Scale: scale}, nil
+}
+
+// Float64 returns the nearest float64 of d.
+func (d Decimal) Float64() float64 {
+	return float64(d.Unscaled) / math.Pow10(int(d.Scale))
+}
+
+// String formats d with exactly Scale digits after the point.
+func (d Decimal) String() string {
+	s := strconv.FormatInt(d.Unscaled, 10)
+	if d.Scale == 0 {
+		return s
+	}
+
+	sign := ""
+	if d.Unscaled < 0 {
+		sign, s = "-", s[1:]
+	}
+
+	if pad := int(d.Scale) + 1 - len(s); pad > 0 {
+		s = strings.Repeat("0", pad) + s
+	}
+
+	return sign + s[:len(s)-int(d.Scale)] + "." + s[len(s)-int(d.Scale):]
+}
+
+// MarshalText
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText
+func (d *Decimal) UnmarshalText(b []byte) (err error) {
+	*d, err = ParseDecimal(string(b))
+	return
+}
+
+// UUID is a 16 bytes universally unique identifier.
+type UUID [16]byte
+
+// ParseUUID parses the canonical xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx form of a UUID.
+func ParseUUID(s string) (u UUID, err error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid uuid %q", s)
+	}
+
+	b := []byte(s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	if _, err = hex.Decode(u[:], b); err != nil {
+		return u, fmt.Errorf("invalid uuid %q", s)
+	}
+
+	return
+}
+
+// String returns the canonical form of u.
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[:8], u[:4])
+	hex.Encode(b[9:13], u[4:6])
+	hex.Encode(b[14:18], u[6:8])
+	hex.Encode(b[19:23], u[8:10])
+	hex.Encode(b[24:], u[10:])
+	b[8], b[13], b[18], b[23] = '-', '-', '-', '-'
+
+	return string(b[:])
+}
+
+// MarshalText
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText
+func (u *UUID) UnmarshalText(b []byte) (err error) {
+	*u, err = ParseUUID(string(b))
+	return
+}
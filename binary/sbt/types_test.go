@@ -0,0 +1,348 @@
+package sbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testTypedRow struct {
+	Time    time.Time
+	Millis  time.Time
+	Price   Decimal
+	ID      UUID
+	Client  netip.Addr
+	Peer    netip.Addr
+	Counter uint32
+}
+
+func (r *testTypedRow) Factory() Row {
+	return new(testTypedRow)
+}
+
+func (r *testTypedRow) Columns() RowSpec {
+	return NewRowSpec(
+		ColumnTypeTime.New("Time"),
+		NewTimeColumn("Millis", 3),
+		NewDecimalColumn("Price", 4),
+		ColumnTypeUUID.New("ID"),
+		ColumnTypeIPv4.New("Client"),
+		ColumnTypeIPv6.New("Peer"),
+		ColumnTypeUInt32.New("Counter"),
+	)
+}
+
+func (r *testTypedRow) Encode(ctx *Encoder) error {
+	ctx.EncodeTimestamp(r.Time, MaxTimePrecision)
+	ctx.EncodeTimestamp(r.Millis, 3)
+	if err := ctx.EncodeDecimal(r.Price, 4); err != nil {
+		return err
+	}
+	ctx.EncodeUUID(r.ID)
+	ctx.EncodeIPv4(r.Client)
+	ctx.EncodeIPv6(r.Peer)
+	ctx.EncodeUInt32(r.Counter)
+
+	return nil
+}
+
+func (r *testTypedRow) Decode(ctx *Decoder) error {
+	r.Time = ctx.DecodeTimestamp(MaxTimePrecision)
+	r.Millis = ctx.DecodeTimestamp(3)
+	r.Price = ctx.DecodeDecimal(4)
+	r.ID = ctx.DecodeUUID()
+	r.Client = ctx.DecodeIPv4()
+	r.Peer = ctx.DecodeIPv6()
+	r.Counter = ctx.DecodeUInt32()
+
+	return nil
+}
+
+func testTypedRows(n int) []*testTypedRow {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+
+	rows := make([]*testTypedRow, n)
+	for i := range rows {
+		t := start.Add(time.Duration(i) * time.Second)
+		rows[i] = &testTypedRow{
+			Time:    t,
+			Millis:  t.Truncate(time.Millisecond),
+			Price:   NewDecimal(int64(i)*12345-50000, 4),
+			ID:      UUID{0xde, 0xad, byte(i), 15: 1},
+			Client:  netip.AddrFrom4([4]byte{10, 0, 0, byte(i)}),
+			Peer:    netip.MustParseAddr("2001:db8::1"),
+			Counter: uint32(i),
+		}
+	}
+
+	return rows
+}
+
+func checkTypedRow(t *testing.T, got, expected *testTypedRow) {
+	if !got.Time.Equal(expected.Time) || !got.Millis.Equal(expected.Millis) || got.Price != expected.Price ||
+		got.ID != expected.ID || got.Client != expected.Client || got.Peer != expected.Peer || got.Counter != expected.Counter {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestByteOrder(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "big.sbt")
+
+	c, err := Create[*testTypedRow, testTypedRow](filename, WithByteOrder(BigEndian), WithKeyColumn("Time"))
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	rows := testTypedRows(100)
+	if err = c.BulkAppend(rows); err != nil {
+		t.Fatalf("failed to append rows: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if c, err = Open[*testTypedRow, testTypedRow](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	if c.ByteOrder() != BigEndian {
+		t.Fatalf("expected big endian, got %s", c.ByteOrder())
+	}
+
+	raw, err := c.RawRow(7)
+	if err != nil {
+		t.Fatalf("failed to read raw row: %v", err)
+	}
+
+	if !bytes.Equal(raw[len(raw)-4:], []byte{0, 0, 0, 7}) || int64(binary.BigEndian.Uint64(raw)) != rows[7].Time.UnixNano() {
+		t.Fatalf("row isn't big endian: %x", raw)
+	}
+
+	row := new(testTypedRow)
+	if err = c.ReadAt(42, row); err != nil {
+		t.Fatalf("failed to read row: %v", err)
+	}
+	checkTypedRow(t, row, rows[42])
+
+	pos, err := c.SearchFirst(rows[30].Time.UnixNano())
+	if err != nil || pos != 30 {
+		t.Fatalf("expected key search to find row 30, got %d: %v", pos, err)
+	}
+
+	values, err := ReadColumn[uint32](c, "Counter", 10, 2)
+	if err != nil || values[0] != 10 || values[1] != 11 {
+		t.Fatalf("unexpected column values %v: %v", values, err)
+	}
+}
+
+func TestTypedExport(t *testing.T) {
+	for _, format := range []ExportFormat{ExportCSV, ExportJSONL, ExportParquet} {
+		t.Run(string(format), func(t *testing.T) {
+			dir := t.TempDir()
+
+			src, err := Create[*testTypedRow, testTypedRow](filepath.Join(dir, "src.sbt"))
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer src.Close()
+
+			rows := testTypedRows(10)
+			if err = src.BulkAppend(rows); err != nil {
+				t.Fatalf("failed to append rows: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err = src.Export(&buf, format); err != nil {
+				t.Fatalf("failed to export: %v", err)
+			}
+
+			dst, err := Create[*testTypedRow, testTypedRow](filepath.Join(dir, "dst.sbt"), WithByteOrder(BigEndian))
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			defer dst.Close()
+
+			if _, err = dst.Import(&buf, format); err != nil {
+				t.Fatalf("failed to import: %v", err)
+			}
+
+			for i, expected := range rows {
+				row := new(testTypedRow)
+				if err = dst.ReadAt(int64(i), row); err != nil {
+					t.Fatalf("failed to read row: %v", err)
+				}
+
+				checkTypedRow(t, row, expected)
+			}
+		})
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	for s, expected := range map[string]Decimal{
+		"12.340": {12340, 3},
+		"-0.05":  {-5, 2},
+		"7":      {7, 0},
+	} {
+		d, err := ParseDecimal(s)
+		if err != nil || d != expected || d.String() != s {
+			t.Fatalf("parsed %q as %+v (%s): %v", s, d, d, err)
+		}
+	}
+
+	if d, err := NewDecimal(-5, 2).Rescale(4); err != nil || d != NewDecimal(-500, 4) {
+		t.Fatalf("unexpected rescaled decimal %+v: %v", d, err)
+	}
+
+	if d, err := NewDecimal(12345, 4).Rescale(2); err != nil || d.String() != "1.23" {
+		t.Fatalf("unexpected truncated decimal %s: %v", d, err)
+	}
+
+	if _, err := NewDecimal(1<<62, 0).Rescale(2); err == nil {
+		t.Fatalf("expected overflow error")
+	}
+
+	if d, err := DecimalFromFloat(3.14159, 2); err != nil || d != NewDecimal(314, 2) || d.Float64() != 3.14 {
+		t.Fatalf("unexpected decimal %+v: %v", d, err)
+	}
+}
+
+func TestUUID(t *testing.T) {
+	s := "123e4567-e89b-12d3-a456-426614174000"
+
+	u, err := ParseUUID(s)
+	if err != nil || u.String() != s || u[0] != 0x12 || u[15] != 0 {
+		t.Fatalf("parsed %q as %s: %v", s, u, err)
+	}
+
+	if _, err = ParseUUID("123e4567e89b12d3a456426614174000"); err == nil {
+		t.Fatalf("expected invalid uuid error")
+	}
+}
+
+func TestTimePrecision(t *testing.T) {
+	ts := time.Unix(-3, 987654321)
+
+	for precision, expected := range map[uint8]time.Time{
+		0: time.Unix(-3, 0),
+		3: time.Unix(-3, 987000000),
+		9: ts,
+	} {
+		if got := unitsTime(timeUnits(ts, precision), precision); !got.Equal(expected) {
+			t.Fatalf("precision %d: expected %v, got %v", precision, expected, got)
+		}
+	}
+
+	spec := NewRowSpec(NewTimeColumn("Time", 10))
+	if err := spec.validate(); err == nil {
+		t.Fatalf("expected invalid precision error")
+	}
+}
+
+func TestEncodeTimePrecision(t *testing.T) {
+	ts := time.Unix(1714564800, 123456789)
+
+	for _, precision := range []uint8{0, 3, 6, 9} {
+		spec := NewRowSpec(NewTimeColumn("Time", precision), ColumnTypeInt64.New("Nanos"))
+
+		e := NewEncoder(make([]byte, spec.RowSize()))
+		e.spec = spec
+		e.EncodeTime(ts)
+		e.EncodeTime(ts)
+
+		if v := int64(binary.LittleEndian.Uint64(e.Bytes())); v != timeUnits(ts, precision) {
+			t.Fatalf("precision %d: expected %d, got %d", precision, timeUnits(ts, precision), v)
+		}
+
+		// i64 columns keep unix nanoseconds
+		if v := int64(binary.LittleEndian.Uint64(e.Bytes()[8:])); v != ts.UnixNano() {
+			t.Fatalf("precision %d: expected %d nanoseconds, got %d", precision, ts.UnixNano(), v)
+		}
+
+		d := NewDecoder(e.Bytes())
+		d.spec = spec
+
+		expected := unitsTime(timeUnits(ts, precision), precision)
+		if got := d.DecodeTime(); !got.Equal(expected) {
+			t.Fatalf("precision %d: expected %v, got %v", precision, expected, got)
+		}
+
+		if got := d.DecodeTime(); !got.Equal(ts) {
+			t.Fatalf("precision %d: expected %v nanoseconds, got %v", precision, ts, got)
+		}
+	}
+}
+
+type testTimeStruct struct {
+	Seconds time.Time `sbt:"seconds,time,0"`
+	Millis  time.Time `sbt:"millis,time,3"`
+	Micros  time.Time `sbt:"micros,time,6"`
+	Nanos   time.Time `sbt:"nanos,time"`
+	Legacy  time.Time `sbt:"legacy"`
+}
+
+func TestStructRowTime(t *testing.T) {
+	spec, err := StructSpec[testTimeStruct]()
+	if err != nil {
+		t.Fatalf("failed to derive spec: %v", err)
+	}
+
+	for i, precision := range []uint8{0, 3, 6, 9} {
+		if spec[i].Type != ColumnTypeTime || spec[i].Precision != precision {
+			t.Fatalf("unexpected column %+v", spec[i])
+		}
+	}
+
+	if spec[4].Type != ColumnTypeInt64 {
+		t.Fatalf("unexpected column %+v", spec[4])
+	}
+
+	filename := filepath.Join(t.TempDir(), "time.sbt")
+	ts := time.Unix(1714564800, 123456789)
+
+	c, err := Create[*StructRow[testTimeStruct], StructRow[testTimeStruct]](filename)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	if err = c.Append(NewStructRow(testTimeStruct{ts, ts, ts, ts, ts})); err != nil {
+		t.Fatalf("failed to append row: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("failed to close container: %v", err)
+	}
+
+	if c, err = Open[*StructRow[testTimeStruct], StructRow[testTimeStruct]](filename); err != nil {
+		t.Fatalf("failed to open container: %v", err)
+	}
+	defer c.Close()
+
+	row := new(StructRow[testTimeStruct])
+	if err = c.ReadAt(0, row); err != nil {
+		t.Fatalf("failed to read row: %v", err)
+	}
+
+	for name, got := range map[string]time.Time{
+		"seconds": row.Value.Seconds, "millis": row.Value.Millis, "micros": row.Value.Micros,
+		"nanos": row.Value.Nanos, "legacy": row.Value.Legacy,
+	} {
+		precision := spec[spec.Index(name)].Precision
+		if spec[spec.Index(name)].Type != ColumnTypeTime {
+			precision = MaxTimePrecision
+		}
+
+		if expected := unitsTime(timeUnits(ts, precision), precision); !got.Equal(expected) {
+			t.Fatalf("%s: expected %v, got %v", name, expected, got)
+		}
+	}
+
+	if _, err = ParseStructTag("at,time,10"); err == nil {
+		t.Fatalf("expected invalid precision error")
+	}
+}
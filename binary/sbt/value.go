@@ -0,0 +1,444 @@
+package sbt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// decodeValues decodes the columns of the current row into values, nil for null columns.
+func decodeValues(d *Decoder, values []any) (err error) {
+	for i, column := range d.spec {
+		if d.IsNull(i) {
+			d.next(int(column.Size))
+			values[i] = nil
+			continue
+		}
+
+		if values[i], err = decodeValue(d, column); err != nil {
+			return fmt.Errorf("failed to decode column %q: %w", column.Name, err)
+		}
+	}
+
+	return d.endRow()
+}
+
+// encodeValues encodes values as the columns of the current row.
+func encodeValues(e *Encoder, values []any) (err error) {
+	for i, column := range e.spec {
+		if err = encodeValue(e, column, values[i]); err != nil {
+			return fmt.Errorf("failed to encode column %q: %w", column.Name, err)
+		}
+	}
+
+	return e.endRow()
+}
+
+// decodeValue decodes a single value of column.
+//
+// Values are bool, the sized int, uint and float types, string for str and vstr columns,
+// []byte for bin and vbin columns, time.Time, Decimal, UUID and netip.Addr.
+func decodeValue(d *Decoder, column Column) (v any, err error) {
+	switch column.Type {
+	case ColumnTypeString:
+		v = d.DecodeStringPadded(int(column.Size))
+	case ColumnTypeBinary:
+		v = append([]byte(nil), d.DecodeBytes(int(column.Size))...)
+	case ColumnTypeVarString:
+		v, err = d.DecodeVarString()
+	case ColumnTypeVarBinary:
+		v, err = d.DecodeVarBytes()
+	case ColumnTypeBool:
+		v = d.DecodeBool()
+	case ColumnTypeInt8:
+		v = d.DecodeInt8()
+	case ColumnTypeInt16:
+		v = d.DecodeInt16()
+	case ColumnTypeInt32:
+		v = d.DecodeInt32()
+	case ColumnTypeInt64:
+		v = d.DecodeInt64()
+	case ColumnTypeUInt8:
+		v = d.DecodeUInt8()
+	case ColumnTypeUInt16:
+		v = d.DecodeUInt16()
+	case ColumnTypeUInt32:
+		v = d.DecodeUInt32()
+	case ColumnTypeUInt64:
+		v = d.DecodeUInt64()
+	case ColumnTypeFloat32:
+		v = d.DecodeFloat32()
+	case ColumnTypeFloat64:
+		v = d.DecodeFloat64()
+	case ColumnTypeTime:
+		v = d.DecodeTimestamp(column.Precision)
+	case ColumnTypeDecimal:
+		v = d.DecodeDecimal(column.Scale)
+	case ColumnTypeUUID:
+		v = d.DecodeUUID()
+	case ColumnTypeIPv4:
+		v = d.DecodeIPv4()
+	case ColumnTypeIPv6:
+		v = d.DecodeIPv6()
+	default:
+		err = fmt.Errorf("unsupported column type %q", column.Type)
+	}
+
+	return
+}
+
+// encodeValue encodes a single value of column, converting it from any integer, float, string or []byte value.
+// Strings are parsed for time (RFC 3339), decimal, uuid and ip columns, integers are stored as is in time columns.
+// A nil value encodes null for nullable columns and the zero value otherwise.
+func encodeValue(e *Encoder, column Column, v any) (err error) {
+	if v == nil && column.Nullable {
+		return e.EncodeNull()
+	}
+
+	switch column.Type {
+	case ColumnTypeString, ColumnTypeVarString, ColumnTypeBinary, ColumnTypeVarBinary:
+		var b []byte
+		switch v := v.(type) {
+		case nil:
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			return fmt.Errorf("can't encode %T as %s", v, column.Type)
+		}
+
+		if column.Type.IsVariable() {
+			e.EncodeVarBytes(b)
+		} else {
+			e.EncodeBytesPadded(b, int(column.Size))
+		}
+	case ColumnTypeBool:
+		var b bool
+		switch v := v.(type) {
+		case nil:
+		case bool:
+			b = v
+		default:
+			return fmt.Errorf("can't encode %T as %s", v, column.Type)
+		}
+
+		e.EncodeBool(b)
+	case ColumnTypeFloat32, ColumnTypeFloat64:
+		var f float64
+		if f, err = floatValue(v); err != nil {
+			return
+		}
+
+		if column.Type == ColumnTypeFloat32 {
+			e.EncodeFloat32(float32(f))
+		} else {
+			e.EncodeFloat64(f)
+		}
+	case ColumnTypeInt8, ColumnTypeInt16, ColumnTypeInt32, ColumnTypeInt64:
+		var i int64
+		if i, err = intValue(v, column.Type); err != nil {
+			return
+		}
+
+		switch column.Type {
+		case ColumnTypeInt8:
+			e.EncodeInt8(int8(i))
+		case ColumnTypeInt16:
+			e.EncodeInt16(int16(i))
+		case ColumnTypeInt32:
+			e.EncodeInt32(int32(i))
+		default:
+			e.EncodeInt64(i)
+		}
+	case ColumnTypeUInt8, ColumnTypeUInt16, ColumnTypeUInt32, ColumnTypeUInt64:
+		var u uint64
+		if u, err = uintValue(v, column.Type); err != nil {
+			return
+		}
+
+		switch column.Type {
+		case ColumnTypeUInt8:
+			e.EncodeUInt8(uint8(u))
+		case ColumnTypeUInt16:
+			e.EncodeUInt16(uint16(u))
+		case ColumnTypeUInt32:
+			e.EncodeUInt32(uint32(u))
+		default:
+			e.EncodeUInt64(u)
+		}
+	case ColumnTypeTime:
+		var t time.Time
+		switch v := v.(type) {
+		case nil:
+			e.EncodeInt64(0)
+			return
+		case time.Time:
+			t = v
+		case string:
+			if t, err = time.Parse(time.RFC3339Nano, v); err != nil {
+				return
+			}
+		default:
+			var i int64
+			if i, err = intValue(v, ColumnTypeInt64); err != nil {
+				return
+			}
+
+			e.EncodeInt64(i)
+			return
+		}
+
+		e.EncodeTimestamp(t, column.Precision)
+	case ColumnTypeDecimal:
+		var d Decimal
+		if d, err = decimalValue(v, column.Scale); err != nil {
+			return
+		}
+
+		err = e.EncodeDecimal(d, column.Scale)
+	case ColumnTypeUUID:
+		var u UUID
+		switch v := v.(type) {
+		case nil:
+		case UUID:
+			u = v
+		case [16]byte:
+			u = v
+		case []byte:
+			if len(v) != len(u) {
+				return fmt.Errorf("can't encode %d bytes as %s", len(v), column.Type)
+			}
+
+			copy(u[:], v)
+		case string:
+			if u, err = ParseUUID(v); err != nil {
+				return
+			}
+		default:
+			return fmt.Errorf("can't encode %T as %s", v, column.Type)
+		}
+
+		e.EncodeUUID(u)
+	case ColumnTypeIPv4, ColumnTypeIPv6:
+		var a netip.Addr
+		switch v := v.(type) {
+		case nil:
+		case netip.Addr:
+			a = v
+		case net.IP:
+			var ok bool
+			if a, ok = netip.AddrFromSlice(v); !ok {
+				return fmt.Errorf("invalid ip %v", v)
+			}
+		case []byte:
+			var ok bool
+			if a, ok = netip.AddrFromSlice(v); !ok {
+				return fmt.Errorf("invalid ip %v", v)
+			}
+		case string:
+			if a, err = netip.ParseAddr(v); err != nil {
+				return
+			}
+		default:
+			return fmt.Errorf("can't encode %T as %s", v, column.Type)
+		}
+
+		if column.Type == ColumnTypeIPv6 {
+			e.EncodeIPv6(a)
+		} else if a.IsValid() && !a.Unmap().Is4() {
+			return fmt.Errorf("can't encode %v as %s", a, column.Type)
+		} else {
+			e.EncodeIPv4(a)
+		}
+	default:
+		err = fmt.Errorf("unsupported column type %q", column.Type)
+	}
+
+	return
+}
+
+// decimalValue converts a Decimal, a string, a float or an integer holding a whole number to a Decimal.
+func decimalValue(v any, scale uint8) (d Decimal, err error) {
+	switch v := v.(type) {
+	case nil:
+		return Decimal{Scale: scale}, nil
+	case Decimal:
+		return v, nil
+	case string:
+		return ParseDecimal(v)
+	case float32:
+		return DecimalFromFloat(float64(v), scale)
+	case float64:
+		return DecimalFromFloat(v, scale)
+	}
+
+	if d.Unscaled, err = intValue(v, ColumnTypeInt64); err != nil {
+		return
+	}
+
+	return
+}
+
+// intBits returns the size in bits of an integer column type.
+func intBits(t ColumnType) int {
+	switch t {
+	case ColumnTypeInt8, ColumnTypeUInt8:
+		return 8
+	case ColumnTypeInt16, ColumnTypeUInt16:
+		return 16
+	case ColumnTypeInt32, ColumnTypeUInt32:
+		return 32
+	}
+
+	return 64
+}
+
+// intValue converts an integer value to an int64 fitting the signed column type t.
+func intValue(v any, t ColumnType) (i int64, err error) {
+	switch v := v.(type) {
+	case nil:
+	case int:
+		i = int64(v)
+	case int8:
+		i = int64(v)
+	case int16:
+		i = int64(v)
+	case int32:
+		i = int64(v)
+	case int64:
+		i = v
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows %s", v, t)
+		}
+
+		i = int64(v)
+	case uint8:
+		i = int64(v)
+	case uint16:
+		i = int64(v)
+	case uint32:
+		i = int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows %s", v, t)
+		}
+
+		i = int64(v)
+	case string:
+		return strconv.ParseInt(v, 10, intBits(t))
+	default:
+		return 0, fmt.Errorf("can't encode %T as %s", v, t)
+	}
+
+	if bits := intBits(t); bits < 64 && (i < -1<<(bits-1) || i >= 1<<(bits-1)) {
+		err = fmt.Errorf("value %d overflows %s", i, t)
+	}
+
+	return
+}
+
+// uintValue converts an integer value to an uint64 fitting the unsigned column type t.
+func uintValue(v any, t ColumnType) (u uint64, err error) {
+	switch v := v.(type) {
+	case nil:
+	case uint:
+		u = uint64(v)
+	case uint8:
+		u = uint64(v)
+	case uint16:
+		u = uint64(v)
+	case uint32:
+		u = uint64(v)
+	case uint64:
+		u = v
+	case int, int8, int16, int32, int64:
+		var i int64
+		if i, err = intValue(v, ColumnTypeInt64); err != nil {
+			return
+		}
+
+		if i < 0 {
+			return 0, fmt.Errorf("value %d overflows %s", i, t)
+		}
+
+		u = uint64(i)
+	case string:
+		return strconv.ParseUint(v, 10, intBits(t))
+	default:
+		return 0, fmt.Errorf("can't encode %T as %s", v, t)
+	}
+
+	if bits := intBits(t); bits < 64 && u >= 1<<bits {
+		err = fmt.Errorf("value %d overflows %s", u, t)
+	}
+
+	return
+}
+
+// floatValue converts a numeric value to a float64.
+func floatValue(v any) (f float64, err error) {
+	switch v := v.(type) {
+	case nil:
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		var i int64
+		if i, err = intValue(v, ColumnTypeInt64); err != nil {
+			var u uint64
+			if u, err = uintValue(v, ColumnTypeUInt64); err != nil {
+				return
+			}
+
+			return float64(u), nil
+		}
+
+		f = float64(i)
+	}
+
+	return
+}
+
+// formatValue formats a value as text, binary values being base64 encoded and null values empty.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// parseValue parses a value of column formatted by formatValue.
+func parseValue(column Column, s string) (v any, err error) {
+	switch column.Type {
+	case ColumnTypeString, ColumnTypeVarString:
+		return s, nil
+	case ColumnTypeBinary, ColumnTypeVarBinary:
+		return base64.StdEncoding.DecodeString(s)
+	case ColumnTypeBool:
+		return strconv.ParseBool(s)
+	}
+
+	// numbers are parsed by encodeValue
+	return s, nil
+}
@@ -33,30 +33,51 @@ func GZipFile(inputFilename, outputFilename string) error {
 	return nil
 }
 
+// ExtractGZipFile decompresses inputFilename next to itself, removing its extension, and returns the
+// decompressed filename. outputFilename is ignored, see ExtractGZipFileTo.
 func ExtractGZipFile(inputFilename, outputFilename string) (string, error) {
+	decompressedFilePath := strings.TrimSuffix(inputFilename, filepath.Ext(inputFilename))
+	if err := ExtractGZipFileTo(inputFilename, decompressedFilePath); err != nil {
+		return "", err
+	}
+
+	return decompressedFilePath, nil
+}
+
+// ExtractGZipFileTo decompresses inputFilename into outputFilename.
+func ExtractGZipFileTo(inputFilename, outputFilename string) error {
 	file, err := os.Open(inputFilename)
 	if err != nil {
-		return "", errors.Newif(err, "error opening file: %s", inputFilename)
+		return errors.Newif(err, "error opening file: %s", inputFilename)
 	}
 	defer file.Close()
 
-	decompressedFilePath := strings.TrimSuffix(inputFilename, filepath.Ext(inputFilename))
-	decompressedFile, err := os.Create(decompressedFilePath)
+	decompressedFile, err := os.Create(outputFilename)
 	if err != nil {
-		return "", errors.Newif(err, "error creating decompressed file: %s", inputFilename)
+		return errors.Newif(err, "error creating decompressed file: %s", outputFilename)
 	}
 	defer decompressedFile.Close()
 
 	gzipReader, err := gzip.NewReader(file)
 	if err != nil {
-		return "", errors.Newif(err, "error creating gzip reader: %s", inputFilename)
+		return errors.Newif(err, "error creating gzip reader: %s", inputFilename)
 	}
 	defer gzipReader.Close()
 
 	_, err = io.Copy(decompressedFile, gzipReader)
 	if err != nil {
-		return "", errors.Newif(err, "error copying file to gzip writer: %s", inputFilename)
+		return errors.Newif(err, "error copying file to gzip writer: %s", inputFilename)
 	}
 
-	return decompressedFilePath, nil
+	return nil
+}
+
+// EasyGzip compresses a file next to itself, adding the .gz extension.
+func EasyGzip(filename string) error {
+	return GZipFile(filename, filename+".gz")
+}
+
+// EasyUnGzip decompresses a .gz file next to itself, returning the decompressed filename.
+func EasyUnGzip(filename string) (string, error) {
+	return ExtractGZipFile(filename, strings.TrimSuffix(filename, filepath.Ext(filename)))
 }
@@ -0,0 +1,45 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractGZipFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "data.txt")
+
+	if err := os.WriteFile(filename, []byte("hello gzip"), 0666); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if err := EasyGzip(filename); err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+
+	if err := os.Remove(filename); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+
+	// the output filename is ignored, the file is extracted next to the archive
+	out, err := ExtractGZipFile(filename+".gz", filepath.Join(dir, "ignored.txt"))
+	if err != nil || out != filename {
+		t.Fatalf("expected %s, got %s: %v", filename, out, err)
+	}
+
+	if Exists(filepath.Join(dir, "ignored.txt")) {
+		t.Fatalf("expected output filename to be ignored")
+	}
+
+	other := filepath.Join(dir, "other.txt")
+	if err = ExtractGZipFileTo(filename+".gz", other); err != nil {
+		t.Fatalf("failed to extract: %v", err)
+	}
+
+	for _, f := range []string{filename, other} {
+		if b, err := os.ReadFile(f); err != nil || string(b) != "hello gzip" {
+			t.Fatalf("unexpected content of %s %q: %v", f, b, err)
+		}
+	}
+}
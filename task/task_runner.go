@@ -23,16 +23,17 @@ func newRunner(ctx context.Context, config *TaskConfig) (r *TaskRunner, err erro
 
 	r.ctx, r.cancel = context.WithCancel(ctx)
 
-	r.wg.Add(1)
-	go r.run()
-
 	if err = r.config.callHandler(r.config.onBeforeStart); err != nil {
 		err = fmt.Errorf("error calling onBeforeStart handler: %w", err)
 		return
 	}
 
+	// schedule before starting, run reads the next step
 	r.calculateTaskNextStep()
 
+	r.wg.Add(1)
+	go r.run()
+
 	return
 }
 